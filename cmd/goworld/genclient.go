@@ -0,0 +1,240 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/lovelly/goworld/engine/entity"
+)
+
+const genClientHeader = "// Code generated by goworld gen-client. DO NOT EDIT.\n"
+
+// genClient generates client SDK stubs from the entity types registered by the server
+func genClient(sid ServerID, outputDir string) {
+	buildServer(sid)
+
+	if outputDir == "" {
+		outputDir = filepath.Join(sid.Path(), "gen_client")
+	}
+	err := os.MkdirAll(outputDir, 0755)
+	checkErrorOrQuit(err, "create output directory failed")
+
+	schemaFile := filepath.Join(outputDir, "schema.json")
+	showMsg("generating schema %s ...", schemaFile)
+	gameExePath := filepath.Join(sid.Path(), sid.Name()+BinaryExtension)
+	cmd := exec.Command(gameExePath, "-gen-schema", schemaFile)
+	cmd.Stderr = os.Stderr
+	cmd.Stdout = os.Stdout
+	err = cmd.Run()
+	checkErrorOrQuit(err, "generate schema failed")
+
+	data, err := ioutil.ReadFile(schemaFile)
+	checkErrorOrQuit(err, "read schema failed")
+	var schemas []*entity.EntityTypeSchema
+	err = json.Unmarshal(data, &schemas)
+	checkErrorOrQuit(err, "parse schema failed")
+
+	csFile := filepath.Join(outputDir, "GoWorldEntities.cs")
+	showMsg("generating C# bindings %s ...", csFile)
+	err = ioutil.WriteFile(csFile, genClientCSharp(schemas), 0644)
+	checkErrorOrQuit(err, "write C# bindings failed")
+
+	tsFile := filepath.Join(outputDir, "goworld_entities.ts")
+	showMsg("generating TypeScript bindings %s ...", tsFile)
+	err = ioutil.WriteFile(tsFile, genClientTypeScript(schemas), 0644)
+	checkErrorOrQuit(err, "write TypeScript bindings failed")
+}
+
+func genClientCSharp(schemas []*entity.EntityTypeSchema) []byte {
+	var b bytes.Buffer
+	b.WriteString(genClientHeader)
+	b.WriteString("using System.Collections.Generic;\n\n")
+	b.WriteString("namespace GoWorld.Generated\n{\n")
+	b.WriteString("    public interface IRPCCaller\n    {\n")
+	b.WriteString("        void CallServer(string entityID, string method, params object[] args);\n")
+	b.WriteString("    }\n")
+
+	for _, schema := range schemas {
+		fmt.Fprintf(&b, "\n    // %s is the client stub of entity type %s\n", schema.TypeName+"Stub", schema.TypeName)
+		fmt.Fprintf(&b, "    public partial class %sStub\n    {\n", schema.TypeName)
+		fmt.Fprintf(&b, "        public const string TypeName = %q;\n\n", schema.TypeName)
+		b.WriteString("        public readonly string ID;\n")
+		b.WriteString("        public readonly IDictionary<string, object> Attrs;\n")
+		b.WriteString("        readonly IRPCCaller caller;\n\n")
+		fmt.Fprintf(&b, "        public %sStub(string id, IDictionary<string, object> attrs, IRPCCaller caller)\n        {\n", schema.TypeName)
+		b.WriteString("            ID = id;\n            Attrs = attrs;\n            this.caller = caller;\n        }\n")
+
+		for _, rpc := range schema.ClientRPCs {
+			var params, args []string
+			for i, argType := range rpc.Args {
+				params = append(params, fmt.Sprintf("%s arg%d", csharpType(argType), i))
+				args = append(args, fmt.Sprintf("arg%d", i))
+			}
+			fmt.Fprintf(&b, "\n        // %s can be called by %s\n", rpc.Name, rpcCallers(rpc))
+			fmt.Fprintf(&b, "        public void %s(%s)\n        {\n", rpc.Name, strings.Join(params, ", "))
+			fmt.Fprintf(&b, "            caller.CallServer(ID, %q%s);\n        }\n", rpc.Name, joinArgs(args))
+		}
+
+		for _, attr := range schema.Attrs {
+			t := csharpType(attr.Type)
+			fmt.Fprintf(&b, "\n        // %s is synced to %s\n", attr.Name, attrReceivers(attr))
+			fmt.Fprintf(&b, "        public %s %s\n        {\n", t, attr.Name)
+			fmt.Fprintf(&b, "            get { object v; return Attrs.TryGetValue(%q, out v) ? (%s)v : default(%s); }\n", attr.Name, t, t)
+			b.WriteString("        }\n")
+		}
+
+		if len(schema.ServerRPCs) > 0 {
+			b.WriteString("\n        // Server RPCs which can not be called by clients\n")
+			b.WriteString("        public static readonly string[] ServerRPCs = {")
+			for i, rpc := range schema.ServerRPCs {
+				if i > 0 {
+					b.WriteString(", ")
+				}
+				fmt.Fprintf(&b, "%q", rpc.Name)
+			}
+			b.WriteString("};\n")
+		}
+		b.WriteString("    }\n")
+	}
+	b.WriteString("}\n")
+	return b.Bytes()
+}
+
+func genClientTypeScript(schemas []*entity.EntityTypeSchema) []byte {
+	var b bytes.Buffer
+	b.WriteString(genClientHeader)
+	b.WriteString("\nexport interface RPCCaller {\n")
+	b.WriteString("    callServer(entityID: string, method: string, ...args: any[]): void;\n")
+	b.WriteString("}\n")
+
+	for _, schema := range schemas {
+		fmt.Fprintf(&b, "\n// %sAttrs are the attributes of entity type %s synced to clients\n", schema.TypeName, schema.TypeName)
+		fmt.Fprintf(&b, "export interface %sAttrs {\n", schema.TypeName)
+		for _, attr := range schema.Attrs {
+			fmt.Fprintf(&b, "    %s?: %s; // synced to %s\n", attr.Name, typescriptType(attr.Type), attrReceivers(attr))
+		}
+		b.WriteString("}\n")
+
+		fmt.Fprintf(&b, "\n// %sStub is the client stub of entity type %s\n", schema.TypeName, schema.TypeName)
+		fmt.Fprintf(&b, "export class %sStub {\n", schema.TypeName)
+		fmt.Fprintf(&b, "    static readonly typeName = %q;\n", schema.TypeName)
+		b.WriteString("    static readonly serverRPCs: string[] = [")
+		for i, rpc := range schema.ServerRPCs {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			fmt.Fprintf(&b, "%q", rpc.Name)
+		}
+		b.WriteString("];\n\n")
+		fmt.Fprintf(&b, "    constructor(readonly id: string, readonly attrs: %sAttrs, private caller: RPCCaller) {}\n", schema.TypeName)
+
+		for _, rpc := range schema.ClientRPCs {
+			var params, args []string
+			for i, argType := range rpc.Args {
+				params = append(params, fmt.Sprintf("arg%d: %s", i, typescriptType(argType)))
+				args = append(args, fmt.Sprintf("arg%d", i))
+			}
+			fmt.Fprintf(&b, "\n    // %s can be called by %s\n", rpc.Name, rpcCallers(rpc))
+			fmt.Fprintf(&b, "    %s(%s): void {\n", rpc.Name, strings.Join(params, ", "))
+			fmt.Fprintf(&b, "        this.caller.callServer(this.id, %q%s);\n    }\n", rpc.Name, joinArgs(args))
+		}
+		b.WriteString("}\n")
+	}
+	return b.Bytes()
+}
+
+func rpcCallers(rpc *entity.RPCSchema) string {
+	if rpc.OtherClients {
+		return "all clients"
+	}
+	return "own client"
+}
+
+func attrReceivers(attr *entity.AttrSchema) string {
+	if attr.AllClients {
+		return "all clients"
+	}
+	return "own client"
+}
+
+func joinArgs(args []string) string {
+	if len(args) == 0 {
+		return ""
+	}
+	return ", " + strings.Join(args, ", ")
+}
+
+// splitSchemaType splits schema type like map<K,V> to the type name and type parameters
+func splitSchemaType(t string) (name string, params []string) {
+	lt := strings.IndexByte(t, '<')
+	if lt < 0 || !strings.HasSuffix(t, ">") {
+		return t, nil
+	}
+
+	name = t[:lt]
+	inner := t[lt+1 : len(t)-1]
+	depth, start := 0, 0
+	for i := 0; i < len(inner); i++ {
+		switch inner[i] {
+		case '<':
+			depth += 1
+		case '>':
+			depth -= 1
+		case ',':
+			if depth == 0 {
+				params = append(params, inner[start:i])
+				start = i + 1
+			}
+		}
+	}
+	params = append(params, inner[start:])
+	return
+}
+
+func csharpType(t string) string {
+	name, params := splitSchemaType(t)
+	switch name {
+	case "int":
+		return "long"
+	case "float":
+		return "double"
+	case "bool":
+		return "bool"
+	case "string":
+		return "string"
+	case "bytes":
+		return "byte[]"
+	case "list":
+		return "List<" + csharpType(params[0]) + ">"
+	case "map":
+		return "Dictionary<" + csharpType(params[0]) + ", " + csharpType(params[1]) + ">"
+	default:
+		return "object"
+	}
+}
+
+func typescriptType(t string) string {
+	name, params := splitSchemaType(t)
+	switch name {
+	case "int", "float":
+		return "number"
+	case "bool":
+		return "boolean"
+	case "string":
+		return "string"
+	case "bytes":
+		return "Uint8Array"
+	case "list":
+		return typescriptType(params[0]) + "[]"
+	case "map":
+		return "{ [key: string]: " + typescriptType(params[1]) + " }"
+	default:
+		return "any"
+	}
+}
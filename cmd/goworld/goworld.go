@@ -29,6 +29,7 @@ func main() {
 		showMsg("no command to execute")
 		flag.Usage()
 		fmt.Fprintf(os.Stderr, "\tgoworld <build|start|stop|kill|reload|status> [server-id]\n")
+		fmt.Fprintf(os.Stderr, "\tgoworld gen-client <server-id> [output-dir]\n")
 		os.Exit(1)
 	}
 
@@ -56,6 +57,15 @@ func main() {
 		kill(ServerID(args[1]))
 	} else if cmd == "status" {
 		status()
+	} else if cmd == "gen-client" {
+		if len(args) < 2 || len(args) > 3 {
+			showMsgAndQuit("usage: goworld gen-client <server-id> [output-dir]")
+		}
+		var outputDir string
+		if len(args) == 3 {
+			outputDir = args[2]
+		}
+		genClient(ServerID(args[1]), outputDir)
 	} else {
 		showMsgAndQuit("unknown command: %s", cmd)
 	}
@@ -85,6 +85,10 @@ func (dcp *dispatcherClientProxy) serve() {
 			dcp.owner.handleSyncPositionYawOnClients(dcp, pkt)
 		} else if msgtype == proto.MT_CALL_ENTITY_METHOD {
 			dcp.owner.handleCallEntityMethod(dcp, pkt)
+		} else if msgtype == proto.MT_CALL_ENTITY_METHOD_WITH_REPLY {
+			dcp.owner.handleCallEntityMethodWithReply(dcp, pkt)
+		} else if msgtype == proto.MT_CALL_ENTITY_METHOD_REPLY {
+			dcp.owner.handleCallEntityMethodReply(dcp, pkt)
 		} else if msgtype >= proto.MT_REDIRECT_TO_GATEPROXY_MSG_TYPE_START && msgtype <= proto.MT_REDIRECT_TO_GATEPROXY_MSG_TYPE_STOP {
 			dcp.owner.handleDoSomethingOnSpecifiedClient(dcp, pkt)
 		} else if msgtype == proto.MT_CALL_ENTITY_METHOD_FROM_CLIENT {
@@ -93,6 +97,8 @@ func (dcp *dispatcherClientProxy) serve() {
 			dcp.owner.handleMigrateRequest(dcp, pkt)
 		} else if msgtype == proto.MT_REAL_MIGRATE {
 			dcp.owner.handleRealMigrate(dcp, pkt)
+		} else if msgtype == proto.MT_GIVE_CLIENT_TO_ENTITY {
+			dcp.owner.handleGiveClientToEntity(dcp, pkt)
 		} else if msgtype == proto.MT_CALL_FILTERED_CLIENTS {
 			dcp.owner.handleCallFilteredClientProxies(dcp, pkt)
 		} else if msgtype == proto.MT_NOTIFY_CLIENT_CONNECTED {
@@ -101,12 +107,17 @@ func (dcp *dispatcherClientProxy) serve() {
 			dcp.owner.handleNotifyClientDisconnected(dcp, pkt)
 		} else if msgtype == proto.MT_LOAD_ENTITY_ANYWHERE {
 			dcp.owner.handleLoadEntityAnywhere(dcp, pkt)
+		} else if msgtype == proto.MT_LOAD_ENTITY_IN_SPACE {
+			dcp.owner.handleLoadEntityInSpace(dcp, pkt)
 		} else if msgtype == proto.MT_NOTIFY_CREATE_ENTITY {
 			eid := pkt.ReadEntityID()
 			dcp.owner.handleNotifyCreateEntity(dcp, pkt, eid)
 		} else if msgtype == proto.MT_NOTIFY_DESTROY_ENTITY {
 			eid := pkt.ReadEntityID()
 			dcp.owner.handleNotifyDestroyEntity(dcp, pkt, eid)
+		} else if msgtype == proto.MT_NOTIFY_CREATE_ENTITY_FAILED {
+			eid := pkt.ReadEntityID()
+			dcp.owner.handleNotifyCreateEntityFailed(dcp, pkt, eid)
 		} else if msgtype == proto.MT_CREATE_ENTITY_ANYWHERE {
 			dcp.owner.handleCreateEntityAnywhere(dcp, pkt)
 		} else if msgtype == proto.MT_DECLARE_SERVICE {
@@ -22,7 +22,16 @@ import (
 )
 
 type callQueueItem struct {
-	packet *netutil.Packet
+	packet     *netutil.Packet
+	clientid   common.ClientID // client given to the entity by the packet, if not empty
+	sourceGame uint16          // game which sends the packet
+	requestID  uint32          // request ID of call with reply, 0 if no reply is expected
+}
+
+// createEntityWaiter is a game waiting for the entity to be created or loaded
+type createEntityWaiter struct {
+	gameid    uint16
+	requestID uint32
 }
 
 type entityDispatchInfo struct {
@@ -31,6 +40,7 @@ type entityDispatchInfo struct {
 	gameid             uint16
 	blockUntilTime     time.Time
 	pendingPacketQueue *xnsyncutil.SyncQueue
+	createWaiters      []createEntityWaiter
 }
 
 func newEntityDispatchInfo() *entityDispatchInfo {
@@ -323,6 +333,76 @@ func (service *DispatcherService) handleNotifyCreateEntity(dcp *dispatcherClient
 		entityDispatchInfo.blockUntilTime = time.Time{}
 		service.sendPendingPackets(entityDispatchInfo)
 	}
+	service.replyCreateWaiters(entityID, entityDispatchInfo, proto.CREATE_ENTITY_OK, "")
+}
+
+// Entity is failed to create or load on the target game
+func (service *DispatcherService) handleNotifyCreateEntityFailed(dcp *dispatcherClientProxy, pkt *netutil.Packet, entityID common.EntityID) {
+	errcode := pkt.ReadUint16()
+	errmsg := pkt.ReadVarStr()
+	if consts.DEBUG_PACKETS {
+		gwlog.Debugf("%s.handleNotifyCreateEntityFailed: dcp=%s, entityID=%s, error=%d %s", service, dcp, entityID, errcode, errmsg)
+	}
+
+	entityDispatchInfo := service.getEntityDispatcherInfoForWrite(entityID)
+	if entityDispatchInfo == nil {
+		return
+	}
+
+	if entityDispatchInfo.gameid != dcp.gameid {
+		// entity is created on other game, ignore the failure
+		entityDispatchInfo.Unlock()
+		return
+	}
+
+	service.replyCreateWaiters(entityID, entityDispatchInfo, errcode, errmsg)
+	service.rejectPendingPackets(entityID, entityDispatchInfo, errmsg)
+	entityDispatchInfo.Unlock()
+	service.delEntityDispatchInfo(entityID)
+}
+
+// rejectPendingPackets handles the cached packets of entity which is failed to create or load
+//
+// Clients are sent back to the source games, calls with reply are replied with the error, and other calls are dropped
+func (service *DispatcherService) rejectPendingPackets(entityID common.EntityID, entityDispatchInfo *entityDispatchInfo, errmsg string) {
+	item, ok := entityDispatchInfo.pendingPacketQueue.TryPop()
+	for ok {
+		queueItem := item.(callQueueItem)
+		sourceDcp := service.dispatcherClientOfGame(queueItem.sourceGame)
+		if sourceDcp != nil && queueItem.clientid != "" {
+			gwlog.Warnf("%s: entity %s is failed to create, client %s is sent back to game %d", service, entityID, queueItem.clientid, queueItem.sourceGame)
+			sourceDcp.SendPacket(queueItem.packet)
+		} else if sourceDcp != nil && queueItem.requestID != 0 {
+			service.replyCallEntityMethodError(sourceDcp, queueItem.requestID, fmt.Sprintf("entity %s is failed to create: %s", entityID, errmsg))
+		}
+		queueItem.packet.Release()
+
+		item, ok = entityDispatchInfo.pendingPacketQueue.TryPop()
+	}
+}
+
+// replyCreateWaiters tells the waiting games that the entity is created or loaded
+func (service *DispatcherService) replyCreateWaiters(entityID common.EntityID, entityDispatchInfo *entityDispatchInfo, errcode uint16, errmsg string) {
+	if len(entityDispatchInfo.createWaiters) == 0 {
+		return
+	}
+
+	for _, waiter := range entityDispatchInfo.createWaiters {
+		service.replyCreateEntityAnywhere(waiter.gameid, waiter.requestID, entityID, entityDispatchInfo.gameid, errcode, errmsg)
+	}
+	entityDispatchInfo.createWaiters = nil
+}
+
+func (service *DispatcherService) replyCreateEntityAnywhere(callerGame uint16, requestID uint32, entityID common.EntityID, gameid uint16, errcode uint16, errmsg string) {
+	callerDcp := service.dispatcherClientOfGame(callerGame)
+	if callerDcp == nil {
+		return
+	}
+
+	pkt := netutil.NewPacket()
+	proto.AppendCreateEntityAnywhereReply(pkt, requestID, entityID, gameid, errcode, errmsg)
+	callerDcp.SendPacket(pkt)
+	pkt.Release()
 }
 
 func (service *DispatcherService) handleNotifyDestroyEntity(dcp *dispatcherClientProxy, pkt *netutil.Packet, entityID common.EntityID) {
@@ -372,23 +452,69 @@ func (service *DispatcherService) handleLoadEntityAnywhere(dcp *dispatcherClient
 		gwlog.Debugf("%s.handleLoadEntityAnywhere: dcp=%s, pkt=%v", service, dcp, pkt.Payload())
 	}
 	eid := pkt.ReadEntityID() // field 1
+	_ = pkt.ReadVarStr()      // typeName
+	requestID := pkt.ReadUint32()
 
 	entityDispatchInfo := service.setEntityDispatcherInfoForWrite(eid)
 	defer entityDispatchInfo.Unlock()
 
 	if entityDispatchInfo.gameid == 0 { // entity not loaded, try load now
-		dcp := service.chooseGameDispatcherClient()
-		entityDispatchInfo.gameid = dcp.gameid
+		targetDcp := service.chooseGameDispatcherClient()
+		entityDispatchInfo.gameid = targetDcp.gameid
 		entityDispatchInfo.blockRPC(consts.DISPATCHER_LOAD_TIMEOUT)
-		dcp.SendPacket(pkt)
+		targetDcp.SendPacket(pkt)
+	}
+
+	if requestID != 0 {
+		if entityDispatchInfo.isBlockingRPC() {
+			// entity is loading or migrating, reply when it is done
+			entityDispatchInfo.createWaiters = append(entityDispatchInfo.createWaiters, createEntityWaiter{dcp.gameid, requestID})
+		} else {
+			service.replyCreateEntityAnywhere(dcp.gameid, requestID, eid, entityDispatchInfo.gameid, proto.CREATE_ENTITY_OK, "")
+		}
 	}
 }
 
+// handleLoadEntityInSpace loads the entity in the space of the source game, if the entity is not on any game
+func (service *DispatcherService) handleLoadEntityInSpace(dcp *dispatcherClientProxy, pkt *netutil.Packet) {
+	if consts.DEBUG_PACKETS {
+		gwlog.Debugf("%s.handleLoadEntityInSpace: dcp=%s, pkt=%v", service, dcp, pkt.Payload())
+	}
+	eid := pkt.ReadEntityID()
+
+	entityDispatchInfo := service.setEntityDispatcherInfoForWrite(eid)
+	defer entityDispatchInfo.Unlock()
+
+	if entityDispatchInfo.gameid != 0 {
+		// entity is loaded or being loaded, do not load another one
+		gwlog.Infof("%s.handleLoadEntityInSpace: entity %s is already on game %d", service, eid, entityDispatchInfo.gameid)
+		return
+	}
+
+	entityDispatchInfo.gameid = dcp.gameid
+	entityDispatchInfo.blockRPC(consts.DISPATCHER_LOAD_TIMEOUT)
+	dcp.SendPacket(pkt)
+}
+
 func (service *DispatcherService) handleCreateEntityAnywhere(dcp *dispatcherClientProxy, pkt *netutil.Packet) {
 	if consts.DEBUG_PACKETS {
 		gwlog.Debugf("%s.handleCreateEntityAnywhere: dcp=%s, pkt=%s", service, dcp, pkt.Payload())
 	}
-	service.chooseGameDispatcherClient().SendPacket(pkt)
+	eid := pkt.ReadEntityID()
+	_ = pkt.ReadVarStr() // typeName
+	requestID := pkt.ReadUint32()
+
+	targetDcp := service.chooseGameDispatcherClient()
+	entityDispatchInfo := service.setEntityDispatcherInfoForWrite(eid)
+	defer entityDispatchInfo.Unlock()
+
+	// block calls to the entity until it is created
+	entityDispatchInfo.gameid = targetDcp.gameid
+	entityDispatchInfo.blockRPC(consts.DISPATCHER_LOAD_TIMEOUT)
+	if requestID != 0 {
+		entityDispatchInfo.createWaiters = append(entityDispatchInfo.createWaiters, createEntityWaiter{dcp.gameid, requestID})
+	}
+	targetDcp.SendPacket(pkt)
 }
 
 func (service *DispatcherService) handleDeclareService(dcp *dispatcherClientProxy, pkt *netutil.Packet) {
@@ -452,6 +578,65 @@ func (service *DispatcherService) handleCallEntityMethod(dcp *dispatcherClientPr
 	}
 }
 
+func (service *DispatcherService) handleCallEntityMethodWithReply(dcp *dispatcherClientProxy, pkt *netutil.Packet) {
+	entityID := pkt.ReadEntityID()
+	requestID := pkt.ReadUint32()
+
+	if consts.DEBUG_PACKETS {
+		gwlog.Debugf("%s.handleCallEntityMethodWithReply: dcp=%s, entityID=%s, requestID=%d", service, dcp, entityID, requestID)
+	}
+
+	entityDispatchInfo := service.getEntityDispatcherInfoForRead(entityID)
+	if entityDispatchInfo == nil {
+		// entity not exists, reply the caller immediately
+		service.replyCallEntityMethodError(dcp, requestID, fmt.Sprintf("entity %s not found", entityID))
+		return
+	}
+
+	defer entityDispatchInfo.RUnlock()
+
+	pkt.AppendUint16(dcp.gameid) // append the caller game so that the reply can be routed back
+	if !entityDispatchInfo.isBlockingRPC() {
+		targetDcp := service.dispatcherClientOfGame(entityDispatchInfo.gameid)
+		if targetDcp == nil {
+			service.replyCallEntityMethodError(dcp, requestID, fmt.Sprintf("game %d of entity %s is not connected", entityDispatchInfo.gameid, entityID))
+			return
+		}
+		targetDcp.SendPacket(pkt)
+	} else {
+		// if migrating, just put the call to wait
+		if entityDispatchInfo.pendingPacketQueue.Len() < consts.ENTITY_PENDING_PACKET_QUEUE_MAX_LEN {
+			pkt.AddRefCount(1)
+			entityDispatchInfo.pendingPacketQueue.Push(callQueueItem{
+				packet:     pkt,
+				sourceGame: dcp.gameid,
+				requestID:  requestID,
+			})
+		} else {
+			gwlog.Errorf("%s.handleCallEntityMethodWithReply %s: packet queue too long, packet dropped", service, entityID)
+			service.replyCallEntityMethodError(dcp, requestID, fmt.Sprintf("entity %s is busy", entityID))
+		}
+	}
+}
+
+func (service *DispatcherService) handleCallEntityMethodReply(dcp *dispatcherClientProxy, pkt *netutil.Packet) {
+	callerGame := pkt.ReadUint16()
+	callerDcp := service.dispatcherClientOfGame(callerGame)
+	if callerDcp == nil {
+		// caller game is gone, the reply is useless
+		gwlog.Warnf("%s.handleCallEntityMethodReply: caller game %d is not connected, reply dropped", service, callerGame)
+		return
+	}
+	callerDcp.SendPacket(pkt)
+}
+
+func (service *DispatcherService) replyCallEntityMethodError(dcp *dispatcherClientProxy, requestID uint32, errmsg string) {
+	pkt := netutil.NewPacket()
+	proto.AppendCallEntityMethodReply(pkt, dcp.gameid, requestID, errmsg, nil)
+	dcp.SendPacket(pkt)
+	pkt.Release()
+}
+
 func (service *DispatcherService) handleSyncPositionYawOnClients(dcp *dispatcherClientProxy, pkt *netutil.Packet) {
 	gateid := pkt.ReadUint16()
 	service.dispatcherClientOfGate(gateid).SendPacket(pkt)
@@ -584,6 +769,66 @@ func (service *DispatcherService) handleRealMigrate(dcp *dispatcherClientProxy,
 	service.dispatcherClientOfGame(targetGame).SendPacket(pkt)
 	// send the cached calls to target game
 	service.sendPendingPackets(entityDispatchInfo)
+	service.replyCreateWaiters(eid, entityDispatchInfo, proto.CREATE_ENTITY_OK, "")
+}
+
+func (service *DispatcherService) handleGiveClientToEntity(dcp *dispatcherClientProxy, pkt *netutil.Packet) {
+	targetID := pkt.ReadEntityID()
+	sourceID := pkt.ReadEntityID()
+	_ = pkt.ReadUint16() // gate of client
+	clientid := pkt.ReadClientID()
+
+	if consts.DEBUG_CLIENTS {
+		gwlog.Debugf("%s.handleGiveClientToEntity: client %s is given from %s to %s", service, clientid, sourceID, targetID)
+	}
+
+	entityDispatchInfo := service.getEntityDispatcherInfoForRead(targetID)
+	if entityDispatchInfo == nil {
+		// target entity not found, send back to the source game to give the client back
+		gwlog.Warnf("%s.handleGiveClientToEntity: entity %s not found, client %s is sent back to game %d", service, targetID, clientid, dcp.gameid)
+		dcp.SendPacket(pkt)
+		return
+	}
+
+	defer entityDispatchInfo.RUnlock()
+
+	if !entityDispatchInfo.isBlockingRPC() {
+		if service.setTargetGameOfClient(clientid, entityDispatchInfo.gameid) {
+			service.dispatcherClientOfGame(entityDispatchInfo.gameid).SendPacket(pkt)
+		}
+	} else {
+		// if loading or migrating, give the client after the entity is ready
+		if entityDispatchInfo.pendingPacketQueue.Len() < consts.ENTITY_PENDING_PACKET_QUEUE_MAX_LEN {
+			pkt.AddRefCount(1)
+			entityDispatchInfo.pendingPacketQueue.Push(callQueueItem{
+				packet:     pkt,
+				clientid:   clientid,
+				sourceGame: dcp.gameid,
+			})
+		} else {
+			gwlog.Errorf("%s.handleGiveClientToEntity %s: packet queue too long, client %s is sent back to game %d", service, targetID, clientid, dcp.gameid)
+			dcp.SendPacket(pkt)
+		}
+	}
+}
+
+// setTargetGameOfClient changes the target game of client if the client is still connected
+func (service *DispatcherService) setTargetGameOfClient(clientid common.ClientID, targetGame uint16) bool {
+	service.clientsLock.Lock()
+	defer service.clientsLock.Unlock()
+
+	if _, ok := service.targetGameOfClient[clientid]; !ok {
+		if consts.DEBUG_CLIENTS {
+			gwlog.Debugf("Client %s is disconnected, can not be given to game %v", clientid, targetGame)
+		}
+		return false
+	}
+
+	service.targetGameOfClient[clientid] = targetGame
+	if consts.DEBUG_CLIENTS {
+		gwlog.Debugf("Target game of client %s is SET to %v on given", clientid, targetGame)
+	}
+	return true
 }
 
 func (service *DispatcherService) sendPendingPackets(entityDispatchInfo *entityDispatchInfo) {
@@ -592,7 +837,10 @@ func (service *DispatcherService) sendPendingPackets(entityDispatchInfo *entityD
 	item, ok := entityDispatchInfo.pendingPacketQueue.TryPop()
 	for ok {
 		cachedPkt := item.(callQueueItem).packet
-		service.dispatcherClientOfGame(targetGame).SendPacket(cachedPkt)
+		clientid := item.(callQueueItem).clientid
+		if clientid == "" || service.setTargetGameOfClient(clientid, targetGame) {
+			service.dispatcherClientOfGame(targetGame).SendPacket(cachedPkt)
+		}
 		cachedPkt.Release()
 
 		item, ok = entityDispatchInfo.pendingPacketQueue.TryPop()
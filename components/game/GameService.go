@@ -107,6 +107,26 @@ func (gs *_GameService) serveRoutine() {
 				method := pkt.ReadVarStr()
 				args := pkt.ReadArgs()
 				gs.HandleCallEntityMethod(eid, method, args, "")
+			} else if msgtype == proto.MT_CALL_ENTITY_METHOD_WITH_REPLY {
+				eid := pkt.ReadEntityID()
+				requestID := pkt.ReadUint32()
+				method := pkt.ReadVarStr()
+				args := pkt.ReadArgs()
+				callerGame := pkt.ReadUint16()
+				gs.HandleCallEntityMethodWithReply(eid, method, args, callerGame, requestID)
+			} else if msgtype == proto.MT_CALL_ENTITY_METHOD_REPLY {
+				_ = pkt.ReadUint16() // caller game is not useful
+				requestID := pkt.ReadUint32()
+				errmsg := pkt.ReadVarStr()
+				var results []interface{}
+				pkt.ReadData(&results)
+				gs.HandleCallEntityMethodReply(requestID, errmsg, results)
+			} else if msgtype == proto.MT_GIVE_CLIENT_TO_ENTITY {
+				targetID := pkt.ReadEntityID()
+				sourceID := pkt.ReadEntityID()
+				gid := pkt.ReadUint16()
+				clientid := pkt.ReadClientID()
+				gs.HandleGiveClientToEntity(targetID, sourceID, clientid, gid)
 			} else if msgtype == proto.MT_MIGRATE_REQUEST { // migrate request sent to dispatcher is sent back
 				gs.HandleMigrateRequestAck(pkt)
 			} else if msgtype == proto.MT_REAL_MIGRATE {
@@ -122,11 +142,28 @@ func (gs *_GameService) serveRoutine() {
 				eid := pkt.ReadEntityID()
 				typeName := pkt.ReadVarStr()
 				gs.HandleLoadEntityAnywhere(typeName, eid)
+			} else if msgtype == proto.MT_LOAD_ENTITY_IN_SPACE {
+				eid := pkt.ReadEntityID()
+				typeName := pkt.ReadVarStr()
+				spaceID := pkt.ReadEntityID()
+				x := pkt.ReadFloat32()
+				y := pkt.ReadFloat32()
+				z := pkt.ReadFloat32()
+				gs.HandleLoadEntityInSpace(typeName, eid, spaceID, x, y, z)
 			} else if msgtype == proto.MT_CREATE_ENTITY_ANYWHERE {
+				eid := pkt.ReadEntityID()
 				typeName := pkt.ReadVarStr()
+				_ = pkt.ReadUint32() // request ID is handled by dispatcher
 				var data map[string]interface{}
 				pkt.ReadData(&data)
-				gs.HandleCreateEntityAnywhere(typeName, data)
+				gs.HandleCreateEntityAnywhere(typeName, eid, data)
+			} else if msgtype == proto.MT_CREATE_ENTITY_ANYWHERE_REPLY {
+				requestID := pkt.ReadUint32()
+				eid := pkt.ReadEntityID()
+				gid := pkt.ReadUint16()
+				errcode := pkt.ReadUint16()
+				errmsg := pkt.ReadVarStr()
+				gs.HandleCreateEntityAnywhereReply(requestID, eid, gid, errcode, errmsg)
 			} else if msgtype == proto.MT_DECLARE_SERVICE {
 				eid := pkt.ReadEntityID()
 				serviceName := pkt.ReadVarStr()
@@ -162,6 +199,8 @@ func (gs *_GameService) serveRoutine() {
 			}
 
 			timer.Tick()
+			entity.TickMovements()
+			entity.TickPartitionedSpaces()
 
 			//case <-gs.collectEntitySyncInfosRequest: //
 			//	gs.collectEntitySycnInfosReply <- 1
@@ -280,11 +319,18 @@ func (gs *_GameService) String() string {
 	return fmt.Sprintf("_GameService<%d>", gs.id)
 }
 
-func (gs *_GameService) HandleCreateEntityAnywhere(typeName string, data map[string]interface{}) {
+func (gs *_GameService) HandleCreateEntityAnywhere(typeName string, entityID common.EntityID, data map[string]interface{}) {
+	if consts.DEBUG_PACKETS {
+		gwlog.Debugf("%s.handleCreateEntityAnywhere: typeName=%s, entityID=%s, data=%v", gs, typeName, entityID, data)
+	}
+	entity.OnCreateEntityAnywhere(typeName, entityID, data)
+}
+
+func (gs *_GameService) HandleCreateEntityAnywhereReply(requestID uint32, entityID common.EntityID, gid uint16, errcode uint16, errmsg string) {
 	if consts.DEBUG_PACKETS {
-		gwlog.Debugf("%s.handleCreateEntityAnywhere: typeName=%s, data=%v", gs, typeName, data)
+		gwlog.Debugf("%s.handleCreateEntityAnywhereReply: requestID=%d, entityID=%s, game=%d, errcode=%d, errmsg=%s", gs, requestID, entityID, gid, errcode, errmsg)
 	}
-	entity.CreateEntityLocally(typeName, data, nil)
+	entity.OnCreateEntityAnywhereReply(requestID, entityID, gid, errcode, errmsg)
 }
 
 func (gs *_GameService) HandleLoadEntityAnywhere(typeName string, entityID common.EntityID) {
@@ -294,6 +340,13 @@ func (gs *_GameService) HandleLoadEntityAnywhere(typeName string, entityID commo
 	entity.LoadEntityLocally(typeName, entityID)
 }
 
+func (gs *_GameService) HandleLoadEntityInSpace(typeName string, entityID common.EntityID, spaceID common.EntityID, x, y, z float32) {
+	if consts.DEBUG_PACKETS {
+		gwlog.Debugf("%s.handleLoadEntityInSpace: typeName=%s, entityID=%s, spaceID=%s, pos=(%v, %v, %v)", gs, typeName, entityID, spaceID, x, y, z)
+	}
+	entity.OnLoadEntityInSpace(typeName, entityID, spaceID, entity.Vector3{X: entity.Coord(x), Y: entity.Coord(y), Z: entity.Coord(z)})
+}
+
 func (gs *_GameService) HandleDeclareService(entityID common.EntityID, serviceName string) {
 	// tell the entity that it is registered successfully
 	if consts.DEBUG_PACKETS {
@@ -313,6 +366,7 @@ func (gs *_GameService) HandleUndeclareService(entityID common.EntityID, service
 func (gs *_GameService) HandleNotifyAllGamesConnected() {
 	// all games are connected
 	gwlog.Infof("All games connected.")
+	entity.OnGameReady(gameid)
 	gs.gameDelegate.OnGameReady()
 }
 
@@ -346,6 +400,20 @@ func (gs *_GameService) HandleCallEntityMethod(entityID common.EntityID, method
 	entity.OnCall(entityID, method, args, clientid)
 }
 
+func (gs *_GameService) HandleCallEntityMethodWithReply(entityID common.EntityID, method string, args [][]byte, callerGame uint16, requestID uint32) {
+	if consts.DEBUG_PACKETS {
+		gwlog.Debugf("%s.handleCallEntityMethodWithReply: %s.%s(%v), callerGame=%d, requestID=%d", gs, entityID, method, args, callerGame, requestID)
+	}
+	entity.OnCallWithReply(entityID, method, args, callerGame, requestID)
+}
+
+func (gs *_GameService) HandleCallEntityMethodReply(requestID uint32, errmsg string, results []interface{}) {
+	if consts.DEBUG_PACKETS {
+		gwlog.Debugf("%s.handleCallEntityMethodReply: requestID=%d, errmsg=%s, results=%v", gs, requestID, errmsg, results)
+	}
+	entity.OnCallReply(requestID, errmsg, results)
+}
+
 func (gs *_GameService) HandleNotifyClientConnected(clientid common.ClientID, gid uint16) {
 	client := entity.MakeGameClient(clientid, gid)
 	if consts.DEBUG_PACKETS {
@@ -364,6 +432,18 @@ func (gs *_GameService) HandleNotifyClientDisconnected(clientid common.ClientID)
 	entity.OnClientDisconnected(clientid)
 }
 
+func (gs *_GameService) HandleGiveClientToEntity(targetID common.EntityID, sourceID common.EntityID, clientid common.ClientID, gid uint16) {
+	client := entity.MakeGameClient(clientid, gid)
+	if consts.DEBUG_CLIENTS {
+		gwlog.Debugf("%s.handleGiveClientToEntity: %s is given from %s to %s", gs, client, sourceID, targetID)
+	}
+
+	if !entity.OnGiveClientToEntity(targetID, sourceID, client) {
+		// no entity can take the client, create a boot entity as if the client is newly connected
+		entity.CreateEntityLocally(gs.config.BootEntity, nil, client)
+	}
+}
+
 func (gs *_GameService) HandleMigrateRequestAck(pkt *netutil.Packet) {
 	eid := pkt.ReadEntityID()
 	spaceid := pkt.ReadEntityID()
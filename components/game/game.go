@@ -37,6 +37,7 @@ var (
 	logLevel                     string
 	restore                      bool
 	runInDaemonMode              bool
+	genSchemaFile                string
 	gameService                  *_GameService
 	signalChan                   = make(chan os.Signal, 1)
 	gameDispatcherClientDelegate = &dispatcherClientDelegate{}
@@ -49,6 +50,7 @@ func parseArgs() {
 	flag.StringVar(&logLevel, "log", "", "set log level, will override log level in config")
 	flag.BoolVar(&restore, "restore", false, "restore from freezed state")
 	flag.BoolVar(&runInDaemonMode, "d", false, "run in daemon mode")
+	flag.StringVar(&genSchemaFile, "gen-schema", "", "write schemas of registered entity types to file and quit")
 	flag.Parse()
 	gameid = uint16(gameidArg)
 }
@@ -60,6 +62,12 @@ func Run(delegate IGameDelegate) {
 	rand.Seed(time.Now().UnixNano())
 	parseArgs()
 
+	if genSchemaFile != "" {
+		// entity types are registered before Run, so the schemas are ready now
+		genSchema(genSchemaFile)
+		return
+	}
+
 	if runInDaemonMode {
 		daemoncontext := binutil.Daemonize()
 		defer daemoncontext.Release()
@@ -106,6 +114,18 @@ func Run(delegate IGameDelegate) {
 	gameService.run(restore)
 }
 
+func genSchema(filename string) {
+	f, err := os.Create(filename)
+	if err != nil {
+		gwlog.Fatalf("create schema file %s failed: %s", filename, err)
+	}
+	defer f.Close()
+
+	if err = entity.DumpEntityTypeSchemas(f); err != nil {
+		gwlog.Fatalf("write schema file %s failed: %s", filename, err)
+	}
+}
+
 func setupSignals() {
 	gwlog.Infof("Setup signals ...")
 	signal.Ignore(syscall.Signal(12), syscall.SIGPIPE)
@@ -221,10 +241,14 @@ func (delegate *dispatcherClientDelegate) HandleDispatcherClientDisconnect() {
 }
 
 func (delegate *dispatcherClientDelegate) HandleDispatcherClientBeforeFlush() {
+	// send batched attribute changes of entities
+	entity.FlushAttrDeltas()
+
 	// collect all sync infos from entities and group them by target gates
 	now := time.Now()
 	if now.Sub(delegate.lastCollectEntitySyncInfosTime) >= time.Millisecond*100 {
 		delegate.lastCollectEntitySyncInfosTime = now
+		entity.UpdateVisibleNeighbors()
 		entity.CollectEntitySyncInfos()
 	}
 }
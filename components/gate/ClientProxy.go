@@ -34,6 +34,7 @@ type ClientProxy struct {
 	filterProps    map[string]string
 	clientSyncInfo clientSyncInfo
 	heartbeatTime  xnsyncutil.AtomicInt64
+	compactSync    *proto.CompactSyncEncoder // encoder of entity sync infos, nil if client does not use compact sync encoding
 }
 
 func newClientProxy(conn netutil.Connection, cfg *config.GateConfig) *ClientProxy {
@@ -89,6 +90,8 @@ func (cp *ClientProxy) serve() {
 			} else if msgtype == proto.MT_HEARTBEAT_FROM_CLIENT {
 				// kcp connected from client, need to do nothing here
 
+			} else if msgtype == proto.MT_SET_COMPACT_SYNC_FROM_CLIENT {
+				cp.handleSetCompactSyncFromClient(pkt)
 			} else {
 				if consts.DEBUG_MODE {
 					gwlog.TraceError("unknown message type from client: %d", msgtype)
@@ -114,7 +117,38 @@ func (cp *ClientProxy) handleSyncPositionYawFromClient(pkt *netutil.Packet) {
 	gateService.handleSyncPositionYawFromClient(pkt)
 }
 
+func (cp *ClientProxy) handleSetCompactSyncFromClient(pkt *netutil.Packet) {
+	// compact sync encoder is used by the packet routine of gate service, so let it handle the request
+	pkt.AppendClientID(cp.clientid)
+	pkt.AddRefCount(1)
+	gateService.packetQueue.Push(packetQueueItem{
+		msgtype: proto.MT_SET_COMPACT_SYNC_FROM_CLIENT,
+		packet:  pkt,
+	})
+}
+
 func (cp *ClientProxy) handleCallEntityMethodFromClient(pkt *netutil.Packet) {
 	pkt.AppendClientID(cp.clientid) // append clientid to the packet
 	dispatcherclient.GetDispatcherClientForSend().SendPacket(pkt)
 }
+
+// sendCompactSyncPositionYaw sends entity sync infos to client in compact sync encoding
+//
+// data is entity sync infos of EntityID and EntitySyncInfo
+func (cp *ClientProxy) sendCompactSyncPositionYaw(data []byte) {
+	packet := netutil.NewPacket()
+	packet.AppendUint16(proto.MT_SYNC_POSITION_YAW_COMPACT_ON_CLIENT)
+	for i := 0; i < len(data); i += common.ENTITYID_LENGTH + proto.SYNC_INFO_SIZE_PER_ENTITY {
+		entityID := common.EntityID(data[i : i+common.ENTITYID_LENGTH])
+		info := data[i+common.ENTITYID_LENGTH : i+common.ENTITYID_LENGTH+proto.SYNC_INFO_SIZE_PER_ENTITY]
+		cp.compactSync.Encode(packet, entityID, proto.EntitySyncInfo{
+			X:   netutil.UnpackFloat32(netutil.NETWORK_ENDIAN, info[0:]),
+			Y:   netutil.UnpackFloat32(netutil.NETWORK_ENDIAN, info[4:]),
+			Z:   netutil.UnpackFloat32(netutil.NETWORK_ENDIAN, info[8:]),
+			Yaw: netutil.UnpackFloat32(netutil.NETWORK_ENDIAN, info[12:]),
+		})
+	}
+	packet.SetNotCompress()
+	cp.SendPacket(packet)
+	packet.Release()
+}
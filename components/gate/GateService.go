@@ -248,6 +248,10 @@ func (gs *GateService) HandleDispatcherClientPacket(msgtype proto.MsgType, packe
 			} else if msgtype == proto.MT_CLEAR_CLIENTPROXY_FILTER_PROPS {
 				gs.handleClearClientFilterProps(clientproxy, packet)
 			} else {
+				if msgtype == proto.MT_DESTROY_ENTITY_ON_CLIENT && clientproxy.compactSync != nil {
+					_ = packet.ReadVarStr() // typeName
+					clientproxy.compactSync.Remove(packet.ReadEntityID())
+				}
 				// message types that should be redirected to client proxy
 				clientproxy.SendPacket(packet)
 			}
@@ -259,6 +263,8 @@ func (gs *GateService) HandleDispatcherClientPacket(msgtype proto.MsgType, packe
 		gs.handleSyncPositionYawOnClients(packet)
 	} else if msgtype == proto.MT_CALL_FILTERED_CLIENTS {
 		gs.handleCallFilteredClientProxies(packet)
+	} else if msgtype == proto.MT_SET_COMPACT_SYNC_FROM_CLIENT {
+		gs.handleSetCompactSyncFromClient(packet)
 	} else {
 		gwlog.Panicf("%s: unknown msg type: %d", gs, msgtype)
 		if consts.DEBUG_MODE {
@@ -333,7 +339,9 @@ func (gs *GateService) handleSyncPositionYawOnClients(packet *netutil.Packet) {
 
 	for clientid, data := range dispatch {
 		clientproxy := gs.clientProxies[clientid]
-		if clientproxy != nil {
+		if clientproxy != nil && clientproxy.compactSync != nil {
+			clientproxy.sendCompactSyncPositionYaw(data)
+		} else if clientproxy != nil {
 			packet := netutil.NewPacket()
 			packet.AppendUint16(proto.MT_SYNC_POSITION_YAW_ON_CLIENTS)
 			packet.AppendBytes(data)
@@ -346,6 +354,26 @@ func (gs *GateService) handleSyncPositionYawOnClients(packet *netutil.Packet) {
 	gs.clientProxiesLock.RUnlock()
 }
 
+func (gs *GateService) handleSetCompactSyncFromClient(packet *netutil.Packet) {
+	enable := packet.ReadBool()
+	clientid := packet.ReadClientID()
+
+	gs.clientProxiesLock.RLock()
+	clientproxy := gs.clientProxies[clientid]
+	gs.clientProxiesLock.RUnlock()
+	if clientproxy == nil {
+		return
+	}
+
+	enable = enable && config.GetGate(gateid).CompactSync
+	if enable && clientproxy.compactSync == nil {
+		clientproxy.compactSync = proto.NewCompactSyncEncoder()
+	} else if !enable {
+		clientproxy.compactSync = nil
+	}
+	clientproxy.SendSetCompactSyncOnClient(enable)
+}
+
 func (gs *GateService) handleCallFilteredClientProxies(packet *netutil.Packet) {
 	key := packet.ReadVarStr()
 	val := packet.ReadVarStr()
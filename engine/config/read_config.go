@@ -68,6 +68,7 @@ type GateConfig struct {
 	RSAKey                 string
 	RSACertificate         string
 	HeartbeatCheckInterval int
+	CompactSync            bool
 }
 
 // DispatcherConfig defines fields of dispatcher config
@@ -371,6 +372,8 @@ func _readGateConfig(sec *ini.Section, sc *GateConfig) {
 			sc.RSACertificate = key.MustString(sc.RSACertificate)
 		} else if name == "heartbeat_check_interval" {
 			sc.HeartbeatCheckInterval = key.MustInt(sc.HeartbeatCheckInterval)
+		} else if name == "compact_sync" {
+			sc.CompactSync = key.MustBool(sc.CompactSync)
 		} else {
 			gwlog.Panicf("section %s has unknown key: %s", sec.Name(), key.Name())
 		}
@@ -64,6 +64,10 @@ const (
 	DISPATCHER_LOAD_TIMEOUT = time.Minute * 5
 	// DISPATCHER_FREEZE_GAME_TIMEOUT is timeout for freezing & restoring game
 	DISPATCHER_FREEZE_GAME_TIMEOUT = time.Minute * 5
+	// CALL_ENTITY_METHOD_REPLY_TIMEOUT is timeout for waiting the reply of entity method calls
+	CALL_ENTITY_METHOD_REPLY_TIMEOUT = time.Second * 30
+	// CREATE_ENTITY_ANYWHERE_TIMEOUT is timeout for waiting the reply of creating or loading entities anywhere
+	CREATE_ENTITY_ANYWHERE_TIMEOUT = time.Second * 30
 	// For Storage
 	// For Operation Monitor
 	// OPMON_DUMP_INTERVAL is the interval to print opmon infos to output
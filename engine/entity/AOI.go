@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"math"
 	"unsafe"
+
+	"github.com/lovelly/goworld/engine/gwlog"
 )
 
 // Coord is the of coordinations entity position (x, y, z)
@@ -77,18 +79,41 @@ func (p Vector3) Normalized() Vector3 {
 	return p
 }
 
+// SetAOIDistance sets the aoi distance of entities of this type
+//
+// Entities of this type are interested in other entities within the distance, no matter what the aoi distance of the space is.
+// Interests can be asymmetric: entities with larger aoi distance see entities with smaller aoi distance from farther.
+func (desc *EntityTypeDesc) SetAOIDistance(distance Coord) *EntityTypeDesc {
+	if distance <= 0 {
+		gwlog.Panicf("SetAOIDistance: invalid distance %v", distance)
+	}
+	desc.aoiDistance = distance
+	return desc
+}
+
 type aoi struct {
-	pos       Vector3
-	neighbors EntitySet
-	xNext     *aoi
-	xPrev     *aoi
-	zNext     *aoi
-	zPrev     *aoi
-	markVal   int
+	pos          Vector3
+	dist         Coord     // entities within the distance are interested
+	height       Coord     // entities within the height on Y axis are interested if height > 0
+	neighbors    EntitySet // entities interested by this entity
+	interestedBy EntitySet // entities interested in this entity
+	visible      EntitySet // neighbors visible to the client of this entity
+	visibleBy    EntitySet // entities whose clients can see this entity
+	maxVisible   int       // max count of visible neighbors, 0 means unlimited
+	priority     NeighborPriorityFunc
+	xNext        *aoi
+	xPrev        *aoi
+	zNext        *aoi
+	zPrev        *aoi
+	markVal      int
+	gridCell     gridCell // cell of aoi in GridAOICalculator
 }
 
 func initAOI(aoi *aoi) {
 	aoi.neighbors = EntitySet{}
+	aoi.interestedBy = EntitySet{}
+	aoi.visible = EntitySet{}
+	aoi.visibleBy = EntitySet{}
 }
 
 // Get the owner entity of this aoi
@@ -105,10 +130,88 @@ func (aoi *aoi) getEntity() *Entity {
 
 func (aoi *aoi) interest(other *Entity) {
 	aoi.neighbors.Add(other)
+	other.aoi.interestedBy.Add(aoi.getEntity())
 }
 
 func (aoi *aoi) uninterest(other *Entity) {
 	aoi.neighbors.Del(other)
+	other.aoi.interestedBy.Del(aoi.getEntity())
+}
+
+// canShow returns if one more neighbor can be visible without exceeding max visible neighbors
+func (aoi *aoi) canShow() bool {
+	return aoi.maxVisible <= 0 || len(aoi.visible) < aoi.maxVisible
+}
+
+func (aoi *aoi) show(other *Entity) {
+	aoi.visible.Add(other)
+	other.aoi.visibleBy.Add(aoi.getEntity())
+}
+
+func (aoi *aoi) hide(other *Entity) {
+	aoi.visible.Del(other)
+	other.aoi.visibleBy.Del(aoi.getEntity())
+}
+
+// isInRange returns if the position is within the interest distance (and height, if set) of aoi
+func (aoi *aoi) isInRange(pos Vector3) bool {
+	dx, dz := pos.X-aoi.pos.X, pos.Z-aoi.pos.Z
+	if dx < -aoi.dist || dx > aoi.dist || dz < -aoi.dist || dz > aoi.dist {
+		return false
+	}
+	if aoi.height > 0 {
+		dy := pos.Y - aoi.pos.Y
+		return dy >= -aoi.height && dy <= aoi.height
+	}
+	return true
+}
+
+// adjustAOI calculates the changes of interests of a after it enters or moves
+//
+// enter and leave are aois which a starts and stops being interested in,
+// enterBy and leaveBy are aois which start and stop being interested in a.
+// maxDist is the max interest distance of all aois in the calculator
+func adjustAOI(cal AOIVisitor, a *aoi, maxDist Coord) (enter, leave, enterBy, leaveBy []*aoi) {
+	for neighbor := range a.neighbors {
+		if !a.isInRange(neighbor.aoi.pos) {
+			leave = append(leave, &neighbor.aoi)
+		}
+	}
+	for other := range a.interestedBy {
+		if !other.aoi.isInRange(a.pos) {
+			leaveBy = append(leaveBy, &other.aoi)
+		}
+	}
+
+	if a.dist > maxDist {
+		maxDist = a.dist
+	}
+	cal.Visit(a, maxDist, func(other *aoi) {
+		otherEntity := other.getEntity()
+		if a.isInRange(other.pos) && !a.neighbors.Contains(otherEntity) {
+			enter = append(enter, other)
+		}
+		if other.isInRange(a.pos) && !a.interestedBy.Contains(otherEntity) {
+			enterBy = append(enterBy, other)
+		}
+	})
+	return
+}
+
+// adjustNeighbors calculates aois which a starts and stops being interested in within the AOI distance of a
+func adjustNeighbors(cal AOIVisitor, a *aoi) (enter, leave []*aoi) {
+	for neighbor := range a.neighbors {
+		if !a.isInRange(neighbor.aoi.pos) {
+			leave = append(leave, &neighbor.aoi)
+		}
+	}
+
+	cal.Visit(a, a.dist, func(other *aoi) {
+		if a.isInRange(other.pos) && !a.neighbors.Contains(other.getEntity()) {
+			enter = append(enter, other)
+		}
+	})
+	return
 }
 
 type aoiSet map[*aoi]struct{}
@@ -12,13 +12,28 @@ type AOICalculator interface {
 	Adjust(aoi *aoi) (enter []*aoi, leave []*aoi)
 }
 
+// AOIVisitor is an optional interface of AOICalculator to visit aois in range
+//
+// Space uses it to support different AOI distances of entities and spatial queries efficiently.
+// If the AOICalculator does not implement AOIVisitor, neighbors are calculated by Adjust and are always mutual,
+// and spatial queries check all entities in space.
+type AOIVisitor interface {
+	// Visit all other aois within distance dist of aoi on both X and Z axes
+	Visit(aoi *aoi, dist Coord, f func(other *aoi))
+	// Visit all aois within the box from min to max on both X and Z axes
+	VisitBox(min, max Vector3, f func(aoi *aoi))
+}
+
 // XZListAOICalculator is an implementation of AOICalculator using XZ lists
 type XZListAOICalculator struct {
 	xSweepList *xAOIList
 	zSweepList *zAOIList
 }
 
-func newXZListAOICalculator() *XZListAOICalculator {
+// NewXZListAOICalculator creates a XZListAOICalculator
+//
+// XZListAOICalculator is the default AOICalculator of spaces
+func NewXZListAOICalculator() *XZListAOICalculator {
 	return &XZListAOICalculator{
 		xSweepList: newXAOIList(),
 		zSweepList: newZAOIList(),
@@ -59,28 +74,44 @@ func (cal *XZListAOICalculator) Move(aoi *aoi, pos Vector3) {
 
 // Adjust is called by Entity to adjust neighbors
 func (cal *XZListAOICalculator) Adjust(aoi *aoi) (enter []*aoi, leave []*aoi) {
-	cal.xSweepList.Mark(aoi)
-	cal.zSweepList.Mark(aoi)
-	// aoi marked twice are neighbors
-	for neighbor := range aoi.neighbors {
-		naoi := &neighbor.aoi
-		if naoi.markVal == 2 {
-			// neighbors kept
-			naoi.markVal = -2 // mark this as neighbor
-		} else { // markVal < 2
-			// was neighbor, but not any more
-			leave = append(leave, naoi)
-		}
-	}
+	return adjustNeighbors(cal, aoi)
+}
 
-	// travel in X list again to find all new neighbors, whose markVal == 2
-	enter = cal.xSweepList.GetClearMarkedNeighbors(aoi)
+// Visit is called by Space to find aois within distance
+func (cal *XZListAOICalculator) Visit(aoi *aoi, dist Coord, f func(other *aoi)) {
+	cal.xSweepList.Mark(aoi, dist)
+	cal.zSweepList.Mark(aoi, dist)
+	// aoi marked twice are in range, travel in X list again to find them
+	inRange := cal.xSweepList.GetClearMarkedNeighbors(aoi, dist)
 	// travel in Z list again to unmark all
-	cal.zSweepList.ClearMark(aoi)
+	cal.zSweepList.ClearMark(aoi, dist)
 
-	// now all marked neighbors are cleared
-	// travel in neighbors
-	return
+	for _, other := range inRange {
+		f(other)
+	}
+}
+
+// VisitBox is called by Space to find aois within the box
+func (cal *XZListAOICalculator) VisitBox(min, max Vector3, f func(aoi *aoi)) {
+	xList := cal.xSweepList
+	if xList.head == nil {
+		return
+	}
+
+	// travel in X list from the nearer end
+	if min.X-xList.head.pos.X <= xList.tail.pos.X-max.X {
+		for p := xList.head; p != nil && p.pos.X <= max.X; p = p.xNext {
+			if p.pos.X >= min.X && p.pos.Z >= min.Z && p.pos.Z <= max.Z {
+				f(p)
+			}
+		}
+	} else {
+		for p := xList.tail; p != nil && p.pos.X >= min.X; p = p.xPrev {
+			if p.pos.X <= max.X && p.pos.Z >= min.Z && p.pos.Z <= max.Z {
+				f(p)
+			}
+		}
+	}
 }
 
 type aoiListOperator interface {
@@ -85,7 +85,7 @@ func TestXAOIList_Interested(t *testing.T) {
 
 		for r := 0; r < 10; r++ {
 			aoi := aois[rand.Intn(len(aois))]
-			list.Mark(aoi)
+			list.Mark(aoi, _DEFAULT_AOI_DISTANCE)
 
 			for _, other := range aois {
 				if other == aoi {
@@ -165,3 +165,167 @@ func checkList(t *testing.T, list *xAOIList, N int) {
 		t.Errorf("unexpected not nil ")
 	}
 }
+
+func testAOICalculator(t *testing.T, newCalculator func() AOICalculator, randPos func() Vector3) {
+	for i := 0; i < 100; i++ {
+		cal := newCalculator()
+		visitor := cal.(AOIVisitor)
+		aois := []*aoi{}
+		N := 1 + rand.Intn(100)
+		for j := 0; j < N; j++ {
+			aoi := &aoi{}
+			cal.Enter(aoi, randPos())
+			aois = append(aois, aoi)
+		}
+
+		for r := 0; r < 100; r++ {
+			a := aois[rand.Intn(len(aois))]
+			switch rand.Intn(4) {
+			case 0:
+				cal.Leave(a)
+				cal.Enter(a, randPos())
+			default:
+				cal.Move(a, randPos())
+			}
+
+			dist := Coord(rand.Intn(2*_DEFAULT_AOI_DISTANCE) + 1)
+			visited := aoiSet{}
+			visitor.Visit(a, dist, func(other *aoi) {
+				if visited.Contains(other) {
+					t.Fatalf("aoi visited twice")
+				}
+				visited.Add(other)
+			})
+
+			min := randPos()
+			max := min.Add(Vector3{Coord(rand.Intn(300)), 0, Coord(rand.Intn(300))})
+			inBox := aoiSet{}
+			visitor.VisitBox(min, max, func(other *aoi) {
+				inBox.Add(other)
+			})
+			for _, other := range aois {
+				if inBox.Contains(other) != (other.pos.X >= min.X && other.pos.X <= max.X && other.pos.Z >= min.Z && other.pos.Z <= max.Z) {
+					t.Fatalf("visit box %v - %v: wrong result for %v", min, max, other.pos)
+				}
+			}
+
+			for _, other := range aois {
+				inRange := other != a && math.Abs(float64(a.pos.X-other.pos.X)) <= float64(dist) && math.Abs(float64(a.pos.Z-other.pos.Z)) <= float64(dist)
+				if visited.Contains(other) != inRange {
+					t.Fatalf("%v: visit %v should be %v, dist=%v", a.pos, other.pos, inRange, dist)
+				}
+			}
+		}
+	}
+}
+
+func randAOIPos() Vector3 {
+	return Vector3{Coord(rand.Float32()*1000 - 500), 0, Coord(rand.Float32()*1000 - 500)}
+}
+
+// randCrowdedAOIPos returns positions crowded at a few X coordinates
+func randCrowdedAOIPos() Vector3 {
+	return Vector3{Coord(rand.Intn(3) * 10), 0, Coord(rand.Float32()*1000 - 500)}
+}
+
+func TestXZListAOICalculator(t *testing.T) {
+	newCalculator := func() AOICalculator { return NewXZListAOICalculator() }
+	testAOICalculator(t, newCalculator, randAOIPos)
+	testAOICalculator(t, newCalculator, randCrowdedAOIPos)
+}
+
+func TestGridAOICalculator(t *testing.T) {
+	newCalculator := func() AOICalculator { return NewGridAOICalculator(_DEFAULT_AOI_DISTANCE) }
+	testAOICalculator(t, newCalculator, randAOIPos)
+	testAOICalculator(t, newCalculator, randCrowdedAOIPos)
+	newCalculator = func() AOICalculator { return NewGridAOICalculator(33) }
+	testAOICalculator(t, newCalculator, randAOIPos)
+}
+
+// adjustOnlyAOICalculator is an AOICalculator which does not implement AOIVisitor
+type adjustOnlyAOICalculator struct {
+	cal *XZListAOICalculator
+}
+
+func (cal *adjustOnlyAOICalculator) Enter(aoi *aoi, pos Vector3) { cal.cal.Enter(aoi, pos) }
+func (cal *adjustOnlyAOICalculator) Leave(aoi *aoi)              { cal.cal.Leave(aoi) }
+func (cal *adjustOnlyAOICalculator) Move(aoi *aoi, pos Vector3)  { cal.cal.Move(aoi, pos) }
+func (cal *adjustOnlyAOICalculator) Adjust(aoi *aoi) (enter []*aoi, leave []*aoi) {
+	return cal.cal.Adjust(aoi)
+}
+
+func TestSpace_AdjustOnlyAOICalculator(t *testing.T) {
+	space := newTestSpace(1)
+	space.SetAOICalculator(&adjustOnlyAOICalculator{NewXZListAOICalculator()})
+	var entities []*Entity
+	for i := 0; i < 50; i++ {
+		e := newTestAOIEntity("testAOIEntity")
+		space.enter(e, randAOIPos(), false)
+		entities = append(entities, e)
+	}
+
+	for i := 0; i < 500; i++ {
+		e := entities[rand.Intn(len(entities))]
+		space.move(e, randAOIPos())
+	}
+	verifyAOI(t, space)
+
+	e := entities[0]
+	inRadius := space.GetEntitiesInRadius(e.aoi.pos, _DEFAULT_AOI_DISTANCE, nil)
+	for _, other := range entities {
+		if inRadius.Contains(other) != (e.aoi.pos.DistanceTo(other.aoi.pos) <= _DEFAULT_AOI_DISTANCE) {
+			t.Fatalf("spatial query should check all entities if calculator is not AOIVisitor")
+		}
+	}
+}
+
+func TestSpace_SetAOICalculator(t *testing.T) {
+	space := newTestSpace(1)
+	var entities []*Entity
+	for i := 0; i < 50; i++ {
+		e := newTestAOIEntity("testAOIEntity")
+		space.enter(e, randAOIPos(), false)
+		entities = append(entities, e)
+	}
+
+	space.SetAOICalculator(NewGridAOICalculator(_DEFAULT_AOI_DISTANCE))
+	for i := 0; i < 500; i++ {
+		e := entities[rand.Intn(len(entities))]
+		space.move(e, randAOIPos())
+	}
+	verifyAOI(t, space)
+}
+
+func benchmarkAOICalculator(b *testing.B, cal interface {
+	AOICalculator
+	AOIVisitor
+}, randPos func() Vector3) {
+	aois := make([]*aoi, 1000)
+	for i := range aois {
+		aois[i] = &aoi{}
+		cal.Enter(aois[i], randPos())
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		a := aois[i%len(aois)]
+		cal.Move(a, randPos())
+		cal.Visit(a, _DEFAULT_AOI_DISTANCE, func(other *aoi) {})
+	}
+}
+
+func BenchmarkXZListAOICalculator(b *testing.B) {
+	benchmarkAOICalculator(b, NewXZListAOICalculator(), randAOIPos)
+}
+
+func BenchmarkXZListAOICalculator_Crowded(b *testing.B) {
+	benchmarkAOICalculator(b, NewXZListAOICalculator(), randCrowdedAOIPos)
+}
+
+func BenchmarkGridAOICalculator(b *testing.B) {
+	benchmarkAOICalculator(b, NewGridAOICalculator(_DEFAULT_AOI_DISTANCE), randAOIPos)
+}
+
+func BenchmarkGridAOICalculator_Crowded(b *testing.B) {
+	benchmarkAOICalculator(b, NewGridAOICalculator(_DEFAULT_AOI_DISTANCE), randCrowdedAOIPos)
+}
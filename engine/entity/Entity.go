@@ -20,6 +20,7 @@ import (
 	"github.com/lovelly/goworld/engine/post"
 	"github.com/lovelly/goworld/engine/proto"
 	"github.com/lovelly/goworld/engine/storage"
+	"github.com/pkg/errors"
 	"github.com/xiaonanln/typeconv"
 )
 
@@ -36,7 +37,9 @@ type entityTimerInfo struct {
 	Method         string
 	Args           []interface{}
 	Repeat         bool
+	Persistent     bool // saved with persistent attributes
 	rawTimer       *timer.Timer
+	catchUpFires   int // number of missed fires to catch up
 }
 
 // Entity is the basic execution unit in GoWorld server. Entities can be used to
@@ -52,6 +55,8 @@ type Entity struct {
 	Space     *Space
 	aoi       aoi
 	yaw       Yaw
+	movement  *entityMovement
+	history   *positionHistory
 
 	rawTimers   map[*timer.Timer]struct{}
 	timers      map[EntityTimerID]*entityTimerInfo
@@ -60,16 +65,27 @@ type Entity struct {
 	client            *GameClient
 	declaredServices  common.StringSet
 	syncingFromClient bool
+	clientMove        clientMoveState
+	syncLODPending    EntitySet // neighbors skipped by sync LOD tiers
 
-	Attrs *MapAttr
+	Attrs      *MapAttr
+	attrsReady bool
+	dirtyAttrs *dirtyAttrNode // persistent attributes changed since last save
+	saveAll    bool           // all persistent attributes should be saved
+	attrDeltas *attrDeltaQueue
 
 	enteringSpaceRequest struct {
 		SpaceID     common.EntityID
 		EnterPos    Vector3
 		RequestTime int64
 	}
+	enteringLastSpace bool                       // entering the last space after loaded, see SetRememberLastSpace
+	handoverTo        common.EntityID            // the cell of partitioned space which entity is handed over to
+	ghost             *ghostInfo                 // not nil if entity is a ghost in the cell of partitioned space
+	clientKeeps       map[common.EntityID]string // entities hidden but kept on client during handover
 
-	filterProps map[string]string
+	filterProps     map[string]string
+	rpcRateCounters map[rpcRateKey]*rpcRateCounter
 
 	syncInfoFlag syncInfoFlag
 }
@@ -113,12 +129,18 @@ func (e *Entity) Destroy() {
 	if e.destroyed {
 		return
 	}
+	if e.IsGhost() {
+		gwlog.Errorf("%s.Destroy: ghost entity can not be destroyed", e)
+		return
+	}
 	gwlog.Debugf("%s.Destroy ...", e)
+	e.handoverTo = ""
 	e.destroyEntity(false)
 	dispatcherclient.GetDispatcherClientForSend().SendNotifyDestroyEntity(e.ID)
 }
 
 func (e *Entity) destroyEntity(isMigrate bool) {
+	e.flushAttrDeltas()
 	e.Space.leave(e)
 
 	if !isMigrate {
@@ -141,6 +163,11 @@ func (e *Entity) destroyEntity(isMigrate bool) {
 	}
 
 	entityManager.del(e.ID)
+	visibilityCappedEntities.Del(e)
+	movingEntities.Del(e)
+	e.clearRPCRateCounters("")
+	clearMovementViolationStats(e.ID)
+	clientKeepingEntities.Del(e)
 	e.destroyed = true
 }
 
@@ -155,13 +182,29 @@ func (e *Entity) Save() {
 		return
 	}
 
+	if e.IsSpaceEntity() {
+		e.ToSpace().saveMemberPositions()
+	}
+	e.updateLastSpace()
+
+	if !e.isDirty() {
+		return // nothing changed since last save
+	}
+
 	if consts.DEBUG_SAVE_LOAD {
 		gwlog.Debugf("SAVING %s ...", e)
 	}
 
-	data := e.getPersistentData()
-
-	storage.Save(e.TypeName, e.ID, data, nil)
+	if e.saveAll || !storage.IsPatchSupported() {
+		data := e.getPersistentData()
+		storage.Save(e.TypeName, e.ID, data, nil)
+	} else {
+		patch := e.getPersistentDataPatch(storage.IsRootPatchOnly())
+		storage.SavePatch(e.TypeName, e.ID, patch, func() interface{} {
+			return e.getPersistentData() // saved if the patch can not be applied
+		}, nil)
+	}
+	e.clearDirtyAttrs()
 }
 
 // IsSpaceEntity returns if the entity is actually a space
@@ -179,12 +222,19 @@ func (e *Entity) ToSpace() *Space {
 }
 
 func (e *Entity) init(typeName string, entityID common.EntityID, entityInstance reflect.Value) {
+	e.initFields(typeName, entityID, entityInstance)
+	e.callCompositiveMethod("OnInit")
+}
+
+// initFields initializes fields of entity without calling OnInit
+func (e *Entity) initFields(typeName string, entityID common.EntityID, entityInstance reflect.Value) {
 	e.ID = entityID
 	e.V = entityInstance
 	e.I = entityInstance.Interface().(IEntity)
 	e.TypeName = typeName
 
 	e.typeDesc = registeredEntityTypes[typeName]
+	e.saveAll = true // save all persistent attributes until the entity is loaded or saved
 
 	//if !e.typeDesc.definedAttrs {
 	//	// first time entity of this type is created, define attrs now
@@ -202,8 +252,6 @@ func (e *Entity) init(typeName string, entityID common.EntityID, entityInstance
 
 	initAOI(&e.aoi)
 	e.initComponents()
-
-	e.callCompositiveMethod("OnInit")
 }
 
 func (e *Entity) initComponents() {
@@ -230,7 +278,11 @@ func (e *Entity) callCompositiveMethod(methodName string, args ...interface{}) {
 	if len(args) > 0 {
 		methodIn = make([]reflect.Value, len(args), len(args))
 		for i := 0; i < len(args); i++ {
-			methodIn[i] = reflect.ValueOf(args[i])
+			if args[i] != nil {
+				methodIn[i] = reflect.ValueOf(args[i])
+			} else {
+				methodIn[i] = reflect.ValueOf(&args[i]).Elem() // nil interface{} value
+			}
 		}
 	}
 
@@ -283,21 +335,65 @@ func SetSaveInterval(duration time.Duration) {
 // Interests and Uninterest among entities
 func (e *Entity) interest(other *Entity) {
 	e.aoi.interest(other)
-	e.client.sendCreateEntity(other, false)
+	if e.aoi.canShow() {
+		e.show(other)
+	}
 }
 
 func (e *Entity) uninterest(other *Entity) {
 	e.aoi.uninterest(other)
+	if e.aoi.visible.Contains(other) {
+		e.hide(other)
+	}
+}
+
+// Show and hide neighbors on client
+func (e *Entity) show(other *Entity) {
+	e.aoi.show(other)
+	if e.unkeepOnClient(other.ID) {
+		// other is kept on client during handover
+		if other.movement != nil {
+			e.client.sendMoveEntity(other, other.movement.path[0], other.movement.speed)
+		}
+		return
+	}
+	e.client.sendCreateEntity(other, false)
+}
+
+func (e *Entity) hide(other *Entity) {
+	e.aoi.hide(other)
+	if keepingOnClient && e.client != nil {
+		e.keepOnClient(other.TypeName, other.ID)
+		return
+	}
 	e.client.sendDestroyEntity(other)
 }
 
 // Neighbors get all neighbors in an EntitySet
 //
+// Neighbors are entities within the aoi distance of this entity.
+// Neighbors are all visible to the client of this entity unless max visible neighbors is set by SetMaxVisibleNeighbors.
 // Never modify the return value !
 func (e *Entity) Neighbors() EntitySet {
 	return e.aoi.neighbors
 }
 
+// VisibleNeighbors gets neighbors that are visible to the client of this entity in an EntitySet
+//
+// Never modify the return value !
+func (e *Entity) VisibleNeighbors() EntitySet {
+	return e.aoi.visible
+}
+
+// InterestedBy gets all entities interested in this entity in an EntitySet
+//
+// These entities have this entity as neighbor.
+// They are same as Neighbors if all entities in space have the same aoi distance.
+// Never modify the return value !
+func (e *Entity) InterestedBy() EntitySet {
+	return e.aoi.interestedBy
+}
+
 func (e *Entity) IsNeighbor(other *Entity) bool {
 	return e.aoi.neighbors.Contains(other)
 }
@@ -370,12 +466,18 @@ func (e *Entity) CancelTimer(tid EntityTimerID) {
 	}
 	delete(e.timers, tid)
 	e.cancelRawTimer(timerInfo.rawTimer)
+	if timerInfo.Persistent {
+		e.delPersistentTimer(tid)
+	}
 }
 
 func (e *Entity) triggerTimer(tid EntityTimerID, isRepeat bool) {
 	timerInfo := e.timers[tid] // should never be nil
 	if !timerInfo.Repeat {
 		delete(e.timers, tid)
+		if timerInfo.Persistent {
+			e.delPersistentTimer(tid)
+		}
 	} else {
 		if !isRepeat {
 			timerInfo.rawTimer = e.addRawTimer(timerInfo.RepeatInterval, func() {
@@ -385,8 +487,14 @@ func (e *Entity) triggerTimer(tid EntityTimerID, isRepeat bool) {
 
 		now := time.Now()
 		timerInfo.FireTime = now.Add(timerInfo.RepeatInterval)
+		if timerInfo.Persistent {
+			e.updatePersistentTimer(tid, timerInfo.FireTime)
+		}
 	}
 
+	for ; timerInfo.catchUpFires > 0; timerInfo.catchUpFires-- {
+		e.onCallFromLocal(timerInfo.Method, timerInfo.Args)
+	}
 	e.onCallFromLocal(timerInfo.Method, timerInfo.Args)
 }
 
@@ -405,6 +513,9 @@ func (e *Entity) dumpTimers() []byte {
 
 	timers := make([]*entityTimerInfo, 0, len(e.timers))
 	for _, t := range e.timers {
+		if t.Persistent {
+			continue // persistent timers are restored from attributes
+		}
 		timers = append(timers, t)
 	}
 	e.timers = nil // no more AddCallback or AddTimer
@@ -487,9 +598,18 @@ func (e *Entity) CallService(serviceName string, method string, args ...interfac
 
 func (e *Entity) syncPositionYawFromClient(x, y, z Coord, yaw Yaw) {
 	//gwlog.Infof("%s.syncPositionYawFromClient: %v,%v,%v, yaw %v, syncing %v", e, x, y, z, yaw, e.syncingFromClient)
-	if e.syncingFromClient {
-		e.setPositionYaw(Vector3{x, y, z}, yaw, true)
+	if !e.syncingFromClient {
+		return
+	}
+
+	pos := Vector3{x, y, z}
+	if e.typeDesc.movementValidator != nil && e.Space != nil && !e.Space.IsNil() {
+		var ok bool
+		if pos, ok = e.validateClientMovement(pos, time.Now()); !ok {
+			return
+		}
 	}
+	e.setPositionYaw(pos, yaw, true)
 }
 
 // SetClientSyncing set if entity infos (position, yaw) is syncing with client
@@ -497,11 +617,12 @@ func (e *Entity) SetClientSyncing(syncing bool) {
 	e.syncingFromClient = syncing
 }
 
-func (e *Entity) onCallFromLocal(methodName string, args []interface{}) {
+func (e *Entity) onCallFromLocal(methodName string, args []interface{}) (results []interface{}, err error) {
 	defer func() {
-		err := recover() // recover from any error during RPC call
-		if err != nil {
-			gwlog.TraceError("%s.%s paniced: %s", e, methodName, err)
+		perr := recover() // recover from any error during RPC call
+		if perr != nil {
+			gwlog.TraceError("%s.%s paniced: %s", e, methodName, perr)
+			err = errors.Errorf("%s.%s paniced: %v", e, methodName, perr)
 		}
 	}()
 
@@ -535,14 +656,15 @@ func (e *Entity) onCallFromLocal(methodName string, args []interface{}) {
 		in[i+1] = reflect.Zero(argType)
 	}
 
-	rpcDesc.Func.Call(in)
+	return rpcDesc.callResults(rpcDesc.Func.Call(in))
 }
 
-func (e *Entity) onCallFromRemote(methodName string, args [][]byte, clientid common.ClientID) {
+func (e *Entity) onCallFromRemote(methodName string, args [][]byte, clientid common.ClientID) (results []interface{}, err error) {
 	defer func() {
-		err := recover() // recover from any error during RPC call
-		if err != nil {
-			gwlog.TraceError("%s.%s paniced: %s", e, methodName, err)
+		perr := recover() // recover from any error during RPC call
+		if perr != nil {
+			gwlog.TraceError("%s.%s paniced: %s", e, methodName, perr)
+			err = errors.Errorf("%s.%s paniced: %v", e, methodName, perr)
 		}
 	}()
 
@@ -550,7 +672,7 @@ func (e *Entity) onCallFromRemote(methodName string, args [][]byte, clientid com
 	if rpcDesc == nil {
 		// rpc not found
 		gwlog.Errorf("%s.onCallFromRemote: Method %s is not a valid RPC, args=%v", e, methodName, args)
-		return
+		return nil, errors.Errorf("%s: method %s is not a valid RPC", e, methodName)
 	}
 
 	methodType := rpcDesc.MethodType
@@ -571,7 +693,20 @@ func (e *Entity) onCallFromRemote(methodName string, args [][]byte, clientid com
 
 	if rpcDesc.NumArgs < len(args) {
 		gwlog.Errorf("%s.onCallFromRemote: Method %s receives %d arguments, but given %d", e, methodName, rpcDesc.NumArgs, len(args))
-		return
+		return nil, errors.Errorf("%s: method %s receives %d arguments, but given %d", e, methodName, rpcDesc.NumArgs, len(args))
+	}
+
+	limits := rpcDesc.Limits
+	if clientid != "" && limits != nil {
+		// check constraints of rpc before decoding arguments
+		if v := e.checkRPCRate(methodName, clientid, limits); v != nil {
+			e.onRPCViolation(v)
+			return nil, v
+		}
+		if v := e.checkRPCArgBytes(methodName, clientid, limits, args); v != nil {
+			e.onRPCViolation(v)
+			return nil, v
+		}
 	}
 
 	in := make([]reflect.Value, rpcDesc.NumArgs+1)
@@ -583,10 +718,27 @@ func (e *Entity) onCallFromRemote(methodName string, args [][]byte, clientid com
 
 		err := netutil.MSG_PACKER.UnpackMsg(arg, argValPtr.Interface())
 		if err != nil {
+			if clientid != "" {
+				v := e.newRPCViolation(methodName, clientid, RPCViolationArgDecode, i, fmt.Sprintf("convert to %s failed: %s", argType, err))
+				e.onRPCViolation(v)
+				return nil, v
+			}
 			gwlog.Panicf("Convert argument %d failed: type=%s", i+1, argType.Name())
 		}
 
 		in[i+1] = reflect.Indirect(argValPtr)
+		if clientid != "" {
+			if v := e.checkRPCArgDepth(methodName, clientid, limits, i, in[i+1]); v != nil {
+				e.onRPCViolation(v)
+				return nil, v
+			}
+		}
+		if clientid != "" && limits != nil {
+			if v := e.checkRPCArgValue(methodName, clientid, limits, i, in[i+1]); v != nil {
+				e.onRPCViolation(v)
+				return nil, v
+			}
+		}
 	}
 
 	for i := len(args); i < rpcDesc.NumArgs; i++ { // use zero value for missing arguments
@@ -594,7 +746,7 @@ func (e *Entity) onCallFromRemote(methodName string, args [][]byte, clientid com
 		in[i+1] = reflect.Zero(argType)
 	}
 
-	rpcDesc.Func.Call(in)
+	return rpcDesc.callResults(rpcDesc.Func.Call(in))
 }
 
 // DeclareService declares global service for service entity
@@ -659,6 +811,9 @@ func (e *Entity) OnDestroy() {
 //
 // Default implementation check entity for persistent attributes
 func (e *Entity) IsPersistent() bool {
+	if e.IsSpaceEntity() {
+		return e.Attrs.HasKey(_SPACE_KIND_ATTR_KEY) && IsSpaceKindPersistent(int(e.GetInt(_SPACE_KIND_ATTR_KEY)))
+	}
 	return e.typeDesc.isPersistent
 }
 
@@ -673,7 +828,7 @@ func (e *Entity) getPersistentData() map[string]interface{} {
 //
 // Load persistent data to attributes
 func (e *Entity) loadPersistentData(data map[string]interface{}) {
-	e.Attrs.AssignMap(data)
+	e.Attrs.AssignMap(e.typeDesc.coerceAttrs(e.TypeName, data))
 }
 
 func (e *Entity) getClientData() map[string]interface{} {
@@ -691,7 +846,7 @@ func (e *Entity) GetMigrateData() map[string]interface{} {
 
 // LoadMigrateData loads migrate data
 func (e *Entity) LoadMigrateData(data map[string]interface{}) {
-	e.Attrs.AssignMap(data)
+	e.Attrs.AssignMap(e.typeDesc.coerceAttrs(e.TypeName, data))
 }
 
 type clientData struct {
@@ -760,14 +915,18 @@ func (e *Entity) SetClient(client *GameClient) {
 		return
 	}
 
+	e.flushAttrDeltas() // pending attribute changes should be sent to the old client
+	e.flushClientKeeps()
+
 	e.client = client
 
 	if oldClient != nil {
 		// send destroy entity to client
 		entityManager.onEntityLoseClient(oldClient.clientid)
+		e.clearRPCRateCounters(oldClient.clientid)
 		dispatcherclient.GetDispatcherClientForSend().SendClearClientFilterProp(oldClient.gateid, oldClient.clientid)
 
-		for neighbor := range e.Neighbors() {
+		for neighbor := range e.aoi.visible {
 			oldClient.sendDestroyEntity(neighbor)
 		}
 
@@ -780,7 +939,7 @@ func (e *Entity) SetClient(client *GameClient) {
 
 		client.sendCreateEntity(e, true)
 
-		for neighbor := range e.Neighbors() {
+		for neighbor := range e.aoi.visible {
 			client.sendCreateEntity(neighbor, false)
 		}
 
@@ -807,9 +966,10 @@ func (e *Entity) CallClient(method string, args ...interface{}) {
 func (e *Entity) CallAllClients(method string, args ...interface{}) {
 	e.client.call(e.ID, method, args)
 
-	for neighbor := range e.Neighbors() {
+	for neighbor := range e.aoi.visibleBy {
 		neighbor.client.call(e.ID, method, args)
 	}
+	e.forwardToGhosts("CallGhostClients", method, args)
 }
 
 // GiveClientTo gives client to other entity
@@ -828,13 +988,37 @@ func (e *Entity) GiveClientTo(other *Entity) {
 	other.SetClient(client)
 }
 
-// ForAllClients visits all clients (own client and clients of neighbors)
+// GiveClientToID gives client to the entity with the ID, which can be on any game
+//
+// The client is given by dispatcher if the target entity is not local, and the target entity is notified by OnClientConnected.
+// If the target entity is not found, the client is given back to this entity if it is not destroyed
+func (e *Entity) GiveClientToID(targetID common.EntityID) {
+	if e.client == nil {
+		gwlog.Warnf("%s.GiveClientToID(%s): client is nil", e, targetID)
+		return
+	}
+
+	if other := entityManager.get(targetID); other != nil {
+		e.GiveClientTo(other)
+		return
+	}
+
+	if consts.DEBUG_CLIENTS {
+		gwlog.Debugf("%s.GiveClientToID(%s): client=%s", e, targetID, e.client)
+	}
+	client := e.client
+	e.SetClient(nil)
+
+	dispatcherclient.GetDispatcherClientForSend().SendGiveClientToEntity(targetID, e.ID, client.gateid, client.clientid)
+}
+
+// ForAllClients visits all clients (own client and clients of entities interested in this entity)
 func (e *Entity) ForAllClients(f func(client *GameClient)) {
 	if e.client != nil {
 		f(e.client)
 	}
 
-	for neighbor := range e.Neighbors() {
+	for neighbor := range e.aoi.visibleBy {
 		if neighbor.client != nil {
 			f(neighbor.client)
 		}
@@ -887,12 +1071,18 @@ func (e *Entity) sendMapAttrChangeToClients(ma *MapAttr, key string, val interfa
 		flag = ma.flag
 	}
 
+	if e.typeDesc.attrDeltaSync && flag&(afClient|afAllClient) != 0 {
+		e.queueAttrDelta(ma, ma.getPathFromOwner(), key, val, false, flag)
+		return
+	}
+
 	if flag&afAllClient != 0 {
 		path := ma.getPathFromOwner()
 		e.client.sendNotifyMapAttrChange(e.ID, path, key, val)
-		for neighbor := range e.aoi.neighbors {
+		for neighbor := range e.aoi.visibleBy {
 			neighbor.client.sendNotifyMapAttrChange(e.ID, path, key, val)
 		}
+		e.forwardAttrOpToGhosts(path, key, attrValueToData(val))
 	} else if flag&afClient != 0 {
 		path := ma.getPathFromOwner()
 		e.client.sendNotifyMapAttrChange(e.ID, path, key, val)
@@ -908,12 +1098,18 @@ func (e *Entity) sendMapAttrDelToClients(ma *MapAttr, key string) {
 		flag = ma.flag
 	}
 
+	if e.typeDesc.attrDeltaSync && flag&(afClient|afAllClient) != 0 {
+		e.queueAttrDelta(ma, ma.getPathFromOwner(), key, nil, true, flag)
+		return
+	}
+
 	if flag&afAllClient != 0 {
 		path := ma.getPathFromOwner()
 		e.client.sendNotifyMapAttrDel(e.ID, path, key)
-		for neighbor := range e.aoi.neighbors {
+		for neighbor := range e.aoi.visibleBy {
 			neighbor.client.sendNotifyMapAttrDel(e.ID, path, key)
 		}
+		e.forwardAttrOpToGhosts(path, key)
 	} else if flag&afClient != 0 {
 		path := ma.getPathFromOwner()
 		e.client.sendNotifyMapAttrDel(e.ID, path, key)
@@ -922,13 +1118,18 @@ func (e *Entity) sendMapAttrDelToClients(ma *MapAttr, key string) {
 
 func (e *Entity) sendListAttrChangeToClients(la *ListAttr, index int, val interface{}) {
 	flag := la.flag
+	if e.typeDesc.attrDeltaSync && flag&(afClient|afAllClient) != 0 {
+		e.queueAttrDelta(la, la.getPathFromOwner(), index, val, false, flag)
+		return
+	}
 
 	if flag&afAllClient != 0 {
 		path := la.getPathFromOwner()
 		e.client.sendNotifyListAttrChange(e.ID, path, uint32(index), val)
-		for neighbor := range e.aoi.neighbors {
+		for neighbor := range e.aoi.visibleBy {
 			neighbor.client.sendNotifyListAttrChange(e.ID, path, uint32(index), val)
 		}
+		e.forwardAttrOpToGhosts(path, index, attrValueToData(val))
 	} else if flag&afClient != 0 {
 		path := la.getPathFromOwner()
 		e.client.sendNotifyListAttrChange(e.ID, path, uint32(index), val)
@@ -937,12 +1138,17 @@ func (e *Entity) sendListAttrChangeToClients(la *ListAttr, index int, val interf
 
 func (e *Entity) sendListAttrPopToClients(la *ListAttr) {
 	flag := la.flag
+	if e.typeDesc.attrDeltaSync && flag&(afClient|afAllClient) != 0 {
+		e.queueListAttrResized(la, flag)
+		return
+	}
 	if flag&afAllClient != 0 {
 		path := la.getPathFromOwner()
 		e.client.sendNotifyListAttrPop(e.ID, path)
-		for neighbor := range e.aoi.neighbors {
+		for neighbor := range e.aoi.visibleBy {
 			neighbor.client.sendNotifyListAttrPop(e.ID, path)
 		}
+		e.forwardAttrOpToGhosts(path[1:], path[0], la.ToList())
 	} else if flag&afClient != 0 {
 		path := la.getPathFromOwner()
 		e.client.sendNotifyListAttrPop(e.ID, path)
@@ -951,12 +1157,17 @@ func (e *Entity) sendListAttrPopToClients(la *ListAttr) {
 
 func (e *Entity) sendListAttrAppendToClients(la *ListAttr, val interface{}) {
 	flag := la.flag
+	if e.typeDesc.attrDeltaSync && flag&(afClient|afAllClient) != 0 {
+		e.queueListAttrResized(la, flag)
+		return
+	}
 	if flag&afAllClient != 0 {
 		path := la.getPathFromOwner()
 		e.client.sendNotifyListAttrAppend(e.ID, path, val)
-		for neighbor := range e.aoi.neighbors {
+		for neighbor := range e.aoi.visibleBy {
 			neighbor.client.sendNotifyListAttrAppend(e.ID, path, val)
 		}
+		e.forwardAttrOpToGhosts(path[1:], path[0], la.ToList())
 	} else if flag&afClient != 0 {
 		path := la.getPathFromOwner()
 		e.client.sendNotifyListAttrAppend(e.ID, path, val)
@@ -996,6 +1207,7 @@ func (e *Entity) GetListAttr(key string) *ListAttr {
 
 // EnterSpace let the entity enters space
 func (e *Entity) EnterSpace(spaceID common.EntityID, pos Vector3) {
+	e.handoverTo = ""
 	if e.isEnteringSpace() {
 		gwlog.Errorf("%s is entering space %s, can not enter space %s", e, e.enteringSpaceRequest.SpaceID, spaceID)
 		e.callCompositiveMethod("OnEnterSpace")
@@ -1030,6 +1242,7 @@ func (e *Entity) enterLocalSpace(space *Space, pos Vector3) {
 
 		if space.IsDestroyed() {
 			gwlog.Warnf("%s: space %s is destroyed, enter space cancelled", e, space.ID)
+			e.onEnterLastSpaceFailed()
 			return
 		}
 
@@ -1072,6 +1285,8 @@ func OnMigrateRequestAck(entityID common.EntityID, spaceID common.EntityID, spac
 		// target space not found, migrate not started
 		gwlog.Errorf("Migrate failed since target space is not found: spaceID=%s, entity=%s", spaceID, entity)
 		entity.clearEnteringSpaceRequest()
+		entity.handoverTo = ""
+		entity.onEnterLastSpaceFailed()
 		return
 	}
 
@@ -1096,9 +1311,12 @@ func (e *Entity) realMigrateTo(spaceID common.EntityID, pos Vector3, spaceLoc ui
 		clientsrv = e.client.gateid
 	}
 
+	// replies can not reach the entity after migration
+	failPendingCallReplies(e, errors.Errorf("%s migrated before reply", e))
 	e.destroyEntity(true) // disable the entity
 	timerData := e.dumpTimers()
 	migrateData := e.GetMigrateData()
+	e.dumpClientKeeps(migrateData)
 
 	dispatcherclient.GetDispatcherClientForSend().SendRealMigrate(e.ID, spaceLoc, spaceID,
 		float32(pos.X), float32(pos.Y), float32(pos.Z), e.TypeName, migrateData, timerData, clientid, clientsrv)
@@ -1179,28 +1397,46 @@ func (e *Entity) GetPosition() Vector3 {
 }
 
 // SetPosition sets the entity position
+//
+// The current movement is stopped at the new position, so that clients do not see the entity stopping at the old position first.
 func (e *Entity) SetPosition(pos Vector3) {
-	e.setPositionYaw(pos, e.yaw, false)
+	if e.movement == nil {
+		e.setPositionYaw(pos, e.yaw, false)
+		return
+	}
+
+	// the stop is the only position update sent to clients
+	e.movement = nil
+	movingEntities.Del(e)
+	e.movePosition(pos)
+	e.sendMoveEntity(pos, 0)
+	e.resetClientMove()
 }
 
 func (e *Entity) setPositionYaw(pos Vector3, yaw Yaw, fromClient bool) {
-	space := e.Space
-	if space == nil {
+	if e.Space == nil {
 		gwlog.Warnf("%s.SetPosition(%s): space is nil", e, pos)
 		return
 	}
 
-	space.move(e, pos)
 	e.yaw = yaw
+	e.movePosition(pos)
 
 	// mark the entity as needing sync
 	// Real sync packets will be sent before flushing dispatcher client
 	e.syncInfoFlag |= sifSyncNeighborClients
 	if !fromClient {
 		e.syncInfoFlag |= sifSyncOwnClient
+		e.resetClientMove()
 	}
 }
 
+// movePosition moves the entity in space without syncing to clients
+func (e *Entity) movePosition(pos Vector3) {
+	e.Space.move(e, pos)
+	e.recordPositionHistory()
+}
+
 // CollectEntitySyncInfos is called by game service to collect and broadcast entity sync infos to all clients
 func CollectEntitySyncInfos() {
 	cfg := config.Get()
@@ -1213,10 +1449,11 @@ func CollectEntitySyncInfos() {
 		entitySyncInfosToGate[gateid-1] = packet
 	}
 
-	for eid, e := range entityManager.entities {
+	syncTick += 1
+	collect := func(eid common.EntityID, e *Entity) {
 		syncInfoFlag := e.syncInfoFlag
-		if syncInfoFlag == 0 {
-			continue
+		if syncInfoFlag == 0 && e.syncLODPending == nil {
+			return
 		}
 
 		e.syncInfoFlag = 0
@@ -1231,21 +1468,25 @@ func CollectEntitySyncInfos() {
 			packet.AppendFloat32(syncInfo.Z)
 			packet.AppendFloat32(syncInfo.Yaw)
 		}
-		if syncInfoFlag&sifSyncNeighborClients != 0 {
-			for neighbor := range e.aoi.neighbors {
-				client := neighbor.client
-				if client != nil {
-					gateid := client.gateid
-					packet := entitySyncInfosToGate[gateid-1]
-					packet.AppendClientID(client.clientid)
-					packet.AppendEntityID(eid)
-					packet.AppendFloat32(syncInfo.X)
-					packet.AppendFloat32(syncInfo.Y)
-					packet.AppendFloat32(syncInfo.Z)
-					packet.AppendFloat32(syncInfo.Yaw)
-				}
+		e.visitSyncNeighbors(syncInfoFlag&sifSyncNeighborClients != 0, func(neighbor *Entity) {
+			client := neighbor.client
+			if client != nil {
+				gateid := client.gateid
+				packet := entitySyncInfosToGate[gateid-1]
+				packet.AppendClientID(client.clientid)
+				packet.AppendEntityID(eid)
+				packet.AppendFloat32(syncInfo.X)
+				packet.AppendFloat32(syncInfo.Y)
+				packet.AppendFloat32(syncInfo.Z)
+				packet.AppendFloat32(syncInfo.Yaw)
 			}
-		}
+		})
+	}
+	for eid, e := range entityManager.entities {
+		collect(eid, e)
+	}
+	for e := range ghostEntities {
+		collect(e.ID, e)
 	}
 
 	// send to dispatcher, one gate by one gate
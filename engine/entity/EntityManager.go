@@ -35,6 +35,13 @@ type EntityTypeDesc struct {
 	clientAttrs                       common.StringSet
 	persistentAttrs                   common.StringSet
 	compositiveMethodComponentIndices map[string][]int
+	attrHooks                         *attrHooks
+	attrDescs                         map[string]*AttrDesc
+	attrDeltaSync                     bool
+	timerCatchUpPolicy                TimerCatchUpPolicy
+	aoiDistance                       Coord
+	movementValidator                 *MovementValidator
+	rememberLastSpace                 bool
 	//definedAttrs                      bool
 }
 
@@ -44,15 +51,30 @@ func init() {
 	_VALID_ATTR_DEFS.Add(strings.ToLower("Client"))
 	_VALID_ATTR_DEFS.Add(strings.ToLower("AllClients"))
 	_VALID_ATTR_DEFS.Add(strings.ToLower("Persistent"))
+	for typeName := range attrTypeNames {
+		_VALID_ATTR_DEFS.Add(typeName)
+	}
 }
 
-func (desc *EntityTypeDesc) DefineAttr(attr string, defs ...string) {
+// DefineAttr defines attribute with properties: Client, AllClients, Persistent and the attribute type (Int, Float, Str, Bool, Map, List)
+//
+// Values of wrong type can not be set to typed attributes. Use Default of the returned AttrDesc to set the default value.
+func (desc *EntityTypeDesc) DefineAttr(attr string, defs ...string) *AttrDesc {
 	gwlog.Infof("        Attr %s = %v", attr, defs)
 	isAllClient, isClient, isPersistent := false, false, false
+	attrDesc := &AttrDesc{name: attr}
 
 	for _, def := range defs {
 		def := strings.ToLower(def)
 
+		if attrType, ok := parseAttrType(def); ok {
+			if attrDesc.typ != AttrTypeAny {
+				gwlog.Panicf("attribute %s: multiple types: %s, %s", attr, attrDesc.typ, attrType)
+			}
+			attrDesc.typ = attrType
+			continue
+		}
+
 		if !_VALID_ATTR_DEFS.Contains(def) {
 			// not a valid def
 			gwlog.Panicf("attribute %s: invalid property: %s; all valid properties: %v", attr, def, _VALID_ATTR_DEFS.ToList())
@@ -81,6 +103,8 @@ func (desc *EntityTypeDesc) DefineAttr(attr string, defs ...string) {
 	if isPersistent {
 		desc.persistentAttrs.Add(attr)
 	}
+	desc.attrDescs[attr] = attrDesc
+	return attrDesc
 }
 
 type _EntityManager struct {
@@ -118,6 +142,8 @@ func (em *_EntityManager) onEntityGetClient(entityID common.EntityID, clientid c
 }
 
 func (em *_EntityManager) onClientDisconnected(clientid common.ClientID) {
+	clearRPCViolationStats(clientid)
+	clearRPCRateCountersOfClient(clientid)
 	eid := em.ownerOfClient[clientid]
 	if !eid.IsNil() { // should always true
 		em.onEntityLoseClient(clientid)
@@ -142,7 +168,11 @@ func (em *_EntityManager) onDeclareService(serviceName string, eid common.Entity
 		eids = EntityIDSet{}
 		em.registeredServices[serviceName] = eids
 	}
+	ready := len(eids) > 0
 	eids.Add(eid)
+	if serviceName == _SPACE_SERVICE_NAME && !ready {
+		onSpaceServiceReady()
+	}
 }
 
 func (em *_EntityManager) onUndeclareService(serviceName string, eid common.EntityID) {
@@ -170,7 +200,9 @@ func (em *_EntityManager) chooseServiceProvider(serviceName string) common.Entit
 }
 
 // RegisterEntity registers custom entity type and define entity behaviors
-func RegisterEntity(typeName string, entity IEntity, isPersistent bool, useAOI bool) {
+//
+// Returns the entity type description which can be used to define more properties of entity type
+func RegisterEntity(typeName string, entity IEntity, isPersistent bool, useAOI bool) *EntityTypeDesc {
 	if _, ok := registeredEntityTypes[typeName]; ok {
 		gwlog.Panicf("RegisterEntity: Entity type %s already registered", typeName)
 	}
@@ -193,6 +225,8 @@ func RegisterEntity(typeName string, entity IEntity, isPersistent bool, useAOI b
 		allClientAttrs:                    common.StringSet{},
 		persistentAttrs:                   common.StringSet{},
 		compositiveMethodComponentIndices: map[string][]int{},
+		attrHooks:                         collectAttrHooks(entityType),
+		attrDescs:                         map[string]*AttrDesc{},
 	}
 	registeredEntityTypes[typeName] = entityTypeDesc
 
@@ -209,6 +243,10 @@ func RegisterEntity(typeName string, entity IEntity, isPersistent bool, useAOI b
 	e.V = entityVal // set necessary values for callCompositiveMethod not to panic
 	e.typeDesc = entityTypeDesc
 	e.callCompositiveMethod("DefineAttrs", entityTypeDesc)
+	if isPersistent {
+		entityTypeDesc.persistentAttrs.Add(_PERSISTENT_TIMERS_ATTR_KEY)
+	}
+	return entityTypeDesc
 }
 
 var entityType = reflect.TypeOf(Entity{})
@@ -243,6 +281,7 @@ const (
 	ccCreate createCause = 1 + iota
 	ccMigrate
 	ccRestore
+	ccLoad // created with data loaded from storage
 )
 
 func createEntity(typeName string, space *Space, pos Vector3, entityID common.EntityID, data map[string]interface{}, timerData []byte, client *GameClient, cause createCause) common.EntityID {
@@ -268,43 +307,56 @@ func createEntity(typeName string, space *Space, pos Vector3, entityID common.En
 	entity.Space = nilSpace
 
 	entityManager.put(entity)
+	isCreate := cause == ccCreate || cause == ccLoad // loaded entities are created as new ones except for saving
+	var clientKeeps map[string]interface{}
 	if data != nil {
-		if cause == ccCreate {
+		if isCreate {
 			entity.loadPersistentData(data)
+			if cause == ccLoad {
+				entity.clearDirtyAttrs() // loaded data is same as saved data
+			}
 		} else {
+			clientKeeps = popClientKeeps(data)
 			entity.LoadMigrateData(data)
 		}
-	} else {
-		entity.Save() // save immediately after creation
+	}
+	if isCreate {
+		entity.assignAttrDefaults()
+		if cause == ccCreate {
+			entity.Save() // save immediately after creation
+		}
 	}
 
 	if timerData != nil {
 		entity.restoreTimers(timerData)
 	}
+	entity.restorePersistentTimers()
 
 	isPersistent := entity.IsPersistent()
 	if isPersistent { // startup the periodical timer for saving e
 		entity.setupSaveTimer()
 	}
 
-	if cause == ccCreate || cause == ccRestore {
+	if isCreate || cause == ccRestore {
 		dispatcherclient.GetDispatcherClientForSend().SendNotifyCreateEntity(entityID)
 	}
 
 	if client != nil {
 		// assign client to the newly created
-		if cause == ccCreate {
+		if isCreate {
 			entity.SetClient(client)
 		} else {
 			entity.client = client // assign client quietly if migrate
 			entityManager.onEntityGetClient(entity.ID, client.clientid)
+			entity.loadClientKeeps(clientKeeps)
 		}
 	}
 
 	gwlog.Debugf("Entity %s created, cause=%d, client=%s", entity, cause, client)
+	entity.attrsReady = true // attribute change hooks are enabled since now
 	entity.callCompositiveMethod("OnAttrsReady")
 
-	if cause == ccCreate {
+	if isCreate {
 		entity.callCompositiveMethod("OnCreated")
 	} else if cause == ccMigrate {
 		entity.callCompositiveMethod("OnMigrateIn")
@@ -315,36 +367,53 @@ func createEntity(typeName string, space *Space, pos Vector3, entityID common.En
 
 	if space != nil {
 		space.enter(entity, pos, cause == ccRestore)
+	} else if isCreate {
+		entity.enterLastSpace()
 	}
 
 	return entityID
 }
 
 func loadEntityLocally(typeName string, entityID common.EntityID, space *Space, pos Vector3) {
+	if _, ok := registeredEntityTypes[typeName]; !ok {
+		notifyCreateEntityFailed(entityID, errors.Wrapf(ErrEntityTypeNotRegistered, "load entity %s.%s", typeName, entityID))
+		return
+	}
+
 	// load the data from storage
 	storage.Load(typeName, entityID, func(data interface{}, err error) {
 		// callback runs in main routine
 		if err != nil {
-			gwlog.Panicf("load entity %s.%s failed: %s", typeName, entityID, err)
-			dispatcherclient.GetDispatcherClientForSend().SendNotifyDestroyEntity(entityID) // load entity failed, tell dispatcher
+			notifyCreateEntityFailed(entityID, errors.Wrapf(err, "load entity %s.%s failed", typeName, entityID))
+			return
+		}
+
+		if data == nil {
+			notifyCreateEntityFailed(entityID, errors.Wrapf(ErrEntityNotFound, "load entity %s.%s", typeName, entityID))
+			return
 		}
 
 		if space != nil && space.IsDestroyed() {
 			// Space might be destroy during the Load process, so cancel the entity creation
-			dispatcherclient.GetDispatcherClientForSend().SendNotifyDestroyEntity(entityID) // load entity failed, tell dispatcher
+			notifyCreateEntityFailed(entityID, errors.Errorf("space %s is destroyed while loading entity %s.%s", space.ID, typeName, entityID))
 			return
 		}
 
-		createEntity(typeName, space, pos, entityID, data.(map[string]interface{}), nil, nil, ccCreate)
+		createEntity(typeName, space, pos, entityID, data.(map[string]interface{}), nil, nil, ccLoad)
 	})
 }
 
-func loadEntityAnywhere(typeName string, entityID common.EntityID) {
-	dispatcherclient.GetDispatcherClientForSend().SendLoadEntityAnywhere(typeName, entityID)
+func notifyCreateEntityFailed(entityID common.EntityID, err error) {
+	gwlog.Errorf("%s", err)
+	dispatcherclient.GetDispatcherClientForSend().SendNotifyCreateEntityFailed(entityID, createEntityErrorCode(err), err.Error()) // tell dispatcher
+}
+
+func loadEntityAnywhere(typeName string, entityID common.EntityID, requestID uint32) {
+	dispatcherclient.GetDispatcherClientForSend().SendLoadEntityAnywhere(typeName, entityID, requestID)
 }
 
-func createEntityAnywhere(typeName string, data map[string]interface{}) {
-	dispatcherclient.GetDispatcherClientForSend().SendCreateEntityAnywhere(typeName, data)
+func createEntityAnywhere(typeName string, entityID common.EntityID, data map[string]interface{}, requestID uint32) {
+	dispatcherclient.GetDispatcherClientForSend().SendCreateEntityAnywhere(typeName, entityID, requestID, data)
 }
 
 // CreateEntityLocally creates new entity in the local game
@@ -354,7 +423,7 @@ func CreateEntityLocally(typeName string, data map[string]interface{}, client *G
 
 // CreateEntityAnywhere creates new entity in any game
 func CreateEntityAnywhere(typeName string) {
-	createEntityAnywhere(typeName, nil)
+	createEntityAnywhere(typeName, common.GenEntityID(), nil, 0)
 }
 
 // LoadEntityLocally loads entity in the local game.
@@ -368,7 +437,18 @@ func LoadEntityLocally(typeName string, entityID common.EntityID) {
 //
 // LoadEntityAnywhere has no effect if entity already exists on any game
 func LoadEntityAnywhere(typeName string, entityID common.EntityID) {
-	loadEntityAnywhere(typeName, entityID)
+	loadEntityAnywhere(typeName, entityID, 0)
+}
+
+// OnLoadEntityInSpace is called by engine when the dispatcher allows loading the entity in the space
+func OnLoadEntityInSpace(typeName string, entityID common.EntityID, spaceID common.EntityID, pos Vector3) {
+	space := spaceManager.getSpace(spaceID)
+	if space == nil || space.IsDestroyed() {
+		notifyCreateEntityFailed(entityID, errors.Errorf("space %s is not found while loading entity %s.%s", spaceID, typeName, entityID))
+		return
+	}
+
+	loadEntityLocally(typeName, entityID, space, pos)
 }
 
 // OnClientDisconnected is called by engine when client is disconnected
@@ -376,6 +456,26 @@ func OnClientDisconnected(clientid common.ClientID) {
 	entityManager.onClientDisconnected(clientid) // pop the owner eid
 }
 
+// OnGiveClientToEntity is called by engine when client is given to entity by GiveClientToID
+//
+// If the target entity is not found, the client is given back to the source entity.
+// Returns false if neither entity is found on this game
+func OnGiveClientToEntity(targetID common.EntityID, sourceID common.EntityID, client *GameClient) bool {
+	if target := entityManager.get(targetID); target != nil {
+		target.SetClient(client)
+		return true
+	}
+
+	if source := entityManager.get(sourceID); source != nil && source.client == nil {
+		gwlog.Warnf("Give %s to %s failed: entity not found, give it back to %s", client, targetID, source)
+		source.SetClient(client)
+		return true
+	}
+
+	gwlog.Errorf("Give %s to %s failed: entity not found", client, targetID)
+	return false
+}
+
 // OnDeclareService is called by engine when service is declared
 func OnDeclareService(serviceName string, entityid common.EntityID) {
 	entityManager.onDeclareService(serviceName, entityid)
@@ -447,6 +547,7 @@ func GetEntity(id common.EntityID) *Entity {
 
 // OnGameTerminating is called when game is terminating
 func OnGameTerminating() {
+	gameTerminating = true
 	for _, e := range entityManager.entities {
 		e.Destroy()
 	}
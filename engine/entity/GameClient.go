@@ -48,13 +48,43 @@ func (client *GameClient) sendCreateEntity(entity *Entity, isPlayer bool) {
 	yaw := entity.yaw
 	dispatcherclient.GetDispatcherClientForSend().SendCreateEntityOnClient(client.gateid, client.clientid, entity.TypeName, entity.ID, isPlayer,
 		clientData, float32(pos.X), float32(pos.Y), float32(pos.Z), float32(yaw))
+
+	if entity.movement != nil {
+		// entity is moving, let the client interpolate the movement
+		client.sendMoveEntity(entity, entity.movement.path[0], entity.movement.speed)
+	}
+}
+
+// sendCreateEntityAs creates the entity on client with another entity ID
+func (client *GameClient) sendCreateEntityAs(entity *Entity, entityID common.EntityID) {
+	if client == nil {
+		return
+	}
+
+	pos := entity.aoi.pos
+	dispatcherclient.GetDispatcherClientForSend().SendCreateEntityOnClient(client.gateid, client.clientid, entity.TypeName, entityID, false,
+		entity.getAllClientData(), float32(pos.X), float32(pos.Y), float32(pos.Z), float32(entity.yaw))
 }
 
 func (client *GameClient) sendDestroyEntity(entity *Entity) {
+	client.sendDestroyEntityID(entity.TypeName, entity.ID)
+}
+
+func (client *GameClient) sendDestroyEntityID(typeName string, entityID common.EntityID) {
+	if client == nil {
+		return
+	}
+	dispatcherclient.GetDispatcherClientForSend().SendDestroyEntityOnClient(client.gateid, client.clientid, typeName, entityID)
+}
+
+// sendMoveEntity notifies client of entity moving to target at speed, or stopping if speed is 0
+func (client *GameClient) sendMoveEntity(entity *Entity, target Vector3, speed Coord) {
 	if client == nil {
 		return
 	}
-	dispatcherclient.GetDispatcherClientForSend().SendDestroyEntityOnClient(client.gateid, client.clientid, entity.TypeName, entity.ID)
+	pos := entity.aoi.pos
+	dispatcherclient.GetDispatcherClientForSend().SendMoveEntityOnClient(client.gateid, client.clientid, entity.ID,
+		float32(pos.X), float32(pos.Y), float32(pos.Z), float32(target.X), float32(target.Y), float32(target.Z), float32(speed))
 }
 
 func (client *GameClient) call(entityID common.EntityID, method string, args []interface{}) {
@@ -108,6 +138,17 @@ func (client *GameClient) sendNotifyListAttrPop(entityID common.EntityID, path [
 	dispatcherclient.GetDispatcherClientForSend().SendNotifyListAttrPopOnClient(client.gateid, client.clientid, entityID, path)
 }
 
+// sendNotifyAttrDelta notifies client of batched attribute changes
+func (client *GameClient) sendNotifyAttrDelta(entityID common.EntityID, ops []interface{}) {
+	if client == nil {
+		return
+	}
+	if consts.DEBUG_CLIENTS {
+		gwlog.Debugf("%s.sendNotifyAttrDelta: entityID=%s, ops=%v", client, entityID, ops)
+	}
+	dispatcherclient.GetDispatcherClientForSend().SendNotifyAttrDeltaOnClient(client.gateid, client.clientid, entityID, ops)
+}
+
 // sendNotifyListAttrAppend notify entity of ListAttr appending
 func (client *GameClient) sendNotifyListAttrAppend(entityID common.EntityID, path []interface{}, val interface{}) {
 	if client == nil {
@@ -0,0 +1,138 @@
+package entity
+
+import (
+	"math"
+
+	"github.com/lovelly/goworld/engine/gwlog"
+)
+
+type gridCell struct {
+	x, z int
+}
+
+// GridAOICalculator is an implementation of AOICalculator using uniform grids (towers)
+//
+// The space is divided into square cells of the same size, and each aoi belongs to the cell containing its position.
+// Unlike XZListAOICalculator, it works well when lots of entities are crowded at the same X or Z coordinate.
+// Cell size should be close to the aoi distance of entities in space.
+type GridAOICalculator struct {
+	cellSize Coord
+	cells    map[gridCell]aoiSet
+}
+
+// NewGridAOICalculator creates a GridAOICalculator with specified cell size
+func NewGridAOICalculator(cellSize Coord) *GridAOICalculator {
+	if cellSize <= 0 {
+		gwlog.Panicf("NewGridAOICalculator: invalid cell size %v", cellSize)
+	}
+
+	return &GridAOICalculator{
+		cellSize: cellSize,
+		cells:    map[gridCell]aoiSet{},
+	}
+}
+
+func (cal *GridAOICalculator) cellIndex(coord Coord) int {
+	return int(math.Floor(float64(coord / cal.cellSize)))
+}
+
+func (cal *GridAOICalculator) cellOf(pos Vector3) gridCell {
+	return gridCell{cal.cellIndex(pos.X), cal.cellIndex(pos.Z)}
+}
+
+func (cal *GridAOICalculator) addToCell(aoi *aoi) {
+	cell := cal.cells[aoi.gridCell]
+	if cell == nil {
+		cell = aoiSet{}
+		cal.cells[aoi.gridCell] = cell
+	}
+	cell.Add(aoi)
+}
+
+func (cal *GridAOICalculator) removeFromCell(aoi *aoi) {
+	cell := cal.cells[aoi.gridCell]
+	cell.Del(aoi)
+	if len(cell) == 0 {
+		delete(cal.cells, aoi.gridCell)
+	}
+}
+
+// Enter is called when Entity enters Space
+func (cal *GridAOICalculator) Enter(aoi *aoi, pos Vector3) {
+	aoi.pos = pos
+	aoi.gridCell = cal.cellOf(pos)
+	cal.addToCell(aoi)
+}
+
+// Leave is called when Entity leaves Space
+func (cal *GridAOICalculator) Leave(aoi *aoi) {
+	cal.removeFromCell(aoi)
+}
+
+// Move is called when Entity moves in Space
+func (cal *GridAOICalculator) Move(aoi *aoi, pos Vector3) {
+	aoi.pos = pos
+	cell := cal.cellOf(pos)
+	if cell == aoi.gridCell {
+		return
+	}
+
+	cal.removeFromCell(aoi)
+	aoi.gridCell = cell
+	cal.addToCell(aoi)
+}
+
+// Adjust is called by Entity to adjust neighbors
+func (cal *GridAOICalculator) Adjust(aoi *aoi) (enter []*aoi, leave []*aoi) {
+	return adjustNeighbors(cal, aoi)
+}
+
+// Visit is called by Space to find aois within distance
+func (cal *GridAOICalculator) Visit(aoi *aoi, dist Coord, f func(other *aoi)) {
+	pos := aoi.pos
+	minX, maxX := cal.cellIndex(pos.X-dist), cal.cellIndex(pos.X+dist)
+	minZ, maxZ := cal.cellIndex(pos.Z-dist), cal.cellIndex(pos.Z+dist)
+
+	for x := minX; x <= maxX; x++ {
+		for z := minZ; z <= maxZ; z++ {
+			for other := range cal.cells[gridCell{x, z}] {
+				if other == aoi {
+					continue
+				}
+				dx, dz := other.pos.X-pos.X, other.pos.Z-pos.Z
+				if dx >= -dist && dx <= dist && dz >= -dist && dz <= dist {
+					f(other)
+				}
+			}
+		}
+	}
+}
+
+// VisitBox is called by Space to find aois within the box
+func (cal *GridAOICalculator) VisitBox(min, max Vector3, f func(aoi *aoi)) {
+	minX, maxX := cal.cellIndex(min.X), cal.cellIndex(max.X)
+	minZ, maxZ := cal.cellIndex(min.Z), cal.cellIndex(max.Z)
+	if float64(maxX-minX+1)*float64(maxZ-minZ+1) > float64(len(cal.cells)) {
+		// the box covers more cells than existing ones, so visit existing cells instead
+		for cell, aois := range cal.cells {
+			if cell.x >= minX && cell.x <= maxX && cell.z >= minZ && cell.z <= maxZ {
+				visitAOIsInBox(aois, min, max, f)
+			}
+		}
+		return
+	}
+
+	for x := minX; x <= maxX; x++ {
+		for z := minZ; z <= maxZ; z++ {
+			visitAOIsInBox(cal.cells[gridCell{x, z}], min, max, f)
+		}
+	}
+}
+
+func visitAOIsInBox(aois aoiSet, min, max Vector3, f func(aoi *aoi)) {
+	for aoi := range aois {
+		if aoi.pos.X >= min.X && aoi.pos.X <= max.X && aoi.pos.Z >= min.Z && aoi.pos.Z <= max.Z {
+			f(aoi)
+		}
+	}
+}
@@ -62,6 +62,7 @@ func (a *ListAttr) setOwner(owner *Entity, flag attrFlag) {
 
 // Set sets item value
 func (a *ListAttr) set(index int, val interface{}) {
+	oldVal := a.items[index]
 	a.items[index] = val
 	if sa, ok := val.(*MapAttr); ok {
 		// val is ListAttr, set parent and owner accordingly
@@ -81,6 +82,8 @@ func (a *ListAttr) set(index int, val interface{}) {
 	} else {
 		a.sendListAttrChangeToClients(index, val)
 	}
+
+	a.triggerAttrChange(index, oldVal, val)
 }
 
 func (a *ListAttr) sendListAttrChangeToClients(index int, val interface{}) {
@@ -103,6 +106,18 @@ func (a *ListAttr) sendListAttrAppendToClients(val interface{}) {
 	}
 }
 
+func (a *ListAttr) triggerAttrChange(index int, oldVal, newVal interface{}) {
+	if owner := a.owner; owner != nil {
+		parentPath := a.getPathFromOwner()
+		if oldVal == nil || newVal == nil {
+			owner.markAttrDirty(parentPath) // item is appended or popped, the whole list is dirty
+		} else {
+			owner.markAttrDirty(parentPath, index)
+		}
+		owner.onAttrChange(parentPath, index, oldVal, newVal)
+	}
+}
+
 func (a *ListAttr) getPathFromOwner() []interface{} {
 	if a.path == nil {
 		a.path = a._getPathFromOwner()
@@ -199,6 +214,7 @@ func (a *ListAttr) pop() interface{} {
 	}
 
 	a.sendListAttrPopToClients()
+	a.triggerAttrChange(size-1, val, nil)
 	return val
 }
 
@@ -255,6 +271,8 @@ func (a *ListAttr) append(val interface{}) {
 	} else {
 		a.sendListAttrAppendToClients(val)
 	}
+
+	a.triggerAttrChange(index, nil, val)
 }
 
 // SetInt sets int value at the index
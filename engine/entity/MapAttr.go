@@ -53,6 +53,10 @@ func (a *MapAttr) ForEach(f func(key string, val interface{})) {
 // Set sets the key-attribute pair in MapAttr
 func (a *MapAttr) set(key string, val interface{}) {
 	var flag attrFlag
+	if a.owner != nil && a == a.owner.Attrs { // check type of root attribute
+		val = a.owner.typeDesc.checkAttrValue(key, val)
+	}
+	oldVal := a.attrs[key]
 	a.attrs[key] = val
 	if sa, ok := val.(*MapAttr); ok {
 		// val is MapAttr, set parent and owner accordingly
@@ -83,6 +87,8 @@ func (a *MapAttr) set(key string, val interface{}) {
 	} else {
 		a.sendAttrChangeToClients(key, val)
 	}
+
+	a.triggerAttrChange(key, oldVal, val)
 }
 
 // SetInt sets int value at the key
@@ -170,6 +176,14 @@ func (a *MapAttr) sendAttrDelToClients(key string) {
 	}
 }
 
+func (a *MapAttr) triggerAttrChange(key string, oldVal, newVal interface{}) {
+	if a.owner != nil {
+		parentPath := a.getPathFromOwner()
+		a.owner.markAttrDirty(parentPath, key)
+		a.owner.onAttrChange(parentPath, key, oldVal, newVal)
+	}
+}
+
 func (a *MapAttr) getPathFromOwner() []interface{} {
 	if a.path == nil {
 		a.path = a._getPathFromOwner()
@@ -246,6 +260,7 @@ func (a *MapAttr) Pop(key string) interface{} {
 	}
 
 	a.sendAttrDelToClients(key)
+	a.triggerAttrChange(key, val, nil)
 	return val
 }
 
@@ -7,6 +7,7 @@ import (
 	"github.com/lovelly/goworld/engine/consts"
 	"github.com/lovelly/goworld/engine/gwlog"
 	"github.com/lovelly/goworld/engine/gwutils"
+	"github.com/xiaonanln/goTimer"
 )
 
 const (
@@ -26,10 +27,20 @@ var (
 type Space struct {
 	Entity
 
-	entities EntitySet
-	Kind     int
+	entities        EntitySet
+	historyEntities EntitySet // entities with position history in space
+	Kind            int
 	//I        ISpace
-	aoiCalc AOICalculator
+	aoiCalc        AOICalculator
+	aoiDistance    Coord         // default aoi distance of entities in space
+	maxAOIDistance Coord         // max aoi distance of entities in space
+	aoiHeight      Coord         // aoi height on Y axis of entities in space, 0 means Y axis is ignored
+	bounds         *[2]Vector3   // min and max positions of space, nil for no bounds
+	syncLODTiers   []SyncLODTier // sync level-of-detail tiers sorted by distance
+	policy         *SpaceKindPolicy
+	idleTimer      *timer.Timer // timer to destroy space when it is idle
+	destroying     bool
+	cell           *spaceCell // cell of partitioned space, nil for normal spaces
 }
 
 func (space *Space) String() string {
@@ -40,14 +51,19 @@ func (space *Space) String() string {
 }
 
 func (space *Space) DefineAttrs(desc *EntityTypeDesc) {
-	desc.DefineAttr(_SPACE_KIND_ATTR_KEY, "AllClients")
+	desc.DefineAttr(_SPACE_KIND_ATTR_KEY, "AllClients", "Persistent")
+	desc.DefineAttr(_SPACE_MEMBERS_ATTR_KEY, "Persistent", "Map")
+	desc.DefineAttr(_SPACE_CELL_ATTR_KEY, "Persistent")
 }
 
 // OnInit initialize Space entity
 func (space *Space) OnInit() {
 	space.entities = EntitySet{}
+	space.historyEntities = EntitySet{}
 	space.I = space.Entity.I
-	space.aoiCalc = newXZListAOICalculator()
+	space.aoiCalc = NewXZListAOICalculator()
+	space.aoiDistance = _DEFAULT_AOI_DISTANCE
+	space.maxAOIDistance = _DEFAULT_AOI_DISTANCE
 }
 
 // OnCreated is called when Space entity is created
@@ -61,6 +77,7 @@ func (space *Space) OnCreated() {
 	if consts.DEBUG_SPACES {
 		gwlog.Debugf("%s.OnCreated", space)
 	}
+	space.onPersistentSpaceCreated()
 	space.callCompositiveMethod("OnSpaceCreated")
 }
 
@@ -82,6 +99,9 @@ func (space *Space) onSpaceCreated() {
 		gwlog.Infof("Created nil space: %s", nilSpace)
 		return
 	}
+
+	space.onCellSpaceCreated()
+	space.onPolicySpaceCreated()
 }
 
 // OnSpaceCreated is called when space is created
@@ -95,7 +115,10 @@ func (space *Space) OnSpaceCreated() {
 
 // OnDestroy is called when Space entity is destroyed
 func (space *Space) OnDestroy() {
+	space.destroying = true
 	space.callCompositiveMethod("OnSpaceDestroy")
+	space.onPolicySpaceDestroy()
+	space.onCellSpaceDestroy()
 	// destroy all entities
 	for e := range space.entities {
 		e.Destroy()
@@ -113,6 +136,90 @@ func (space *Space) OnSpaceDestroy() {
 	}
 }
 
+// SetAOIDistance sets the default aoi distance of entities in space
+//
+// Entity types with aoi distance set by EntityTypeDesc.SetAOIDistance are not affected.
+// Should be called in OnSpaceCreated to configure aoi distance by space kind
+func (space *Space) SetAOIDistance(distance Coord) {
+	if distance <= 0 {
+		gwlog.Panicf("%s.SetAOIDistance: invalid distance %v", space, distance)
+	}
+
+	space.aoiDistance = distance
+	space.maxAOIDistance = distance
+	space.forEachAOIEntity(func(e *Entity) {
+		e.aoi.dist = space.getAOIDistanceOf(e)
+		if e.aoi.dist > space.maxAOIDistance {
+			space.maxAOIDistance = e.aoi.dist
+		}
+	})
+
+	// entities already in space should adjust neighbors
+	space.forEachAOIEntity(space.adjust)
+}
+
+// SetAOICalculator sets the AOICalculator used by space, e.g. NewGridAOICalculator(100)
+//
+// Should be called in OnSpaceCreated to choose aoi calculator by space kind.
+// Entities already in space are moved to the new calculator.
+func (space *Space) SetAOICalculator(cal AOICalculator) {
+	if cal == nil {
+		gwlog.Panicf("%s.SetAOICalculator: nil calculator", space)
+	}
+
+	space.forEachAOIEntity(func(e *Entity) {
+		space.aoiCalc.Leave(&e.aoi)
+		cal.Enter(&e.aoi, e.aoi.pos)
+	})
+	space.aoiCalc = cal
+}
+
+// forEachAOIEntity calls f for each entity in the aoi calculator of space, including ghosts if space is a partition cell
+func (space *Space) forEachAOIEntity(f func(e *Entity)) {
+	for e := range space.entities {
+		f(e)
+	}
+	if space.cell != nil {
+		for _, ghost := range space.cell.ghosts {
+			f(ghost)
+		}
+	}
+}
+
+// SetAOIHeight enables 3D aoi in space by setting the aoi height on Y axis
+//
+// Entities are only interested in entities whose Y coordinates are within the height,
+// so that entities on different floors or flying high above are not visible to each other.
+// Setting height to 0 disables 3D aoi, which is the default.
+func (space *Space) SetAOIHeight(height Coord) {
+	if height < 0 {
+		gwlog.Panicf("%s.SetAOIHeight: invalid height %v", space, height)
+	}
+
+	space.aoiHeight = height
+	space.forEachAOIEntity(func(e *Entity) {
+		e.aoi.height = height
+	})
+	space.forEachAOIEntity(space.adjust)
+}
+
+// GetAOIHeight returns the aoi height on Y axis of entities in space
+func (space *Space) GetAOIHeight() Coord {
+	return space.aoiHeight
+}
+
+// GetAOIDistance returns the default aoi distance of entities in space
+func (space *Space) GetAOIDistance() Coord {
+	return space.aoiDistance
+}
+
+func (space *Space) getAOIDistanceOf(entity *Entity) Coord {
+	if entity.typeDesc.aoiDistance > 0 {
+		return entity.typeDesc.aoiDistance
+	}
+	return space.aoiDistance
+}
+
 // IsNil checks if the space is the nil space
 func (space *Space) IsNil() bool {
 	return space.Kind == 0
@@ -146,33 +253,48 @@ func (space *Space) enter(entity *Entity, pos Vector3, isRestore bool) {
 	entity.Space = space
 	space.entities.Add(entity)
 
+	entity.aoi.dist = space.getAOIDistanceOf(entity)
+	entity.aoi.height = space.aoiHeight
+	if entity.aoi.dist > space.maxAOIDistance {
+		space.maxAOIDistance = entity.aoi.dist
+	}
 	space.aoiCalc.Enter(&entity.aoi, pos)
 	entity.syncInfoFlag |= sifSyncOwnClient | sifSyncNeighborClients
+	entity.resetClientMove()
+	entity.resetPositionHistory()
+	entity.enteringLastSpace = false
+	entity.handoverTo = ""
+	entity.updateLastSpace()
 
 	if !isRestore {
-		entity.client.sendCreateEntity(&space.Entity, false) // create Space entity before every other entities
-
-		enter, _ := space.aoiCalc.Adjust(&entity.aoi)
-		// gwlog.Infof("Entity %s entering at pos %v: %v: enter %d neighbors", entity, pos, entity.GetPosition(), len(enter))
+		space.replaceGhostOf(entity)
+		entity.showSpaceOnClient(space) // create Space entity before every other entities
 
-		for _, naoi := range enter {
-			neighbor := naoi.getEntity()
-			entity.interest(neighbor)
-			neighbor.interest(entity)
-		}
+		space.adjust(entity)
+		flushClientKeeps(nil)
 
 		gwutils.RunPanicless(func() {
 			space.callCompositiveMethod("OnEntityEnterSpace", entity)
 			entity.callCompositiveMethod("OnEnterSpace")
 		})
 	} else {
-		enter, _ := space.aoiCalc.Adjust(&entity.aoi)
+		// clients already have the entities, so just restore interests without notifying clients
+		enter, _, enterBy, _ := space.adjustAOI(entity)
 		for _, naoi := range enter {
-			neighbor := naoi.getEntity()
-			entity.aoi.interest(neighbor)
-			neighbor.aoi.interest(entity)
+			entity.aoi.interest(naoi.getEntity())
+			if entity.aoi.canShow() {
+				entity.aoi.show(naoi.getEntity())
+			}
+		}
+		for _, naoi := range enterBy {
+			naoi.interest(entity)
+			if naoi.canShow() {
+				naoi.show(entity)
+			}
 		}
 	}
+	space.onPersistentMemberEnter(entity)
+	space.onPolicyEntitiesChanged(entity)
 
 	//space.verifyAOICorrectness(entity)
 }
@@ -186,15 +308,31 @@ func (space *Space) leave(entity *Entity) {
 		return
 	}
 
+	// entities are kept on clients if entity is handed over to the neighbor cell of partitioned space
+	handover := space.cell != nil && !entity.handoverTo.IsNil()
+	keepingOnClient = handover
 	for neighbor := range entity.aoi.neighbors {
 		entity.uninterest(neighbor)
-		neighbor.uninterest(entity)
 	}
+	for other := range entity.aoi.interestedBy {
+		other.uninterest(entity)
+	}
+	entity.updateLastSpace()
+	pos := entity.aoi.pos
 	space.aoiCalc.Leave(&entity.aoi)
-	entity.client.sendDestroyEntity(&space.Entity)
+	entity.hideSpaceOnClient(space)
+	keepingOnClient = false
+	if handover {
+		space.leaveGhostOf(entity, pos)
+		flushClientKeeps(entity)
+		entity.handoverTo = ""
+	}
 	// remove from Space entities
 	space.entities.Del(entity)
+	space.historyEntities.Del(entity)
 	entity.Space = nilSpace
+	space.onPersistentMemberLeave(entity)
+	space.onPolicyEntitiesChanged(entity)
 
 	space.callCompositiveMethod("OnEntityLeaveSpace", entity)
 	entity.callCompositiveMethod("OnLeaveSpace", space)
@@ -206,22 +344,40 @@ func (space *Space) move(entity *Entity, newPos Vector3) {
 	}
 
 	space.aoiCalc.Move(&entity.aoi, newPos)
-	enter, leave := space.aoiCalc.Adjust(&entity.aoi)
+	space.adjust(entity)
+
+	//space.verifyAOICorrectness(entity)
+	//opmon.Finish(time.Millisecond * 10)
+}
+
+// adjust updates interests between the entity and other entities in space
+// adjustAOI calculates the changes of interests of entity using the AOICalculator of space, see adjustAOI
+func (space *Space) adjustAOI(entity *Entity) (enter, leave, enterBy, leaveBy []*aoi) {
+	if visitor, ok := space.aoiCalc.(AOIVisitor); ok {
+		return adjustAOI(visitor, &entity.aoi, space.maxAOIDistance)
+	}
+
+	// neighbors are mutual if the calculator can not visit aois in range
+	enter, leave = space.aoiCalc.Adjust(&entity.aoi)
+	return enter, leave, enter, leave
+}
+
+func (space *Space) adjust(entity *Entity) {
+	enter, leave, enterBy, leaveBy := space.adjustAOI(entity)
 
 	for _, naoi := range leave {
-		neighbor := naoi.getEntity()
-		entity.uninterest(neighbor)
-		neighbor.uninterest(entity)
+		entity.uninterest(naoi.getEntity())
+	}
+	for _, naoi := range leaveBy {
+		naoi.getEntity().uninterest(entity)
 	}
 
 	for _, naoi := range enter {
-		neighbor := naoi.getEntity()
-		entity.interest(neighbor)
-		neighbor.interest(entity)
+		entity.interest(naoi.getEntity())
+	}
+	for _, naoi := range enterBy {
+		naoi.getEntity().interest(entity)
 	}
-
-	//space.verifyAOICorrectness(entity)
-	//opmon.Finish(time.Millisecond * 10)
 }
 
 //func (space *Space) verifyAOICorrectness(entity *Entity) {
@@ -34,9 +34,11 @@ func (spmgr *_SpaceManager) getSpace(id common.EntityID) *Space {
 }
 
 // RegisterSpace registers the user custom space type
+//
+// Space type can define persistent attributes, which are saved only for spaces of kinds set by SetSpaceKindPersistent
 func RegisterSpace(spacePtr IEntity) {
 	spaceVal := reflect.Indirect(reflect.ValueOf(spacePtr))
 	spaceType = spaceVal.Type()
 
-	RegisterEntity(_SPACE_ENTITY_TYPE, spacePtr, false, false)
+	RegisterEntity(_SPACE_ENTITY_TYPE, spacePtr, true, false)
 }
@@ -0,0 +1,181 @@
+package entity
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/lovelly/goworld/engine/common"
+)
+
+type testAOIEntity struct {
+	Entity
+}
+
+func (e *testAOIEntity) DefineAttrs(desc *EntityTypeDesc) {
+}
+
+type testAOIBossEntity struct {
+	Entity
+}
+
+func (e *testAOIBossEntity) DefineAttrs(desc *EntityTypeDesc) {
+}
+
+func newTestSpace(kind int) *Space {
+	if _, ok := registeredEntityTypes[_SPACE_ENTITY_TYPE]; !ok {
+		RegisterSpace(&Space{})
+	}
+	instance := reflect.New(registeredEntityTypes[_SPACE_ENTITY_TYPE].entityType)
+	space := instance.Interface().(*Space)
+	space.init(_SPACE_ENTITY_TYPE, common.GenEntityID(), instance)
+	space.Kind = kind
+	return space
+}
+
+func newTestAOIEntity(typeName string) *Entity {
+	if _, ok := registeredEntityTypes["testAOIEntity"]; !ok {
+		RegisterEntity("testAOIEntity", &testAOIEntity{}, false, true)
+		RegisterEntity("testAOIBossEntity", &testAOIBossEntity{}, false, true).SetAOIDistance(300)
+	}
+	return newTestEntity(typeName, nil, false, true)
+}
+
+// verifyAOI checks neighbors and interestedBy of all entities in space against brute force results
+func verifyAOI(t *testing.T, space *Space) {
+	for e := range space.entities {
+		for other := range space.entities {
+			if other == e {
+				continue
+			}
+			inRange := e.aoi.isInRange(other.aoi.pos)
+			if e.aoi.neighbors.Contains(other) != inRange {
+				t.Fatalf("%s: neighbor %s should be %v: pos=%v, %v, dist=%v", e, other, inRange, e.aoi.pos, other.aoi.pos, e.aoi.dist)
+			}
+			if other.aoi.interestedBy.Contains(e) != inRange {
+				t.Fatalf("%s: interested by %s should be %v", other, e, inRange)
+			}
+			if e.aoi.maxVisible == 0 && (e.aoi.visible.Contains(other) != inRange || other.aoi.visibleBy.Contains(e) != inRange) {
+				t.Fatalf("%s: visible %s should be %v", e, other, inRange)
+			}
+		}
+	}
+}
+
+func TestAsymmetricAOI(t *testing.T) {
+	space := newTestSpace(1)
+	space.SetAOIDistance(50)
+
+	player1 := newTestAOIEntity("testAOIEntity")
+	player2 := newTestAOIEntity("testAOIEntity")
+	boss := newTestAOIEntity("testAOIBossEntity")
+	space.enter(player1, Vector3{0, 0, 0}, false)
+	space.enter(player2, Vector3{30, 0, 0}, false)
+	space.enter(boss, Vector3{200, 0, 0}, false)
+
+	if !player1.Neighbors().Contains(player2) || !player2.Neighbors().Contains(player1) {
+		t.Errorf("players should see each other")
+	}
+	if !boss.Neighbors().Contains(player1) || !boss.Neighbors().Contains(player2) {
+		t.Errorf("boss should see players")
+	}
+	if player1.Neighbors().Contains(boss) || player2.Neighbors().Contains(boss) {
+		t.Errorf("players should not see boss")
+	}
+	if !player1.InterestedBy().Contains(boss) || boss.InterestedBy().Contains(player1) {
+		t.Errorf("wrong interested by")
+	}
+
+	space.move(player2, Vector3{180, 0, 0})
+	if player1.Neighbors().Contains(player2) || !player2.Neighbors().Contains(boss) || !boss.Neighbors().Contains(player2) {
+		t.Errorf("wrong neighbors after move")
+	}
+	verifyAOI(t, space)
+
+	space.SetAOIDistance(400)
+	if !player1.Neighbors().Contains(boss) || !player1.Neighbors().Contains(player2) {
+		t.Errorf("players should see boss after space aoi distance changed")
+	}
+	if boss.aoi.dist != 300 {
+		t.Errorf("aoi distance of boss should not be changed by space")
+	}
+	verifyAOI(t, space)
+
+	space.leave(boss)
+	if len(boss.Neighbors()) != 0 || len(boss.InterestedBy()) != 0 || player1.Neighbors().Contains(boss) {
+		t.Errorf("interests should be cleared after leaving space")
+	}
+}
+
+func TestAsymmetricAOIRandom(t *testing.T) {
+	space := newTestSpace(1)
+	var entities []*Entity
+	for i := 0; i < 100; i++ {
+		typeName := "testAOIEntity"
+		if i%10 == 0 {
+			typeName = "testAOIBossEntity"
+		}
+		e := newTestAOIEntity(typeName)
+		space.enter(e, Vector3{Coord(rand.Float32() * 1000), 0, Coord(rand.Float32() * 1000)}, false)
+		entities = append(entities, e)
+	}
+	verifyAOI(t, space)
+
+	for i := 0; i < 1000; i++ {
+		e := entities[rand.Intn(len(entities))]
+		space.move(e, Vector3{e.aoi.pos.X + Coord(rand.Float32()*200-100), 0, e.aoi.pos.Z + Coord(rand.Float32()*200-100)})
+	}
+	verifyAOI(t, space)
+}
+
+func TestAOIHeight(t *testing.T) {
+	space := newTestSpace(1)
+	space.SetAOIHeight(10)
+
+	e1 := newTestAOIEntity("testAOIEntity")
+	e2 := newTestAOIEntity("testAOIEntity")
+	space.enter(e1, Vector3{0, 0, 0}, false)
+	space.enter(e2, Vector3{0, 50, 0}, false)
+	if e1.Neighbors().Contains(e2) || e2.Neighbors().Contains(e1) {
+		t.Errorf("entities on different floors should not see each other")
+	}
+
+	space.move(e2, Vector3{0, 10, 0})
+	if !e1.Neighbors().Contains(e2) || !e2.Neighbors().Contains(e1) {
+		t.Errorf("entities within aoi height should see each other")
+	}
+
+	space.move(e2, Vector3{0, -11, 0})
+	if e1.Neighbors().Contains(e2) || e2.Neighbors().Contains(e1) {
+		t.Errorf("entities out of aoi height should not see each other")
+	}
+
+	space.SetAOIHeight(0)
+	if !e1.Neighbors().Contains(e2) || !e2.Neighbors().Contains(e1) {
+		t.Errorf("Y axis should be ignored if aoi height is 0")
+	}
+}
+
+func TestAOIHeightRandom(t *testing.T) {
+	for _, cal := range []AOICalculator{NewXZListAOICalculator(), NewGridAOICalculator(_DEFAULT_AOI_DISTANCE)} {
+		space := newTestSpace(1)
+		space.SetAOICalculator(cal)
+		space.SetAOIHeight(30)
+
+		randPos := func() Vector3 {
+			return Vector3{Coord(rand.Float32() * 500), Coord(rand.Intn(5) * 20), Coord(rand.Float32() * 500)}
+		}
+		var entities []*Entity
+		for i := 0; i < 100; i++ {
+			e := newTestAOIEntity("testAOIEntity")
+			space.enter(e, randPos(), false)
+			entities = append(entities, e)
+		}
+		verifyAOI(t, space)
+
+		for i := 0; i < 1000; i++ {
+			space.move(entities[rand.Intn(len(entities))], randPos())
+		}
+		verifyAOI(t, space)
+	}
+}
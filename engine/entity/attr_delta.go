@@ -0,0 +1,108 @@
+package entity
+
+// attrDeltaKey identifies the attribute changed by a delta
+type attrDeltaKey struct {
+	parent interface{} // *MapAttr or *ListAttr containing the changed attribute
+	key    interface{} // string for MapAttr, int for ListAttr
+}
+
+// attrDelta is a pending attribute change to be sent to clients
+type attrDelta struct {
+	path       []interface{} // path of the parent attribute (from leaf to root)
+	key        interface{}
+	val        interface{}
+	del        bool
+	allClients bool // should be sent to neighbor clients too
+}
+
+// attrDeltaQueue collects attribute changes of an entity in one tick
+type attrDeltaQueue struct {
+	deltas  []*attrDelta
+	indices map[attrDeltaKey]int
+}
+
+// pendingAttrDeltaEntities are entities with attribute changes to be flushed
+var pendingAttrDeltaEntities = EntitySet{}
+
+// SetAttrDeltaSync sets if attribute changes of the entity type are sent to clients in batch
+//
+// If enabled, attribute changes in one tick are sent to each client in one MT_NOTIFY_ATTR_DELTA_ON_CLIENT message,
+// and repeated changes of the same attribute only send the last value
+func (desc *EntityTypeDesc) SetAttrDeltaSync(enable bool) {
+	desc.attrDeltaSync = enable
+}
+
+// queueAttrDelta queues the attribute change to be sent in next flush
+func (e *Entity) queueAttrDelta(parent interface{}, path []interface{}, key interface{}, val interface{}, del bool, flag attrFlag) {
+	q := e.attrDeltas
+	if q == nil {
+		q = &attrDeltaQueue{indices: map[attrDeltaKey]int{}}
+		e.attrDeltas = q
+		pendingAttrDeltaEntities.Add(e)
+	}
+
+	dk := attrDeltaKey{parent, key}
+	if i, ok := q.indices[dk]; ok {
+		// the last change wins, and is moved to the end to keep the order with changes of other attributes
+		q.deltas[i] = nil
+	}
+	q.indices[dk] = len(q.deltas)
+	q.deltas = append(q.deltas, &attrDelta{
+		path:       path,
+		key:        key,
+		val:        val,
+		del:        del,
+		allClients: flag&afAllClient != 0,
+	})
+}
+
+// queueListAttrResized queues the whole ListAttr as changed, since appending and popping can not be collapsed
+func (e *Entity) queueListAttrResized(la *ListAttr, flag attrFlag) {
+	path := la.getPathFromOwner()
+	e.queueAttrDelta(la.parent, path[1:], path[0], la, false, flag)
+}
+
+// flushAttrDeltas sends pending attribute changes to clients
+func (e *Entity) flushAttrDeltas() {
+	q := e.attrDeltas
+	if q == nil {
+		return
+	}
+	e.attrDeltas = nil
+	pendingAttrDeltaEntities.Del(e)
+
+	var ownOps, allOps []interface{}
+	for _, delta := range q.deltas {
+		if delta == nil {
+			continue
+		}
+
+		var op []interface{}
+		if delta.del {
+			op = []interface{}{delta.path, delta.key}
+		} else {
+			op = []interface{}{delta.path, delta.key, attrValueToData(delta.val)}
+		}
+		ownOps = append(ownOps, op)
+		if delta.allClients {
+			allOps = append(allOps, op)
+		}
+	}
+
+	if len(ownOps) > 0 {
+		e.client.sendNotifyAttrDelta(e.ID, ownOps)
+	}
+	if len(allOps) > 0 {
+		for neighbor := range e.aoi.visibleBy {
+			neighbor.client.sendNotifyAttrDelta(e.ID, allOps)
+		}
+		e.forwardToGhosts("SyncGhostAttrs", allOps)
+	}
+}
+
+// FlushAttrDeltas sends attribute changes of all entities in batch, called by engine in each tick
+func FlushAttrDeltas() {
+	for e := range pendingAttrDeltaEntities {
+		e.flushAttrDeltas()
+	}
+}
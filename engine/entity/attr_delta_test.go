@@ -0,0 +1,68 @@
+package entity
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+type testAttrDeltaEntity struct {
+	Entity
+}
+
+func (e *testAttrDeltaEntity) DefineAttrs(desc *EntityTypeDesc) {
+	desc.DefineAttr("hp", "AllClients")
+	desc.DefineAttr("bag", "Client")
+	desc.DefineAttr("buffs", "AllClients")
+	desc.DefineAttr("secret")
+	desc.SetAttrDeltaSync(true)
+}
+
+func pendingAttrDeltas(e *Entity) []string {
+	var res []string
+	for _, delta := range e.attrDeltas.deltas {
+		if delta == nil {
+			continue
+		}
+		if delta.del {
+			res = append(res, fmt.Sprintf("del %v %v all=%v", delta.path, delta.key, delta.allClients))
+		} else {
+			res = append(res, fmt.Sprintf("set %v %v=%v all=%v", delta.path, delta.key, attrValueToData(delta.val), delta.allClients))
+		}
+	}
+	return res
+}
+
+func TestAttrDeltaSync(t *testing.T) {
+	e := newTestEntity("testAttrDeltaEntity", &testAttrDeltaEntity{}, false, false).I.(*testAttrDeltaEntity)
+	e.Attrs.SetInt("hp", 100)
+	e.Attrs.SetMapAttr("bag", NewMapAttr())
+	e.Attrs.SetListAttr("buffs", NewListAttr())
+	e.Attrs.SetInt("secret", 1) // not synced to clients
+	e.Attrs.GetMapAttr("bag").SetInt("sword", 1)
+	e.Attrs.GetMapAttr("bag").SetInt("sword", 2)
+	e.Attrs.GetListAttr("buffs").AppendStr("haste")
+	e.Attrs.GetListAttr("buffs").AppendStr("shield")
+	e.Attrs.GetListAttr("buffs").SetStr(0, "slow")
+	e.Attrs.GetMapAttr("bag").Del("sword")
+	e.Attrs.SetInt("hp", 90)
+
+	expect := []string{
+		"set [] bag=map[] all=false",
+		"set [] buffs=[slow shield] all=true",
+		"set [buffs] 0=slow all=true",
+		"del [bag] sword all=false",
+		"set [] hp=90 all=true",
+	}
+	if deltas := pendingAttrDeltas(&e.Entity); !reflect.DeepEqual(deltas, expect) {
+		t.Errorf("pending deltas should be %v, but got %v", expect, deltas)
+	}
+	if !pendingAttrDeltaEntities.Contains(&e.Entity) {
+		t.Errorf("entity should be pending")
+	}
+
+	FlushAttrDeltas()
+	if e.attrDeltas != nil || pendingAttrDeltaEntities.Contains(&e.Entity) {
+		t.Errorf("deltas should be flushed")
+	}
+}
@@ -0,0 +1,155 @@
+package entity
+
+import (
+	"strconv"
+
+	"github.com/lovelly/goworld/engine/storage/storage_common"
+)
+
+// dirtyAttrNode records the dirty persistent attribute paths of an entity as a tree
+type dirtyAttrNode struct {
+	whole    bool                           // the whole sub-document is dirty
+	children map[interface{}]*dirtyAttrNode // dirty children, keys are string for MapAttr and int for ListAttr
+}
+
+func (n *dirtyAttrNode) isClean() bool {
+	return n == nil || (!n.whole && len(n.children) == 0)
+}
+
+// mark marks the attribute at the path (from root to leaf) as dirty
+func (n *dirtyAttrNode) mark(path []interface{}) {
+	for _, key := range path {
+		if n.whole {
+			return // already covered by the dirty parent
+		}
+		if n.children == nil {
+			n.children = map[interface{}]*dirtyAttrNode{}
+		}
+		child := n.children[key]
+		if child == nil {
+			child = &dirtyAttrNode{}
+			n.children[key] = child
+		}
+		n = child
+	}
+	n.whole = true
+	n.children = nil
+}
+
+// markAttrDirty marks the persistent attribute as dirty
+//
+// parentPath is the path of the parent attribute returned by getPathFromOwner (from leaf to root),
+// and keys are appended to the parent path
+func (e *Entity) markAttrDirty(parentPath []interface{}, keys ...interface{}) {
+	if !e.typeDesc.isPersistent || e.saveAll {
+		return
+	}
+
+	path := make([]interface{}, 0, len(parentPath)+len(keys))
+	for i := len(parentPath) - 1; i >= 0; i-- {
+		path = append(path, parentPath[i])
+	}
+	path = append(path, keys...)
+	if len(path) == 0 {
+		return
+	}
+
+	if rootAttr, ok := path[0].(string); !ok || !e.typeDesc.persistentAttrs.Contains(rootAttr) {
+		return
+	}
+
+	if e.dirtyAttrs == nil {
+		e.dirtyAttrs = &dirtyAttrNode{}
+	}
+	e.dirtyAttrs.mark(path)
+}
+
+// clearDirtyAttrs marks all attributes as clean
+func (e *Entity) clearDirtyAttrs() {
+	e.dirtyAttrs = nil
+	e.saveAll = false
+}
+
+// isDirty returns if persistent attributes are changed since last save
+func (e *Entity) isDirty() bool {
+	return e.saveAll || !e.dirtyAttrs.isClean()
+}
+
+// getPersistentDataPatch returns changes of persistent attributes since last save
+//
+// If rootOnly is true, the patch contains whole root attributes only
+func (e *Entity) getPersistentDataPatch(rootOnly bool) *storagecommon.DataPatch {
+	patch := &storagecommon.DataPatch{}
+	if e.dirtyAttrs.isClean() {
+		return patch
+	}
+
+	var visit func(n *dirtyAttrNode, val interface{}, exists bool, path []string)
+	visit = func(n *dirtyAttrNode, val interface{}, exists bool, path []string) {
+		if n.whole || (rootOnly && len(path) == 1) {
+			if !exists {
+				patch.Unset = append(patch.Unset, path)
+				return
+			}
+			patch.Set = append(patch.Set, storagecommon.PatchEntry{Path: path, Value: attrValueToData(val)})
+			return
+		}
+
+		for key, child := range n.children {
+			childPath := make([]string, len(path), len(path)+1)
+			copy(childPath, path)
+			childVal, childExists := getChildAttr(val, key)
+			if k, ok := key.(int); ok {
+				childPath = append(childPath, strconv.Itoa(k))
+			} else {
+				childPath = append(childPath, key.(string))
+			}
+			visit(child, childVal, childExists, childPath)
+		}
+	}
+
+	visit(e.dirtyAttrs, e.Attrs, true, nil)
+	return patch
+}
+
+func getChildAttr(val interface{}, key interface{}) (interface{}, bool) {
+	switch a := val.(type) {
+	case *MapAttr:
+		if k, ok := key.(string); ok {
+			v, exists := a.attrs[k]
+			return v, exists
+		}
+	case *ListAttr:
+		if k, ok := key.(int); ok && k >= 0 && k < len(a.items) {
+			return a.items[k], true
+		}
+	}
+	return nil, false
+}
+
+// attrDataToValue converts native map or slice data to MapAttr or ListAttr, reverse of attrValueToData
+func attrDataToValue(data interface{}) interface{} {
+	switch d := data.(type) {
+	case map[string]interface{}:
+		a := NewMapAttr()
+		a.AssignMap(d)
+		return a
+	case []interface{}:
+		a := NewListAttr()
+		a.AssignList(d)
+		return a
+	default:
+		return data
+	}
+}
+
+func attrValueToData(val interface{}) interface{} {
+	switch a := val.(type) {
+	case *MapAttr:
+		return a.ToMap()
+	case *ListAttr:
+		return a.ToList()
+	default:
+		return val
+	}
+}
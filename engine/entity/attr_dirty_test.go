@@ -0,0 +1,110 @@
+package entity
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/lovelly/goworld/engine/storage/storage_common"
+)
+
+type testDirtyAttrEntity struct {
+	Entity
+}
+
+func (e *testDirtyAttrEntity) DefineAttrs(desc *EntityTypeDesc) {
+	desc.DefineAttr("level", "Persistent")
+	desc.DefineAttr("bag", "Persistent")
+	desc.DefineAttr("quests", "Persistent")
+	desc.DefineAttr("online")
+}
+
+func newTestDirtyAttrEntity() *testDirtyAttrEntity {
+	e := newTestEntity("testDirtyAttrEntity", &testDirtyAttrEntity{}, true, false).I.(*testDirtyAttrEntity)
+	e.loadPersistentData(map[string]interface{}{
+		"level":  1,
+		"bag":    map[string]interface{}{"sword": 1, "potions": map[string]interface{}{"small": 3, "large": 1}},
+		"quests": []interface{}{"q1", "q2"},
+	})
+	e.clearDirtyAttrs()
+	return e
+}
+
+func patchToStrings(patch *storagecommon.DataPatch) []string {
+	var res []string
+	for _, entry := range patch.Set {
+		res = append(res, fmt.Sprintf("set %v=%v", entry.Path, entry.Value))
+	}
+	for _, path := range patch.Unset {
+		res = append(res, fmt.Sprintf("unset %v", path))
+	}
+	sort.Strings(res)
+	return res
+}
+
+func TestDirtyAttrs(t *testing.T) {
+	e := newTestDirtyAttrEntity()
+	if e.isDirty() {
+		t.Fatalf("entity should be clean after loaded")
+	}
+
+	e.Attrs.SetBool("online", true) // not persistent
+	if e.isDirty() {
+		t.Fatalf("entity should be clean if only non-persistent attributes are changed")
+	}
+
+	bag := e.Attrs.GetMapAttr("bag")
+	bag.SetInt("sword", 2)
+	bag.GetMapAttr("potions").SetInt("small", 2)
+	bag.GetMapAttr("potions").Del("large")
+	e.Attrs.GetListAttr("quests").SetStr(1, "q3")
+
+	expect := []string{
+		"set [bag potions small]=2",
+		"set [bag sword]=2",
+		"set [quests 1]=q3",
+		"unset [bag potions large]",
+	}
+	if patch := patchToStrings(e.getPersistentDataPatch(false)); !reflect.DeepEqual(patch, expect) {
+		t.Errorf("patch should be %v, but got %v", expect, patch)
+	}
+
+	expect = []string{
+		"set [bag]=map[potions:map[small:2] sword:2]",
+		"set [quests]=[q1 q3]",
+	}
+	if patch := patchToStrings(e.getPersistentDataPatch(true)); !reflect.DeepEqual(patch, expect) {
+		t.Errorf("root patch should be %v, but got %v", expect, patch)
+	}
+
+	// dirty parent covers dirty children
+	e.clearDirtyAttrs()
+	bag.GetMapAttr("potions").SetInt("small", 1)
+	bag.Del("potions")
+	e.Attrs.GetListAttr("quests").AppendStr("q4")
+	e.Attrs.GetListAttr("quests").SetStr(0, "q0")
+	e.Attrs.Del("level")
+
+	expect = []string{
+		"set [quests]=[q0 q3 q4]",
+		"unset [bag potions]",
+		"unset [level]",
+	}
+	if patch := patchToStrings(e.getPersistentDataPatch(false)); !reflect.DeepEqual(patch, expect) {
+		t.Errorf("patch should be %v, but got %v", expect, patch)
+	}
+
+	// apply the patch to the saved data
+	saved := map[string]interface{}{
+		"level":  int64(1),
+		"bag":    map[string]interface{}{"sword": int64(2), "potions": map[string]interface{}{"small": int64(2)}},
+		"quests": []interface{}{"q1", "q3"},
+	}
+	if err := storagecommon.ApplyDataPatch(saved, e.getPersistentDataPatch(false)); err != nil {
+		t.Fatal(err)
+	}
+	if data := e.getPersistentData(); !reflect.DeepEqual(saved, data) {
+		t.Errorf("patched data should be %v, but got %v", data, saved)
+	}
+}
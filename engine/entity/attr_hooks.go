@@ -0,0 +1,75 @@
+package entity
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/lovelly/goworld/engine/common"
+)
+
+const (
+	_ATTR_CHANGE_HOOK_PREFIX = "OnAttrChange_"
+	_ATTRS_CHANGED_HOOK      = "OnAttrsChanged"
+)
+
+// attrHooks records which attribute change hooks are defined by the entity type and its components
+type attrHooks struct {
+	rootAttrs    common.StringSet // root attributes which have OnAttrChange_<name> hooks
+	attrsChanged bool             // OnAttrsChanged is defined
+}
+
+func (hooks *attrHooks) isEmpty() bool {
+	return len(hooks.rootAttrs) == 0 && !hooks.attrsChanged
+}
+
+// collectAttrHooks collects attribute change hooks of entity type and its components
+func collectAttrHooks(entityType reflect.Type) *attrHooks {
+	hooks := &attrHooks{rootAttrs: common.StringSet{}}
+	visit := func(ptrType reflect.Type) {
+		for i := 0; i < ptrType.NumMethod(); i++ {
+			methodName := ptrType.Method(i).Name
+			if strings.HasPrefix(methodName, _ATTR_CHANGE_HOOK_PREFIX) {
+				hooks.rootAttrs.Add(methodName[len(_ATTR_CHANGE_HOOK_PREFIX):])
+			} else if methodName == _ATTRS_CHANGED_HOOK {
+				hooks.attrsChanged = true
+			}
+		}
+	}
+
+	visit(reflect.PtrTo(entityType))
+	for fi := 0; fi < entityType.NumField(); fi++ {
+		field := entityType.Field(fi)
+		if isComponentType(field.Type) {
+			visit(reflect.PtrTo(field.Type))
+		}
+	}
+	return hooks
+}
+
+// onAttrChange triggers attribute change hooks of the entity
+//
+// parentPath is the path of the parent attribute returned by getPathFromOwner (from leaf to root)
+func (e *Entity) onAttrChange(parentPath []interface{}, key interface{}, old, new interface{}) {
+	hooks := e.typeDesc.attrHooks
+	if !e.attrsReady || hooks.isEmpty() {
+		return
+	}
+
+	// path from the root attribute to the changed attribute
+	path := make([]interface{}, len(parentPath)+1)
+	for i, k := range parentPath {
+		path[len(parentPath)-1-i] = k
+	}
+	path[len(parentPath)] = key
+
+	if path[0] == _PERSISTENT_TIMERS_ATTR_KEY {
+		return // persistent timers are not attributes for users
+	}
+
+	if rootAttr, ok := path[0].(string); ok && hooks.rootAttrs.Contains(rootAttr) {
+		e.callCompositiveMethod(_ATTR_CHANGE_HOOK_PREFIX+rootAttr, path, old, new)
+	}
+	if hooks.attrsChanged {
+		e.callCompositiveMethod(_ATTRS_CHANGED_HOOK, path, old, new)
+	}
+}
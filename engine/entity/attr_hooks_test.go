@@ -0,0 +1,70 @@
+package entity
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+type testAttrHookEntity struct {
+	Entity
+	testAttrHookComponent
+
+	hpChanges []string
+}
+
+type testAttrHookComponent struct {
+	Component
+
+	changes []string
+}
+
+func (e *testAttrHookEntity) DefineAttrs(desc *EntityTypeDesc) {}
+
+func (e *testAttrHookEntity) OnAttrChange_hp(path []interface{}, old, new interface{}) {
+	e.hpChanges = append(e.hpChanges, fmt.Sprintf("%v:%v->%v", path, old, new))
+}
+
+func (c *testAttrHookComponent) OnAttrsChanged(path []interface{}, old, new interface{}) {
+	if _, ok := new.(*MapAttr); ok {
+		new = "MapAttr"
+	} else if _, ok := new.(*ListAttr); ok {
+		new = "ListAttr"
+	}
+	c.changes = append(c.changes, fmt.Sprintf("%v:%v->%v", path, old, new))
+}
+
+func TestAttrHooks(t *testing.T) {
+	e := newTestEntity("testAttrHookEntity", &testAttrHookEntity{}, false, false).I.(*testAttrHookEntity)
+	e.Attrs.SetInt("hp", 100) // attrs not ready, no hooks
+	e.attrsReady = true
+
+	e.Attrs.SetInt("hp", 90)
+	e.Attrs.SetMapAttr("bag", NewMapAttr())
+	e.Attrs.GetMapAttr("bag").SetInt("sword", 1)
+	e.Attrs.GetMapAttr("bag").SetListAttr("potions", NewListAttr())
+	potions := e.Attrs.GetMapAttr("bag").GetListAttr("potions")
+	potions.AppendStr("small")
+	potions.SetStr(0, "large")
+	potions.PopStr()
+	e.Attrs.Del("hp")
+
+	expectHpChanges := []string{"[hp]:100->90", "[hp]:90-><nil>"}
+	if !reflect.DeepEqual(e.hpChanges, expectHpChanges) {
+		t.Errorf("hp changes should be %v, but got %v", expectHpChanges, e.hpChanges)
+	}
+
+	expectChanges := []string{
+		"[hp]:100->90",
+		"[bag]:<nil>->MapAttr",
+		"[bag sword]:<nil>->1",
+		"[bag potions]:<nil>->ListAttr",
+		"[bag potions 0]:<nil>->small",
+		"[bag potions 0]:small->large",
+		"[bag potions 0]:large-><nil>",
+		"[hp]:90-><nil>",
+	}
+	if !reflect.DeepEqual(e.changes, expectChanges) {
+		t.Errorf("changes should be %v, but got %v", expectChanges, e.changes)
+	}
+}
@@ -0,0 +1,365 @@
+package entity
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/lovelly/goworld/engine/gwlog"
+)
+
+// AttrType is the declared type of attribute
+type AttrType int
+
+const (
+	// AttrTypeAny is the type of attributes defined without type, values of any type can be set
+	AttrTypeAny AttrType = iota
+	// AttrTypeInt is the type of int attributes, values are stored as int64
+	AttrTypeInt
+	// AttrTypeFloat is the type of float attributes, values are stored as float64
+	AttrTypeFloat
+	// AttrTypeStr is the type of string attributes
+	AttrTypeStr
+	// AttrTypeBool is the type of bool attributes
+	AttrTypeBool
+	// AttrTypeMap is the type of MapAttr attributes
+	AttrTypeMap
+	// AttrTypeList is the type of ListAttr attributes
+	AttrTypeList
+)
+
+var attrTypeNames = map[string]AttrType{
+	"int":   AttrTypeInt,
+	"float": AttrTypeFloat,
+	"str":   AttrTypeStr,
+	"bool":  AttrTypeBool,
+	"map":   AttrTypeMap,
+	"list":  AttrTypeList,
+}
+
+func (t AttrType) String() string {
+	for name, at := range attrTypeNames {
+		if at == t {
+			return name
+		}
+	}
+	return "any"
+}
+
+// schemaType returns the schema type name of attribute type
+func (t AttrType) schemaType() string {
+	switch t {
+	case AttrTypeInt:
+		return "int"
+	case AttrTypeFloat:
+		return "float"
+	case AttrTypeStr:
+		return "string"
+	case AttrTypeBool:
+		return "bool"
+	case AttrTypeMap:
+		return "map<string,any>"
+	case AttrTypeList:
+		return "list<any>"
+	default:
+		return "any"
+	}
+}
+
+// AttrDesc is the description of an attribute defined by DefineAttr
+type AttrDesc struct {
+	name       string
+	typ        AttrType
+	hasDefault bool
+	defaultVal interface{}        // default value, coerced to the attribute type
+	defaultGen func() interface{} // generates default value for each entity
+}
+
+// Type returns the declared type of attribute
+func (ad *AttrDesc) Type() AttrType {
+	return ad.typ
+}
+
+// Default sets the default value of attribute
+//
+// Default value is assigned to entities which are created or loaded without the attribute.
+// For map and list attributes, the default value should be map[string]interface{} or []interface{} and is copied for each entity.
+// A func() interface{} can also be used to generate different default values for each entity.
+func (ad *AttrDesc) Default(val interface{}) *AttrDesc {
+	ad.hasDefault = true
+	if gen, ok := val.(func() interface{}); ok {
+		ad.defaultGen = gen
+		return ad
+	}
+
+	v, err := ad.coerce(val)
+	if err != nil {
+		gwlog.Panicf("attribute %s: invalid default value: %s", ad.name, err)
+	}
+	ad.defaultVal = v
+	return ad
+}
+
+// newDefaultValue returns the default value for a new entity
+func (ad *AttrDesc) newDefaultValue() (interface{}, bool) {
+	if !ad.hasDefault && ad.typ == AttrTypeAny {
+		return nil, false
+	}
+
+	val := ad.defaultVal
+	if ad.defaultGen != nil {
+		v, err := ad.coerce(ad.defaultGen())
+		if err != nil {
+			gwlog.Panicf("attribute %s: invalid default value: %s", ad.name, err)
+		}
+		val = v
+	}
+
+	switch ad.typ {
+	case AttrTypeInt:
+		if val == nil {
+			val = int64(0)
+		}
+	case AttrTypeFloat:
+		if val == nil {
+			val = float64(0)
+		}
+	case AttrTypeStr:
+		if val == nil {
+			val = ""
+		}
+	case AttrTypeBool:
+		if val == nil {
+			val = false
+		}
+	case AttrTypeMap:
+		ma := NewMapAttr()
+		if val != nil {
+			ma.AssignMap(val.(map[string]interface{}))
+		}
+		return ma, true
+	case AttrTypeList:
+		la := NewListAttr()
+		if val != nil {
+			la.AssignList(val.([]interface{}))
+		}
+		return la, true
+	}
+
+	if mv, ok := val.(map[string]interface{}); ok { // untyped attribute with map default
+		ma := NewMapAttr()
+		ma.AssignMap(mv)
+		return ma, true
+	} else if lv, ok := val.([]interface{}); ok { // untyped attribute with list default
+		la := NewListAttr()
+		la.AssignList(lv)
+		return la, true
+	}
+	return val, true
+}
+
+// checkValue checks the value written to the attribute and converts it to the stored type
+func (ad *AttrDesc) checkValue(val interface{}) interface{} {
+	switch ad.typ {
+	case AttrTypeInt:
+		switch v := val.(type) {
+		case int64:
+			return v
+		case int:
+			return int64(v)
+		case int8:
+			return int64(v)
+		case int16:
+			return int64(v)
+		case int32:
+			return int64(v)
+		case uint:
+			return int64(v)
+		case uint8:
+			return int64(v)
+		case uint16:
+			return int64(v)
+		case uint32:
+			return int64(v)
+		case uint64:
+			return int64(v)
+		}
+	case AttrTypeFloat:
+		switch v := val.(type) {
+		case float64:
+			return v
+		case float32:
+			return float64(v)
+		}
+	case AttrTypeStr:
+		if _, ok := val.(string); ok {
+			return val
+		}
+	case AttrTypeBool:
+		if _, ok := val.(bool); ok {
+			return val
+		}
+	case AttrTypeMap:
+		if _, ok := val.(*MapAttr); ok {
+			return val
+		}
+	case AttrTypeList:
+		if _, ok := val.(*ListAttr); ok {
+			return val
+		}
+	default:
+		return val
+	}
+
+	gwlog.Panicf("attribute %s should be %s, but got %T: %v", ad.name, ad.typ, val, val)
+	return nil
+}
+
+// coerce converts value of wrong type to the attribute type, which happens when loading data from storage
+func (ad *AttrDesc) coerce(val interface{}) (interface{}, error) {
+	if val == nil {
+		return nil, nil
+	}
+
+	switch ad.typ {
+	case AttrTypeInt:
+		switch v := val.(type) {
+		case int64:
+			return v, nil
+		case int:
+			return int64(v), nil
+		case int8:
+			return int64(v), nil
+		case int16:
+			return int64(v), nil
+		case int32:
+			return int64(v), nil
+		case uint:
+			return int64(v), nil
+		case uint8:
+			return int64(v), nil
+		case uint16:
+			return int64(v), nil
+		case uint32:
+			return int64(v), nil
+		case uint64:
+			return int64(v), nil
+		case float32:
+			return coerceFloatToInt(float64(v))
+		case float64:
+			return coerceFloatToInt(v)
+		case string:
+			return strconv.ParseInt(v, 10, 64)
+		}
+	case AttrTypeFloat:
+		switch v := val.(type) {
+		case float64:
+			return v, nil
+		case float32:
+			return float64(v), nil
+		case int64:
+			return float64(v), nil
+		case int:
+			return float64(v), nil
+		case int32:
+			return float64(v), nil
+		case uint64:
+			return float64(v), nil
+		case uint32:
+			return float64(v), nil
+		case string:
+			return strconv.ParseFloat(v, 64)
+		}
+	case AttrTypeStr:
+		switch v := val.(type) {
+		case string:
+			return v, nil
+		case []byte:
+			return string(v), nil
+		}
+	case AttrTypeBool:
+		switch v := val.(type) {
+		case bool:
+			return v, nil
+		case string:
+			return strconv.ParseBool(v)
+		}
+	case AttrTypeMap:
+		switch v := val.(type) {
+		case map[string]interface{}:
+			return v, nil
+		case map[interface{}]interface{}:
+			m := make(map[string]interface{}, len(v))
+			for k, e := range v {
+				m[fmt.Sprint(k)] = e
+			}
+			return m, nil
+		}
+	case AttrTypeList:
+		if v, ok := val.([]interface{}); ok {
+			return v, nil
+		}
+	default:
+		return val, nil
+	}
+
+	return nil, fmt.Errorf("can not convert %T to %s: %v", val, ad.typ, val)
+}
+
+func coerceFloatToInt(v float64) (interface{}, error) {
+	if v != math.Trunc(v) || v < math.MinInt64 || v > math.MaxInt64 {
+		return nil, fmt.Errorf("can not convert float %v to int", v)
+	}
+	return int64(v), nil
+}
+
+// parseAttrType parses attribute type from attribute def
+func parseAttrType(def string) (AttrType, bool) {
+	t, ok := attrTypeNames[strings.ToLower(def)]
+	return t, ok
+}
+
+// coerceAttrs converts loaded attribute values to the declared types
+//
+// Values which can not be converted are dropped, so that default values are used.
+func (desc *EntityTypeDesc) coerceAttrs(typeName string, data map[string]interface{}) map[string]interface{} {
+	for key, val := range data {
+		ad := desc.attrDescs[key]
+		if ad == nil || ad.typ == AttrTypeAny {
+			continue
+		}
+
+		v, err := ad.coerce(val)
+		if err != nil {
+			gwlog.Errorf("%s: load attribute %s failed: %s", typeName, key, err)
+			delete(data, key)
+		} else if v == nil {
+			delete(data, key)
+		} else {
+			data[key] = v
+		}
+	}
+	return data
+}
+
+// checkAttrValue checks the value written to root attribute
+func (desc *EntityTypeDesc) checkAttrValue(key string, val interface{}) interface{} {
+	if ad := desc.attrDescs[key]; ad != nil {
+		return ad.checkValue(val)
+	}
+	return val
+}
+
+// assignAttrDefaults assigns default values to attributes which are not set
+func (e *Entity) assignAttrDefaults() {
+	for key, ad := range e.typeDesc.attrDescs {
+		if e.Attrs.HasKey(key) {
+			continue
+		}
+
+		if val, ok := ad.newDefaultValue(); ok {
+			e.Attrs.set(key, val)
+		}
+	}
+}
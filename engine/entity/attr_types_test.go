@@ -0,0 +1,79 @@
+package entity
+
+import (
+	"testing"
+)
+
+type testTypedAttrEntity struct {
+	Entity
+}
+
+func (e *testTypedAttrEntity) DefineAttrs(desc *EntityTypeDesc) {
+	desc.DefineAttr("name", "AllClients", "Str").Default("noname")
+	desc.DefineAttr("level", "Int").Default(1)
+	desc.DefineAttr("exp", "Int")
+	desc.DefineAttr("speed", "Float").Default(func() interface{} { return 2 })
+	desc.DefineAttr("online", "Bool")
+	desc.DefineAttr("bag", "Map").Default(map[string]interface{}{"gold": 10})
+	desc.DefineAttr("friends", "List")
+	desc.DefineAttr("any")
+}
+
+func TestAttrDefaults(t *testing.T) {
+	e := newTestEntity("testTypedAttrEntity", &testTypedAttrEntity{}, false, false).I.(*testTypedAttrEntity)
+	e.loadPersistentData(map[string]interface{}{
+		"level":  float64(10), // numbers are float64 if loaded from JSON
+		"exp":    "100",
+		"online": 1.5, // can not be converted, use the default value
+	})
+	e.assignAttrDefaults()
+
+	if e.GetStr("name") != "noname" || e.GetInt("level") != 10 || e.GetInt("exp") != 100 || e.GetFloat("speed") != 2 || e.Attrs.GetBool("online") {
+		t.Errorf("wrong attrs: %v", e.Attrs.ToMap())
+	}
+	if _, ok := e.Attrs.Get("level").(int64); !ok {
+		t.Errorf("level should be int64, but got %T", e.Attrs.Get("level"))
+	}
+	if e.GetMapAttr("bag").GetInt("gold") != 10 || e.GetListAttr("friends").Size() != 0 {
+		t.Errorf("wrong bag or friends: %v", e.Attrs.ToMap())
+	}
+	if e.Attrs.HasKey("any") {
+		t.Errorf("untyped attribute without default should not be set")
+	}
+
+	// default values should not be shared between entities
+	e.GetMapAttr("bag").SetInt("gold", 0)
+	e2 := newTestEntity("testTypedAttrEntity", &testTypedAttrEntity{}, false, false).I.(*testTypedAttrEntity)
+	e2.assignAttrDefaults()
+	if e2.GetMapAttr("bag").GetInt("gold") != 10 {
+		t.Errorf("default map value is shared between entities")
+	}
+}
+
+func TestAttrTypeCheck(t *testing.T) {
+	e := newTestEntity("testTypedAttrEntity", &testTypedAttrEntity{}, false, false).I.(*testTypedAttrEntity)
+	e.Attrs.set("level", 3)
+	if v, ok := e.Attrs.Get("level").(int64); !ok || v != 3 {
+		t.Errorf("level should be int64(3), but got %T %v", e.Attrs.Get("level"), e.Attrs.Get("level"))
+	}
+	e.Attrs.SetStr("any", "whatever")
+	e.Attrs.SetInt("any", 1)
+
+	expectPanic := func(name string, f func()) {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("%s should panic", name)
+			}
+		}()
+		f()
+	}
+	expectPanic("set str to int attribute", func() { e.Attrs.SetStr("level", "3") })
+	expectPanic("set int to float attribute", func() { e.Attrs.SetInt("speed", 1) })
+	expectPanic("set list to map attribute", func() { e.Attrs.SetListAttr("bag", NewListAttr()) })
+	expectPanic("set invalid default", func() {
+		(&AttrDesc{name: "invalid", typ: AttrTypeInt}).Default("abc")
+	})
+	if e.GetInt("level") != 3 {
+		t.Errorf("level should not be changed by invalid writes")
+	}
+}
@@ -0,0 +1,138 @@
+package entity
+
+import (
+	"github.com/lovelly/goworld/components/dispatcher/dispatcherclient"
+	"github.com/lovelly/goworld/engine/common"
+	"github.com/lovelly/goworld/engine/consts"
+	"github.com/lovelly/goworld/engine/gwlog"
+	"github.com/lovelly/goworld/engine/gwutils"
+	"github.com/pkg/errors"
+	"github.com/xiaonanln/goTimer"
+)
+
+// CallReplyCallback is the callback type of CallWithReply
+//
+// results are the return values of the called method, err is not nil if the call failed or timed out
+type CallReplyCallback func(results []interface{}, err error)
+
+type pendingCallReply struct {
+	caller   *Entity
+	callback CallReplyCallback
+	timer    *timer.Timer
+}
+
+var (
+	lastCallRequestID  uint32
+	pendingCallReplies = map[uint32]*pendingCallReply{}
+)
+
+// CallWithReply calls method of other entity with args and receives the return values of the method in callback
+//
+// callback comes before args so that args can be variadic as in Call.
+// If the last return value of the method is an error, it is passed to callback as err.
+// callback is called with an error if the entity is not found or no reply is received within consts.CALL_ENTITY_METHOD_REPLY_TIMEOUT
+func (e *Entity) CallWithReply(id common.EntityID, method string, callback CallReplyCallback, args ...interface{}) {
+	callEntityWithReply(e, id, method, args, callback)
+}
+
+// CallServiceWithReply calls a service provider with args and receives the return values of the method in callback
+func (e *Entity) CallServiceWithReply(serviceName string, method string, callback CallReplyCallback, args ...interface{}) {
+	serviceEid := entityManager.chooseServiceProvider(serviceName)
+	callEntityWithReply(e, serviceEid, method, args, callback)
+}
+
+func callEntityWithReply(caller *Entity, id common.EntityID, method string, args []interface{}, callback CallReplyCallback) {
+	if consts.OPTIMIZE_LOCAL_ENTITIES {
+		if target := entityManager.get(id); target != nil { // this entity is local, just call entity directly
+			caller.Post(func() {
+				if target.IsDestroyed() {
+					replyCaller(caller, callback, nil, errors.Errorf("entity %s not found", id))
+					return
+				}
+
+				results, err := target.onCallFromLocal(method, args)
+				replyCaller(caller, callback, results, err)
+			})
+			return
+		}
+	}
+
+	lastCallRequestID += 1
+	requestID := lastCallRequestID
+	pending := &pendingCallReply{
+		caller:   caller,
+		callback: callback,
+	}
+	pending.timer = timer.AddCallback(consts.CALL_ENTITY_METHOD_REPLY_TIMEOUT, func() {
+		// no reply from the target entity (the game of target entity might be down)
+		delete(pendingCallReplies, requestID)
+		replyCaller(caller, callback, nil, errors.Errorf("call %s.%s timeout", id, method))
+	})
+	pendingCallReplies[requestID] = pending
+
+	dispatcherclient.GetDispatcherClientForSend().SendCallEntityMethodWithReply(id, requestID, method, args)
+}
+
+func replyCaller(caller *Entity, callback CallReplyCallback, results []interface{}, err error) {
+	if caller.IsDestroyed() {
+		// caller is destroyed before reply, ignore
+		return
+	}
+
+	gwutils.RunPanicless(func() {
+		callback(results, err)
+	})
+}
+
+// failPendingCallReplies fails all pending calls of the caller, which will not receive replies
+func failPendingCallReplies(caller *Entity, err error) {
+	for requestID, pending := range pendingCallReplies {
+		if pending.caller != caller {
+			continue
+		}
+
+		delete(pendingCallReplies, requestID)
+		pending.timer.Cancel()
+		replyCaller(caller, pending.callback, nil, err)
+	}
+}
+
+// OnCallWithReply is called by engine when method call with reply reaches in the game
+func OnCallWithReply(id common.EntityID, method string, args [][]byte, callerGame uint16, requestID uint32) {
+	var results []interface{}
+	var err error
+
+	e := entityManager.get(id)
+	if e != nil {
+		results, err = e.onCallFromRemote(method, args, "")
+	} else {
+		// entity not found, may destroyed before call
+		err = errors.Errorf("entity %s not found", id)
+	}
+
+	var errmsg string
+	if err != nil {
+		errmsg = err.Error()
+	}
+
+	dispatcherclient.GetDispatcherClientForSend().SendCallEntityMethodReply(callerGame, requestID, errmsg, results)
+}
+
+// OnCallReply is called by engine when the reply of method call reaches in the game
+func OnCallReply(requestID uint32, errmsg string, results []interface{}) {
+	pending := pendingCallReplies[requestID]
+	if pending == nil {
+		// reply is too late
+		gwlog.Warnf("OnCallReply: request %d is not found, maybe timeout already", requestID)
+		return
+	}
+
+	delete(pendingCallReplies, requestID)
+	pending.timer.Cancel()
+
+	var err error
+	if errmsg != "" {
+		err = errors.New(errmsg)
+	}
+	replyCaller(pending.caller, pending.callback, results, err)
+}
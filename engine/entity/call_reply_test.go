@@ -0,0 +1,60 @@
+package entity
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/xiaonanln/goTimer"
+)
+
+type testReplyEntity struct {
+	Entity
+}
+
+func (e *testReplyEntity) DefineAttrs(desc *EntityTypeDesc) {
+}
+
+func (e *testReplyEntity) Add(a, b int) int {
+	return a + b
+}
+
+func TestCallWithReplyLocal(t *testing.T) {
+	caller := newTestEntity("testReplyEntity", &testReplyEntity{}, false, false)
+	callee := newTestEntity("testReplyEntity", &testReplyEntity{}, false, false)
+	entityManager.put(callee)
+	defer entityManager.del(callee.ID)
+
+	var results []interface{}
+	replied := false
+	caller.CallWithReply(callee.ID, "Add", func(r []interface{}, err error) {
+		if err != nil {
+			t.Errorf("call should succeed: %v", err)
+		}
+		results, replied = r, true
+	}, 1, 2)
+	waitPost(t, func() bool { return replied })
+	if len(results) != 1 || results[0] != 3 {
+		t.Fatalf("call should reply the return values, but got %v", results)
+	}
+}
+
+func TestFailPendingCallReplies(t *testing.T) {
+	caller := newTestAOIEntity("testAOIEntity")
+	other := newTestAOIEntity("testAOIEntity")
+
+	var failed error
+	pendingCallReplies[1] = &pendingCallReply{caller: caller, timer: timer.AddCallback(time.Hour, func() {}),
+		callback: func(results []interface{}, err error) { failed = err }}
+	pendingCallReplies[2] = &pendingCallReply{caller: other, timer: timer.AddCallback(time.Hour, func() {}),
+		callback: func(results []interface{}, err error) { t.Fatalf("calls of other entities should not fail") }}
+	defer delete(pendingCallReplies, 2)
+
+	failPendingCallReplies(caller, errors.New("migrated"))
+	if failed == nil || failed.Error() != "migrated" {
+		t.Fatalf("pending call should fail with the error: %v", failed)
+	}
+	if _, ok := pendingCallReplies[1]; ok || pendingCallReplies[2] == nil {
+		t.Fatalf("only pending calls of the caller should be removed")
+	}
+}
@@ -0,0 +1,146 @@
+package entity
+
+import (
+	"github.com/lovelly/goworld/engine/common"
+	"github.com/lovelly/goworld/engine/consts"
+	"github.com/lovelly/goworld/engine/gwlog"
+	"github.com/lovelly/goworld/engine/gwutils"
+	"github.com/lovelly/goworld/engine/proto"
+	"github.com/pkg/errors"
+	"github.com/xiaonanln/goTimer"
+)
+
+// CreateEntityCallback is the callback type of CreateEntityAnywhereWithCallback and LoadEntityAnywhereWithCallback
+//
+// gameid is the game where the entity is created or loaded, err is not nil if failed or timed out
+type CreateEntityCallback func(entityID common.EntityID, gameid uint16, err error)
+
+var (
+	// ErrEntityNotFound is the cause of errors when loading entity which is not found in storage
+	ErrEntityNotFound = errors.New("entity is not found in storage")
+	// ErrEntityTypeNotRegistered is the cause of errors when creating or loading entity of type not registered in the target game
+	ErrEntityTypeNotRegistered = errors.New("entity type is not registered")
+)
+
+// createEntityError is the error of creating or loading entity received from other games, with the sentinel error as the cause
+type createEntityError struct {
+	cause error
+	msg   string
+}
+
+func (err *createEntityError) Error() string {
+	return err.msg
+}
+
+// Cause returns the sentinel error, so that errors.Cause(err) == ErrEntityNotFound works
+func (err *createEntityError) Cause() error {
+	return err.cause
+}
+
+// Unwrap returns the sentinel error, so that errors.Is(err, ErrEntityNotFound) works
+func (err *createEntityError) Unwrap() error {
+	return err.cause
+}
+
+// createEntityErrorCode returns the error code sent to dispatcher for the error of creating or loading entity
+func createEntityErrorCode(err error) uint16 {
+	switch errors.Cause(err) {
+	case ErrEntityNotFound:
+		return proto.CREATE_ENTITY_NOT_FOUND
+	case ErrEntityTypeNotRegistered:
+		return proto.CREATE_ENTITY_TYPE_NOT_REGISTERED
+	}
+	return proto.CREATE_ENTITY_FAILED
+}
+
+// createEntityErrorFromCode restores the error of creating or loading entity from the error code and message
+func createEntityErrorFromCode(errcode uint16, errmsg string) error {
+	switch errcode {
+	case proto.CREATE_ENTITY_OK:
+		return nil
+	case proto.CREATE_ENTITY_NOT_FOUND:
+		return &createEntityError{ErrEntityNotFound, errmsg}
+	case proto.CREATE_ENTITY_TYPE_NOT_REGISTERED:
+		return &createEntityError{ErrEntityTypeNotRegistered, errmsg}
+	}
+	return errors.New(errmsg)
+}
+
+type pendingCreateReply struct {
+	entityID common.EntityID
+	callback CreateEntityCallback
+	timer    *timer.Timer
+}
+
+var (
+	lastCreateRequestID  uint32
+	pendingCreateReplies = map[uint32]*pendingCreateReply{}
+)
+
+// CreateEntityAnywhereWithCallback creates new entity in any game and returns the entity ID
+//
+// callback is called when the entity is created, or with an error if failed or no reply is received within consts.CREATE_ENTITY_ANYWHERE_TIMEOUT
+func CreateEntityAnywhereWithCallback(typeName string, callback CreateEntityCallback) common.EntityID {
+	entityID := common.GenEntityID()
+	requestID := addPendingCreateReply(entityID, callback)
+	createEntityAnywhere(typeName, entityID, nil, requestID)
+	return entityID
+}
+
+// LoadEntityAnywhereWithCallback loads entity in any game
+//
+// callback is called when the entity is loaded or if the entity already exists on any game.
+// callback is called with an error if the entity is not found in storage, the entity type is not registered,
+// or no reply is received within consts.CREATE_ENTITY_ANYWHERE_TIMEOUT.
+// errors.Cause(err) is ErrEntityNotFound or ErrEntityTypeNotRegistered for the first two cases.
+func LoadEntityAnywhereWithCallback(typeName string, entityID common.EntityID, callback CreateEntityCallback) {
+	requestID := addPendingCreateReply(entityID, callback)
+	loadEntityAnywhere(typeName, entityID, requestID)
+}
+
+func addPendingCreateReply(entityID common.EntityID, callback CreateEntityCallback) uint32 {
+	lastCreateRequestID += 1
+	requestID := lastCreateRequestID
+	pending := &pendingCreateReply{
+		entityID: entityID,
+		callback: callback,
+	}
+	pending.timer = timer.AddCallback(consts.CREATE_ENTITY_ANYWHERE_TIMEOUT, func() {
+		// no reply from dispatcher (the target game might be down)
+		delete(pendingCreateReplies, requestID)
+		callCreateEntityCallback(callback, entityID, 0, errors.Errorf("create or load entity %s timeout", entityID))
+	})
+	pendingCreateReplies[requestID] = pending
+	return requestID
+}
+
+func callCreateEntityCallback(callback CreateEntityCallback, entityID common.EntityID, gameid uint16, err error) {
+	gwutils.RunPanicless(func() {
+		callback(entityID, gameid, err)
+	})
+}
+
+// OnCreateEntityAnywhere is called by engine when entity is created in this game by CreateEntityAnywhere
+func OnCreateEntityAnywhere(typeName string, entityID common.EntityID, data map[string]interface{}) {
+	if _, ok := registeredEntityTypes[typeName]; !ok {
+		notifyCreateEntityFailed(entityID, errors.Wrapf(ErrEntityTypeNotRegistered, "create entity %s.%s", typeName, entityID))
+		return
+	}
+
+	createEntity(typeName, nil, Vector3{}, entityID, data, nil, nil, ccCreate)
+}
+
+// OnCreateEntityAnywhereReply is called by engine when the entity created or loaded anywhere is ready
+func OnCreateEntityAnywhereReply(requestID uint32, entityID common.EntityID, gameid uint16, errcode uint16, errmsg string) {
+	pending := pendingCreateReplies[requestID]
+	if pending == nil {
+		// reply is too late
+		gwlog.Warnf("OnCreateEntityAnywhereReply: request %d is not found, maybe timeout already", requestID)
+		return
+	}
+
+	delete(pendingCreateReplies, requestID)
+	pending.timer.Cancel()
+
+	callCreateEntityCallback(pending.callback, entityID, gameid, createEntityErrorFromCode(errcode, errmsg))
+}
@@ -0,0 +1,59 @@
+package entity
+
+import (
+	"testing"
+
+	"github.com/lovelly/goworld/engine/common"
+	"github.com/lovelly/goworld/engine/proto"
+	"github.com/pkg/errors"
+)
+
+func TestCreateEntityAnywhereReply(t *testing.T) {
+	var gotID common.EntityID
+	var gotGame uint16
+	var gotErr error
+	calls := 0
+	callback := func(entityID common.EntityID, gameid uint16, err error) {
+		gotID, gotGame, gotErr = entityID, gameid, err
+		calls += 1
+	}
+
+	entityID := common.GenEntityID()
+	requestID := addPendingCreateReply(entityID, callback)
+	OnCreateEntityAnywhereReply(requestID, entityID, 2, proto.CREATE_ENTITY_OK, "")
+	if gotID != entityID || gotGame != 2 || gotErr != nil {
+		t.Errorf("callback should be called with %s on game 2, but got %s, %d, %v", entityID, gotID, gotGame, gotErr)
+	}
+	if _, ok := pendingCreateReplies[requestID]; ok {
+		t.Errorf("request should be removed after replied")
+	}
+
+	requestID = addPendingCreateReply(entityID, callback)
+	OnCreateEntityAnywhereReply(requestID, entityID, 0, proto.CREATE_ENTITY_NOT_FOUND, "load entity Avatar.xxx: entity is not found in storage")
+	if gotErr == nil || gotErr.Error() != "load entity Avatar.xxx: entity is not found in storage" || errors.Cause(gotErr) != ErrEntityNotFound {
+		t.Errorf("callback should be called with ErrEntityNotFound, but got %v", gotErr)
+	}
+
+	// late replies are ignored
+	OnCreateEntityAnywhereReply(requestID, entityID, 1, proto.CREATE_ENTITY_OK, "")
+	if calls != 2 {
+		t.Errorf("callback should not be called again")
+	}
+}
+
+func TestCreateEntityErrorCode(t *testing.T) {
+	for _, sentinel := range []error{ErrEntityNotFound, ErrEntityTypeNotRegistered} {
+		err := errors.Wrapf(sentinel, "load entity Avatar.xxx")
+		decoded := createEntityErrorFromCode(createEntityErrorCode(err), err.Error())
+		if errors.Cause(decoded) != sentinel || decoded.Error() != err.Error() {
+			t.Errorf("error %v should be decoded with cause %v, but got %v", err, sentinel, decoded)
+		}
+	}
+
+	if code := createEntityErrorCode(errors.New("storage is down")); code != proto.CREATE_ENTITY_FAILED {
+		t.Errorf("other errors should be sent as CREATE_ENTITY_FAILED, but got %d", code)
+	}
+	if err := createEntityErrorFromCode(proto.CREATE_ENTITY_OK, ""); err != nil {
+		t.Errorf("CREATE_ENTITY_OK should be decoded as nil, but got %v", err)
+	}
+}
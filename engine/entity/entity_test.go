@@ -1,6 +1,7 @@
 package entity
 
 import (
+	"reflect"
 	"testing"
 
 	"github.com/lovelly/goworld/engine/common"
@@ -49,6 +50,19 @@ func (c *TestComponent1) OnMigrateIn() {
 	gwlog.Infof("TestComponent1.OnMigrateIn ...")
 }
 
+// newTestEntity creates an entity of the type which is not managed by entity manager, the type is registered if not yet
+//
+// ptr is a pointer to the entity struct for registering the type, use e.I to get the entity struct
+func newTestEntity(typeName string, ptr IEntity, isPersistent bool, useAOI bool) *Entity {
+	if _, ok := registeredEntityTypes[typeName]; !ok {
+		RegisterEntity(typeName, ptr, isPersistent, useAOI)
+	}
+	instance := reflect.New(registeredEntityTypes[typeName].entityType)
+	e := reflect.Indirect(instance).FieldByName("Entity").Addr().Interface().(*Entity)
+	e.init(typeName, common.GenEntityID(), instance)
+	return e
+}
+
 func TestRegisterEntity(t *testing.T) {
 	RegisterEntity("TestEntity", &TestEntity{}, false, false)
 }
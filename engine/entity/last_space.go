@@ -0,0 +1,98 @@
+package entity
+
+import (
+	"github.com/lovelly/goworld/engine/common"
+	"github.com/lovelly/goworld/engine/gwlog"
+	"github.com/xiaonanln/typeconv"
+)
+
+const (
+	_LAST_SPACE_ATTR_KEY = "_LS" // last space of entity: {K: space kind, I: space ID, X, Y, Z: position}
+)
+
+// SetRememberLastSpace sets if the last space kind, space ID and position of entities of this type are persistent
+//
+// When the entity is loaded, it re-enters the last space at the saved position if the space still exists.
+// Otherwise OnEnterLastSpaceFailed(kind int, pos Vector3) is called, so that the entity can choose another space.
+// The entity type should be persistent.
+func (desc *EntityTypeDesc) SetRememberLastSpace(enable bool) *EntityTypeDesc {
+	if enable && !desc.isPersistent {
+		gwlog.Panicf("SetRememberLastSpace: entity type %s is not persistent", desc.entityType.Name())
+	}
+
+	desc.rememberLastSpace = enable
+	if enable && desc.attrDescs[_LAST_SPACE_ATTR_KEY] == nil {
+		desc.DefineAttr(_LAST_SPACE_ATTR_KEY, "Persistent", "Map")
+	}
+	return desc
+}
+
+// GetLastSpace returns the last space kind, space ID and position of entity, or ok=false if there is no last space
+func (e *Entity) GetLastSpace() (kind int, spaceID common.EntityID, pos Vector3, ok bool) {
+	if !e.Attrs.HasKey(_LAST_SPACE_ATTR_KEY) {
+		return
+	}
+
+	ls := e.GetMapAttr(_LAST_SPACE_ATTR_KEY)
+	if !ls.HasKey("I") {
+		return
+	}
+
+	kind = int(typeconv.Int(ls.Get("K")))
+	spaceID = common.EntityID(ls.GetStr("I"))
+	pos = Vector3{
+		Coord(typeconv.Float(ls.Get("X"))),
+		Coord(typeconv.Float(ls.Get("Y"))),
+		Coord(typeconv.Float(ls.Get("Z"))),
+	}
+	return kind, spaceID, pos, true
+}
+
+// updateLastSpace saves the current space and position of entity as the last space
+func (e *Entity) updateLastSpace() {
+	if !e.typeDesc.rememberLastSpace || e.Space == nil || e.Space.IsNil() {
+		return
+	}
+
+	ls := e.GetMapAttr(_LAST_SPACE_ATTR_KEY)
+	if !ls.HasKey("I") || ls.GetStr("I") != string(e.Space.ID) {
+		ls.SetInt("K", int64(e.Space.Kind))
+		ls.SetStr("I", string(e.Space.ID))
+	}
+
+	pos := e.aoi.pos
+	if ls.HasKey("X") && Coord(typeconv.Float(ls.Get("X"))) == pos.X &&
+		Coord(typeconv.Float(ls.Get("Y"))) == pos.Y && Coord(typeconv.Float(ls.Get("Z"))) == pos.Z {
+		return // not moved
+	}
+	ls.SetFloat("X", float64(pos.X))
+	ls.SetFloat("Y", float64(pos.Y))
+	ls.SetFloat("Z", float64(pos.Z))
+}
+
+// enterLastSpace enters the last space of entity after it is loaded
+func (e *Entity) enterLastSpace() {
+	if !e.typeDesc.rememberLastSpace || e.isEnteringSpace() || (e.Space != nil && !e.Space.IsNil()) {
+		return // OnCreated might have entered a space
+	}
+
+	_, spaceID, pos, ok := e.GetLastSpace()
+	if !ok {
+		return
+	}
+
+	e.enteringLastSpace = true
+	e.EnterSpace(spaceID, pos)
+}
+
+// onEnterLastSpaceFailed calls OnEnterLastSpaceFailed if the entity fails to enter the last space
+func (e *Entity) onEnterLastSpaceFailed() {
+	if !e.enteringLastSpace {
+		return
+	}
+
+	e.enteringLastSpace = false
+	kind, spaceID, pos, _ := e.GetLastSpace()
+	gwlog.Warnf("%s: last space %s of kind %d is not found", e, spaceID, kind)
+	e.callCompositiveMethod("OnEnterLastSpaceFailed", kind, pos)
+}
@@ -0,0 +1,48 @@
+package entity
+
+import (
+	"testing"
+)
+
+type testLastSpaceEntity struct {
+	Entity
+	failedKind int
+	failedPos  Vector3
+}
+
+func (e *testLastSpaceEntity) DefineAttrs(desc *EntityTypeDesc) {
+	desc.SetRememberLastSpace(true)
+}
+
+func (e *testLastSpaceEntity) OnEnterLastSpaceFailed(kind int, pos Vector3) {
+	e.failedKind, e.failedPos = kind, pos
+}
+
+func TestRememberLastSpace(t *testing.T) {
+	e := newTestEntity("testLastSpaceEntity", &testLastSpaceEntity{}, true, true)
+	e.assignAttrDefaults()
+	if _, _, _, ok := e.GetLastSpace(); ok {
+		t.Fatalf("new entity should have no last space")
+	}
+
+	space := newTestSpace(3)
+	space.enter(e, Vector3{1, 0, 1}, false)
+	space.move(e, Vector3{5, 0, 7})
+	space.leave(e)
+
+	kind, spaceID, pos, ok := e.GetLastSpace()
+	if !ok || kind != 3 || spaceID != space.ID || pos != (Vector3{5, 0, 7}) {
+		t.Fatalf("last space should be saved when leaving space: %v %v %v %v", kind, spaceID, pos, ok)
+	}
+
+	e.onEnterLastSpaceFailed()
+	le := e.I.(*testLastSpaceEntity)
+	if le.failedKind != 0 {
+		t.Fatalf("OnEnterLastSpaceFailed should not be called if not entering last space")
+	}
+	e.enteringLastSpace = true
+	e.onEnterLastSpaceFailed()
+	if le.failedKind != 3 || le.failedPos != (Vector3{5, 0, 7}) || e.enteringLastSpace {
+		t.Fatalf("OnEnterLastSpaceFailed should be called with last space kind and position")
+	}
+}
@@ -0,0 +1,154 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/lovelly/goworld/engine/gwlog"
+)
+
+// entityMovement is the server side movement of entity along a path
+type entityMovement struct {
+	space    *Space
+	path     []Vector3 // remaining waypoints, path[0] is the current target
+	speed    Coord     // distance per second
+	lastTime time.Time
+}
+
+var (
+	movingEntities = EntitySet{}
+)
+
+// MoveTo moves the entity straight to the target position at speed (distance per second)
+//
+// See MoveAlongPath for details.
+func (e *Entity) MoveTo(target Vector3, speed Coord) {
+	e.MoveAlongPath([]Vector3{target}, speed)
+}
+
+// MoveAlongPath moves the entity along the path of waypoints at speed (distance per second)
+//
+// The position of entity is advanced by engine in each tick, and the current movement is replaced.
+// Clients receive the target and speed of each straight segment only once, and interpolate the movement by themselves.
+// OnMoveArrived() is called when the entity arrives at the last waypoint.
+// If the space has a navigation map, the entity stops before unwalkable positions and OnMoveBlocked() is called.
+func (e *Entity) MoveAlongPath(path []Vector3, speed Coord) {
+	if e.Space == nil || e.Space.IsNil() {
+		gwlog.Warnf("%s.MoveAlongPath: not in any space", e)
+		return
+	}
+	if len(path) == 0 || speed <= 0 {
+		gwlog.Warnf("%s.MoveAlongPath: invalid path %v or speed %v", e, path, speed)
+		return
+	}
+
+	e.movement = &entityMovement{
+		space:    e.Space,
+		path:     append([]Vector3(nil), path...),
+		speed:    speed,
+		lastTime: time.Now(),
+	}
+	movingEntities.Add(e)
+	e.FaceToPos(path[0])
+	e.sendMoveEntity(path[0], speed)
+}
+
+// StopMove stops the current movement of entity
+//
+// Neither OnMoveArrived nor OnMoveBlocked is called.
+func (e *Entity) StopMove() {
+	if e.movement == nil {
+		return
+	}
+	e.stopMove()
+}
+
+// IsMoving returns if the entity is moving by MoveTo or MoveAlongPath
+func (e *Entity) IsMoving() bool {
+	return e.movement != nil
+}
+
+func (e *Entity) stopMove() {
+	e.movement = nil
+	movingEntities.Del(e)
+	if e.Space != nil && !e.Space.IsNil() {
+		e.sendMoveEntity(e.aoi.pos, 0)
+		e.syncInfoFlag |= sifSyncOwnClient | sifSyncNeighborClients
+	}
+}
+
+// sendMoveEntity sends the movement of entity to the client and clients of visible entities
+func (e *Entity) sendMoveEntity(target Vector3, speed Coord) {
+	e.client.sendMoveEntity(e, target, speed)
+	for neighbor := range e.aoi.visibleBy {
+		neighbor.client.sendMoveEntity(e, target, speed)
+	}
+	e.forwardToGhosts("MoveGhostOnClients", target, speed)
+}
+
+// tickMovement advances the position of entity along the movement path
+func (e *Entity) tickMovement(now time.Time) {
+	movement := e.movement
+	if e.destroyed || e.Space != movement.space {
+		// entity is destroyed or has left the space
+		e.movement = nil
+		movingEntities.Del(e)
+		return
+	}
+
+	dist := movement.speed * Coord(now.Sub(movement.lastTime).Seconds())
+	movement.lastTime = now
+	pos := e.aoi.pos
+	segmentChanged := false
+	for dist > 0 && len(movement.path) > 0 {
+		target := movement.path[0]
+		next := target
+		d := pos.DistanceTo(target)
+		if d > dist {
+			next = pos.Add(target.Sub(pos).Mul(dist / d))
+		}
+
+		if hit, blocked := e.Space.NavRaycast(pos, next); blocked {
+			e.setMovingPosition(hit)
+			e.stopMove()
+			e.callCompositiveMethod("OnMoveBlocked")
+			return
+		}
+
+		pos = next
+		if d > dist {
+			break
+		}
+		// arrived at the waypoint
+		dist -= d
+		movement.path = movement.path[1:]
+		segmentChanged = true
+	}
+
+	e.setMovingPosition(pos)
+	if len(movement.path) == 0 {
+		e.stopMove()
+		e.callCompositiveMethod("OnMoveArrived")
+	} else if segmentChanged {
+		// send the next segment of path to clients
+		e.FaceToPos(movement.path[0])
+		e.sendMoveEntity(movement.path[0], movement.speed)
+	}
+}
+
+// setMovingPosition sets position of moving entity without syncing to clients, because clients interpolate the movement
+func (e *Entity) setMovingPosition(pos Vector3) {
+	e.Space.move(e, pos)
+	e.recordPositionHistory()
+}
+
+// TickMovements advances positions of moving entities, called by engine in each tick
+func TickMovements() {
+	if len(movingEntities) == 0 {
+		return
+	}
+
+	now := time.Now()
+	for e := range movingEntities {
+		e.tickMovement(now)
+	}
+}
@@ -0,0 +1,88 @@
+package entity
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lovelly/goworld/engine/nav"
+)
+
+type testMovingEntity struct {
+	Entity
+	arrived, blocked int
+}
+
+func (e *testMovingEntity) DefineAttrs(desc *EntityTypeDesc) {
+}
+
+func (e *testMovingEntity) OnMoveArrived() {
+	e.arrived += 1
+}
+
+func (e *testMovingEntity) OnMoveBlocked() {
+	e.blocked += 1
+}
+
+// tickTestMovement advances the movement of entity by duration d
+func tickTestMovement(e *Entity, d time.Duration) {
+	now := e.movement.lastTime.Add(d)
+	e.tickMovement(now)
+}
+
+func checkPosition(t *testing.T, e *Entity, pos Vector3) {
+	if e.GetPosition().DistanceTo(pos) > 0.001 {
+		t.Fatalf("%s should be at %v, but at %v", e, pos, e.GetPosition())
+	}
+}
+
+func TestMoveAlongPath(t *testing.T) {
+	space := newTestSpace(1)
+	e := newTestEntity("testMovingEntity", &testMovingEntity{}, false, true).I.(*testMovingEntity)
+	space.enter(&e.Entity, Vector3{}, false)
+
+	e.MoveAlongPath([]Vector3{{10, 0, 0}, {10, 0, 10}}, 10)
+	if !e.IsMoving() || !movingEntities.Contains(&e.Entity) {
+		t.Fatalf("entity should be moving")
+	}
+	tickTestMovement(&e.Entity, time.Millisecond*500)
+	checkPosition(t, &e.Entity, Vector3{5, 0, 0})
+	tickTestMovement(&e.Entity, time.Second)
+	checkPosition(t, &e.Entity, Vector3{10, 0, 5})
+	if e.arrived != 0 {
+		t.Errorf("entity should not arrive")
+	}
+	tickTestMovement(&e.Entity, time.Second)
+	checkPosition(t, &e.Entity, Vector3{10, 0, 10})
+	if e.arrived != 1 || e.IsMoving() || movingEntities.Contains(&e.Entity) {
+		t.Errorf("entity should arrive and stop")
+	}
+
+	e.MoveTo(Vector3{}, 1)
+	e.SetPosition(Vector3{1, 0, 1})
+	if e.IsMoving() || movingEntities.Contains(&e.Entity) {
+		t.Errorf("SetPosition should stop moving")
+	}
+	checkPosition(t, &e.Entity, Vector3{1, 0, 1})
+}
+
+func TestMoveBlocked(t *testing.T) {
+	const navSpaceKind = 1002
+	navMap := nav.NewGridMap(10, 10, 1, 0, 0)
+	for z := 0; z < 10; z++ {
+		navMap.SetWalkable(5, z, false)
+	}
+	SetSpaceNavMap(navSpaceKind, navMap)
+	defer delete(spaceNavMaps, navSpaceKind)
+
+	space := newTestSpace(navSpaceKind)
+	e := newTestEntity("testMovingEntity", &testMovingEntity{}, false, true).I.(*testMovingEntity)
+	space.enter(&e.Entity, Vector3{1.5, 0, 1.5}, false)
+	e.MoveTo(Vector3{8.5, 0, 1.5}, 10)
+	tickTestMovement(&e.Entity, time.Second)
+	if e.blocked != 1 || e.arrived != 0 || e.IsMoving() {
+		t.Fatalf("entity should be blocked")
+	}
+	if pos := e.GetPosition(); pos.X > 5 || pos.X < 4.9 || !space.IsWalkable(pos) {
+		t.Errorf("entity should stop before the wall, but at %v", pos)
+	}
+}
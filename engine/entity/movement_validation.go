@@ -0,0 +1,221 @@
+package entity
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/lovelly/goworld/engine/common"
+	"github.com/lovelly/goworld/engine/gwlog"
+)
+
+const (
+	// max elapsed time used for speed check, so that clients can not save up distance by pausing syncs
+	_MAX_MOVEMENT_ELAPSED = time.Second
+)
+
+// MovementViolationPolicy decides what to do when position synced from client violates the MovementValidator
+type MovementViolationPolicy int
+
+const (
+	// MovementSnapBack rejects the position and sends the current position back to the client
+	MovementSnapBack MovementViolationPolicy = iota
+	// MovementCallback rejects the position and calls OnMovementViolation of the entity
+	MovementCallback
+)
+
+// WalkableFunc checks if entity can walk straight between two positions in space
+type WalkableFunc func(space *Space, from, to Vector3) bool
+
+// MovementValidator declares the constraints of positions synced from clients
+type MovementValidator struct {
+	MaxSpeed  Coord                   // max distance per second, 0 for no limit
+	Tolerance Coord                   // extra distance allowed in each sync for network jitter
+	Walkable  WalkableFunc            // nil uses the navigation map of space, see Space.NavRaycast
+	Policy    MovementViolationPolicy // what to do on violation
+}
+
+// MovementViolationKind is the kind of movement violation
+type MovementViolationKind int
+
+const (
+	// MovementViolationSpeed means client moves faster than MaxSpeed
+	MovementViolationSpeed MovementViolationKind = 1 + iota
+	// MovementViolationUnwalkable means client moves through unwalkable positions
+	MovementViolationUnwalkable
+)
+
+func (kind MovementViolationKind) String() string {
+	switch kind {
+	case MovementViolationSpeed:
+		return "Speed"
+	case MovementViolationUnwalkable:
+		return "Unwalkable"
+	}
+	return fmt.Sprintf("MovementViolationKind<%d>", int(kind))
+}
+
+// MovementViolation is the error of position synced from client which violates the MovementValidator
+type MovementViolation struct {
+	EntityID common.EntityID
+	Kind     MovementViolationKind
+	From, To Vector3
+	Detail   string
+}
+
+func (v *MovementViolation) Error() string {
+	return fmt.Sprintf("movement violation %s: %s from %s to %s: %s", v.Kind, v.EntityID, v.From, v.To, v.Detail)
+}
+
+// MovementViolationStats is the statistics of movement violations of an entity
+type MovementViolationStats struct {
+	Total         int
+	ByKind        map[MovementViolationKind]int
+	LastViolation *MovementViolation
+	LastTime      time.Time
+}
+
+var movementViolationStats = map[common.EntityID]*MovementViolationStats{}
+
+// GetMovementViolationStats returns the movement violation statistics of all violating entities in this game
+//
+// Never modify the return value !
+func GetMovementViolationStats() map[common.EntityID]*MovementViolationStats {
+	return movementViolationStats
+}
+
+func clearMovementViolationStats(entityID common.EntityID) {
+	delete(movementViolationStats, entityID)
+}
+
+func recordMovementViolation(v *MovementViolation) {
+	stats := movementViolationStats[v.EntityID]
+	if stats == nil {
+		stats = &MovementViolationStats{ByKind: map[MovementViolationKind]int{}}
+		movementViolationStats[v.EntityID] = stats
+	}
+	stats.Total += 1
+	stats.ByKind[v.Kind] += 1
+	stats.LastViolation = v
+	stats.LastTime = time.Now()
+
+	if stats.Total%100 == 1 { // do not flood the log when client keeps violating
+		gwlog.Warnf("%s (total %d violations of this entity)", v, stats.Total)
+	}
+}
+
+// clientMoveState is the state of the last accepted position of entity used by movement validation
+type clientMoveState struct {
+	space *Space
+	time  time.Time
+}
+
+// SetMovementValidator enables validation of positions synced from clients of this entity type
+//
+// Positions are clamped to bounds of the space, see Space.SetBounds.
+// Positions moving too fast or through unwalkable positions are rejected, and handled by the Policy of validator.
+func (desc *EntityTypeDesc) SetMovementValidator(validator MovementValidator) *EntityTypeDesc {
+	if validator.MaxSpeed < 0 || validator.Tolerance < 0 {
+		gwlog.Panicf("SetMovementValidator: invalid max speed %v or tolerance %v", validator.MaxSpeed, validator.Tolerance)
+	}
+	desc.movementValidator = &validator
+	return desc
+}
+
+// SetBounds sets the bounds of space, positions synced from clients of entities with MovementValidator are clamped to the bounds
+//
+// Y axis is not clamped if minPos.Y == maxPos.Y
+func (space *Space) SetBounds(minPos, maxPos Vector3) {
+	if minPos.X > maxPos.X || minPos.Y > maxPos.Y || minPos.Z > maxPos.Z {
+		gwlog.Panicf("%s.SetBounds: invalid bounds %s - %s", space, minPos, maxPos)
+	}
+	space.bounds = &[2]Vector3{minPos, maxPos}
+}
+
+// GetBounds returns the bounds of space, and false if the space has no bounds
+func (space *Space) GetBounds() (Vector3, Vector3, bool) {
+	if space.bounds == nil {
+		return Vector3{}, Vector3{}, false
+	}
+	return space.bounds[0], space.bounds[1], true
+}
+
+// ClampToBounds clamps the position to bounds of space
+func (space *Space) ClampToBounds(pos Vector3) Vector3 {
+	if space.bounds == nil {
+		return pos
+	}
+	minPos, maxPos := space.bounds[0], space.bounds[1]
+	pos.X = maxCoord(minPos.X, minCoord(maxPos.X, pos.X))
+	if minPos.Y < maxPos.Y {
+		pos.Y = maxCoord(minPos.Y, minCoord(maxPos.Y, pos.Y))
+	}
+	pos.Z = maxCoord(minPos.Z, minCoord(maxPos.Z, pos.Z))
+	return pos
+}
+
+// validateClientMovement validates the position synced from client, returns the position to apply and if it is accepted
+func (e *Entity) validateClientMovement(pos Vector3, now time.Time) (Vector3, bool) {
+	validator := e.typeDesc.movementValidator
+	space := e.Space
+	from := e.aoi.pos
+
+	clamped := space.ClampToBounds(pos)
+	if clamped != pos {
+		// let the client know the clamped position
+		e.syncInfoFlag |= sifSyncOwnClient
+		pos = clamped
+	}
+
+	if validator.MaxSpeed > 0 && e.clientMove.space == space {
+		elapsed := now.Sub(e.clientMove.time)
+		if elapsed > _MAX_MOVEMENT_ELAPSED {
+			elapsed = _MAX_MOVEMENT_ELAPSED
+		}
+		maxDist := validator.MaxSpeed*Coord(elapsed.Seconds()) + validator.Tolerance
+		if dist := from.DistanceTo(pos); dist > maxDist {
+			e.onMovementViolation(validator, MovementViolationSpeed, from, pos, fmt.Sprintf("moved %v in %s, max %v", dist, elapsed, maxDist))
+			return from, false
+		}
+	}
+
+	walkable := validator.Walkable
+	if walkable == nil {
+		walkable = navWalkable
+	}
+	if !walkable(space, from, pos) {
+		e.onMovementViolation(validator, MovementViolationUnwalkable, from, pos, "not walkable")
+		return from, false
+	}
+
+	e.clientMove = clientMoveState{space, now}
+	return pos, true
+}
+
+func navWalkable(space *Space, from, to Vector3) bool {
+	_, blocked := space.NavRaycast(from, to)
+	return !blocked
+}
+
+// resetClientMove restarts speed check of movement validation from the current position
+func (e *Entity) resetClientMove() {
+	if e.typeDesc.movementValidator != nil {
+		e.clientMove = clientMoveState{e.Space, time.Now()}
+	}
+}
+
+func (e *Entity) onMovementViolation(validator *MovementValidator, kind MovementViolationKind, from, to Vector3, detail string) {
+	v := &MovementViolation{
+		EntityID: e.ID,
+		Kind:     kind,
+		From:     from,
+		To:       to,
+		Detail:   detail,
+	}
+	recordMovementViolation(v)
+
+	if validator.Policy == MovementSnapBack {
+		e.syncInfoFlag |= sifSyncOwnClient
+	} else {
+		e.callCompositiveMethod("OnMovementViolation", v)
+	}
+}
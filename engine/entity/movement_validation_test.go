@@ -0,0 +1,103 @@
+package entity
+
+import (
+	"testing"
+	"time"
+)
+
+type testValidatedEntity struct {
+	Entity
+	violations []*MovementViolation
+}
+
+func (e *testValidatedEntity) DefineAttrs(desc *EntityTypeDesc) {
+}
+
+func (e *testValidatedEntity) OnMovementViolation(v *MovementViolation) {
+	e.violations = append(e.violations, v)
+}
+
+// setTestMovementValidator sets the movement validator of entity type, and returns a function to restore the old one
+func setTestMovementValidator(desc *EntityTypeDesc, validator MovementValidator) (restore func()) {
+	old := desc.movementValidator
+	desc.SetMovementValidator(validator)
+	return func() {
+		desc.movementValidator = old
+	}
+}
+
+// syncTestClientMovement syncs position from client after duration d since the last accepted position
+func syncTestClientMovement(e *Entity, pos Vector3, d time.Duration) bool {
+	e.syncInfoFlag = 0
+	pos, ok := e.validateClientMovement(pos, e.clientMove.time.Add(d))
+	if ok {
+		e.setPositionYaw(pos, 0, true)
+	}
+	return ok
+}
+
+func TestMovementValidatorSpeed(t *testing.T) {
+	space := newTestSpace(1)
+	e := newTestEntity("testValidatedEntity", &testValidatedEntity{}, false, true).I.(*testValidatedEntity)
+	defer setTestMovementValidator(e.typeDesc, MovementValidator{MaxSpeed: 10, Tolerance: 1})()
+	space.enter(&e.Entity, Vector3{}, false)
+
+	if !syncTestClientMovement(&e.Entity, Vector3{5, 0, 0}, time.Millisecond*500) {
+		t.Fatalf("moving 5 in 0.5s should be accepted")
+	}
+	checkPosition(t, &e.Entity, Vector3{5, 0, 0})
+	if !syncTestClientMovement(&e.Entity, Vector3{5, 0, 10}, time.Millisecond*950) {
+		t.Fatalf("moving 10 in 0.95s should be accepted with tolerance")
+	}
+	if syncTestClientMovement(&e.Entity, Vector3{5, 0, 30}, time.Second) {
+		t.Fatalf("moving 20 in 1s should be rejected")
+	}
+	checkPosition(t, &e.Entity, Vector3{5, 0, 10})
+	if e.syncInfoFlag&sifSyncOwnClient == 0 {
+		t.Errorf("client should be snapped back")
+	}
+	if syncTestClientMovement(&e.Entity, Vector3{5, 0, 30}, time.Minute) {
+		t.Fatalf("elapsed time should be capped")
+	}
+
+	e.SetPosition(Vector3{100, 0, 100})
+	if !syncTestClientMovement(&e.Entity, Vector3{100, 0, 105}, time.Millisecond*500) {
+		t.Fatalf("speed check should restart from position set by server")
+	}
+}
+
+func TestMovementValidatorBoundsAndWalkable(t *testing.T) {
+	space := newTestSpace(1)
+	space.SetBounds(Vector3{-10, 0, -10}, Vector3{10, 0, 10})
+	e := newTestEntity("testValidatedEntity", &testValidatedEntity{}, false, true).I.(*testValidatedEntity)
+	defer setTestMovementValidator(e.typeDesc, MovementValidator{
+		Policy: MovementCallback,
+		Walkable: func(space *Space, from, to Vector3) bool {
+			return to.X >= 0
+		},
+	})()
+	space.enter(&e.Entity, Vector3{}, false)
+
+	if !syncTestClientMovement(&e.Entity, Vector3{20, 5, -20}, time.Second) {
+		t.Fatalf("out of bounds position should be clamped")
+	}
+	checkPosition(t, &e.Entity, Vector3{10, 5, -10})
+	if e.syncInfoFlag&sifSyncOwnClient == 0 {
+		t.Errorf("clamped position should be sent to client")
+	}
+
+	if syncTestClientMovement(&e.Entity, Vector3{-5, 0, 0}, time.Second) {
+		t.Fatalf("unwalkable position should be rejected")
+	}
+	checkPosition(t, &e.Entity, Vector3{10, 5, -10})
+	if len(e.violations) != 1 || e.violations[0].Kind != MovementViolationUnwalkable {
+		t.Fatalf("OnMovementViolation should be called: %v", e.violations)
+	}
+	if e.syncInfoFlag&sifSyncOwnClient != 0 {
+		t.Errorf("client should not be snapped back with MovementCallback policy")
+	}
+	if stats := GetMovementViolationStats()[e.ID]; stats == nil || stats.Total != 1 || stats.ByKind[MovementViolationUnwalkable] != 1 {
+		t.Errorf("movement violation should be recorded: %+v", stats)
+	}
+	clearMovementViolationStats(e.ID)
+}
@@ -0,0 +1,131 @@
+package entity
+
+import (
+	"github.com/lovelly/goworld/engine/async"
+	"github.com/lovelly/goworld/engine/gwlog"
+	"github.com/lovelly/goworld/engine/nav"
+	"github.com/lovelly/goworld/engine/post"
+)
+
+const (
+	_NAV_ASYNC_JOB_GROUP = "_nav"
+)
+
+var (
+	spaceNavMaps = map[int]nav.NavMap{}
+)
+
+// FindPathCallback is type of FindPath callback
+type FindPathCallback func(path []Vector3, err error)
+
+// SetSpaceNavMap sets the navigation map of spaces of specified kind
+//
+// Should be called before spaces of the kind are created, usually before goworld.Run
+func SetSpaceNavMap(kind int, navMap nav.NavMap) {
+	spaceNavMaps[kind] = navMap
+}
+
+// LoadSpaceNavMap loads the navigation map file of spaces of specified kind
+//
+// The file is a grid map (.grid) or a navmesh (.navmesh), see nav.Load
+func LoadSpaceNavMap(kind int, path string) error {
+	navMap, err := nav.Load(path)
+	if err != nil {
+		return err
+	}
+
+	gwlog.Infof("Navigation map of space kind %d loaded: %s", kind, path)
+	SetSpaceNavMap(kind, navMap)
+	return nil
+}
+
+func vector3ToNavPoint(v Vector3) nav.Point {
+	return nav.Point{X: float32(v.X), Y: float32(v.Y), Z: float32(v.Z)}
+}
+
+func navPointToVector3(p nav.Point) Vector3 {
+	return Vector3{Coord(p.X), Coord(p.Y), Coord(p.Z)}
+}
+
+// GetNavMap returns the navigation map of space, or nil if the space kind has no navigation map
+func (space *Space) GetNavMap() nav.NavMap {
+	return spaceNavMaps[space.Kind]
+}
+
+// IsWalkable returns if the position is walkable in space
+//
+// All positions are walkable if the space has no navigation map
+func (space *Space) IsWalkable(pos Vector3) bool {
+	navMap := space.GetNavMap()
+	if navMap == nil {
+		return true
+	}
+	return navMap.IsWalkable(vector3ToNavPoint(pos))
+}
+
+// NavRaycast checks if the straight line between two positions is walkable in space
+//
+// returns the farthest walkable position along the line, and true if the line is blocked
+func (space *Space) NavRaycast(from, to Vector3) (Vector3, bool) {
+	navMap := space.GetNavMap()
+	if navMap == nil {
+		return to, false
+	}
+	hit, blocked := navMap.Raycast(vector3ToNavPoint(from), vector3ToNavPoint(to))
+	return navPointToVector3(hit), blocked
+}
+
+// FindPath finds a walkable path between two positions in space, returns in callback
+//
+// The path is found in async goroutine, and callback is called in the game routine with the path including both positions,
+// or nav.ErrNoPath if there is no path. The path is a straight line if the space has no navigation map.
+func (space *Space) FindPath(from, to Vector3, callback FindPathCallback) {
+	navMap := space.GetNavMap()
+	if navMap == nil {
+		post.Post(func() {
+			callback([]Vector3{from, to}, nil)
+		})
+		return
+	}
+
+	async.AppendAsyncJob(_NAV_ASYNC_JOB_GROUP, func() (res interface{}, err error) {
+		points, err := navMap.FindPath(vector3ToNavPoint(from), vector3ToNavPoint(to))
+		if err != nil {
+			return nil, err
+		}
+
+		path := make([]Vector3, len(points))
+		for i, p := range points {
+			path[i] = navPointToVector3(p)
+		}
+		return path, nil
+	}, findPathAsyncCallback(callback))
+}
+
+func findPathAsyncCallback(callback FindPathCallback) async.AsyncCallback {
+	return func(res interface{}, err error) {
+		if err != nil {
+			callback(nil, err)
+		} else {
+			callback(res.([]Vector3), nil)
+		}
+	}
+}
+
+// FindPath finds a walkable path from the position of entity to the target position in the current space, returns in callback
+//
+// callback is not called if the entity is destroyed or has left the space before the path is found.
+func (e *Entity) FindPath(to Vector3, callback FindPathCallback) {
+	space := e.Space
+	if space == nil || space.IsNil() {
+		gwlog.Warnf("%s.FindPath: not in any space", e)
+		return
+	}
+
+	space.FindPath(e.GetPosition(), to, func(path []Vector3, err error) {
+		if e.IsDestroyed() || e.Space != space {
+			return
+		}
+		callback(path, err)
+	})
+}
@@ -0,0 +1,71 @@
+package entity
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lovelly/goworld/engine/nav"
+	"github.com/lovelly/goworld/engine/post"
+)
+
+// waitPost runs posted callbacks until cond is true or timeout
+func waitPost(t *testing.T, cond func() bool) {
+	deadline := time.Now().Add(time.Second * 5)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout")
+		}
+		time.Sleep(time.Millisecond)
+		post.Tick()
+	}
+}
+
+func TestSpaceNavigation(t *testing.T) {
+	const navSpaceKind = 1001
+	navMap := nav.NewGridMap(10, 10, 1, 0, 0)
+	for z := 0; z < 9; z++ {
+		navMap.SetWalkable(5, z, false) // a wall with a hole at z = 9
+	}
+	SetSpaceNavMap(navSpaceKind, navMap)
+	defer delete(spaceNavMaps, navSpaceKind)
+
+	space := newTestSpace(navSpaceKind)
+	if !space.IsWalkable(Vector3{1, 0, 1}) || space.IsWalkable(Vector3{5.5, 0, 1}) {
+		t.Errorf("wrong walkability")
+	}
+	if hit, blocked := space.NavRaycast(Vector3{1.5, 0, 1.5}, Vector3{8.5, 0, 1.5}); !blocked || hit.X > 5 {
+		t.Errorf("raycast should be blocked by wall, but got %v, %v", hit, blocked)
+	}
+
+	e := newTestAOIEntity("testAOIEntity")
+	space.enter(e, Vector3{1.5, 0, 1.5}, false)
+	var path []Vector3
+	var pathErr error
+	done := false
+	e.FindPath(Vector3{8.5, 0, 1.5}, func(p []Vector3, err error) {
+		path, pathErr, done = p, err, true
+	})
+	waitPost(t, func() bool { return done })
+	if pathErr != nil || len(path) < 3 || path[0] != (Vector3{1.5, 0, 1.5}) || path[len(path)-1] != (Vector3{8.5, 0, 1.5}) {
+		t.Errorf("wrong path: %v, %v", path, pathErr)
+	}
+
+	done = false
+	space.FindPath(Vector3{1.5, 0, 1.5}, Vector3{5.5, 0, 1.5}, func(p []Vector3, err error) {
+		pathErr, done = err, true
+	})
+	waitPost(t, func() bool { return done })
+	if pathErr != nav.ErrNoPath {
+		t.Errorf("path to wall should not be found, but got %v", pathErr)
+	}
+
+	// spaces without navigation map find straight paths
+	done = false
+	newTestSpace(1).FindPath(Vector3{}, Vector3{10, 0, 10}, func(p []Vector3, err error) {
+		path, pathErr, done = p, err, true
+	})
+	waitPost(t, func() bool { return done })
+	if pathErr != nil || len(path) != 2 {
+		t.Errorf("wrong path: %v, %v", path, pathErr)
+	}
+}
@@ -0,0 +1,174 @@
+package entity
+
+import (
+	"fmt"
+
+	"github.com/lovelly/goworld/components/dispatcher/dispatcherclient"
+	"github.com/lovelly/goworld/engine/common"
+	"github.com/lovelly/goworld/engine/gwlog"
+	"github.com/lovelly/goworld/engine/storage"
+	"github.com/lovelly/goworld/engine/uuid"
+	"github.com/xiaonanln/typeconv"
+)
+
+const (
+	_SPACE_MEMBERS_ATTR_KEY = "_M" // persistent members of space: EntityID => {T: type name, X, Y, Z: position}
+)
+
+type persistentSpaceKind struct {
+	memberTypes common.StringSet // types of members re-entered when space is loaded
+}
+
+var (
+	persistentSpaceKinds = map[int]*persistentSpaceKind{}
+	gameTerminating      bool // members are kept when entities are destroyed for game terminating
+)
+
+// SetSpaceKindPersistent makes spaces of the kind persistent, so that persistent attributes of spaces are saved and loaded
+//
+// Persistent entities of memberTypes in spaces are saved with their positions, and are loaded and re-entered at
+// the saved positions when the space is loaded. Members which are already on any game are not loaded again and are
+// removed from the space. Should be called on all games before goworld.Run.
+func SetSpaceKindPersistent(kind int, memberTypes ...string) {
+	if kind == 0 {
+		gwlog.Panicf("SetSpaceKindPersistent: nil space can not be persistent")
+	}
+
+	psk := &persistentSpaceKind{memberTypes: common.StringSet{}}
+	for _, typeName := range memberTypes {
+		psk.memberTypes.Add(typeName)
+	}
+	persistentSpaceKinds[kind] = psk
+}
+
+// IsSpaceKindPersistent returns if spaces of the kind are persistent
+func IsSpaceKindPersistent(kind int) bool {
+	return persistentSpaceKinds[kind] != nil
+}
+
+// PersistentSpaceID returns the stable space ID of the space kind and instance name
+//
+// Use empty instance for the only space of the kind.
+func PersistentSpaceID(kind int, instance string) common.EntityID {
+	return common.EntityID(uuid.GenFixedUUID([]byte(fmt.Sprintf("%s:%d:%s", _SPACE_ENTITY_TYPE, kind, instance))))
+}
+
+// LoadPersistentSpaceAnywhere loads the persistent space of the kind and instance name in any game, and returns the space ID
+//
+// The space is created if it is not found in storage. Nothing happens if the space already exists on any game.
+// callback can be nil, or is called when the space is loaded or created, see CreateEntityAnywhereWithCallback.
+func LoadPersistentSpaceAnywhere(kind int, instance string, callback CreateEntityCallback) common.EntityID {
+	if !IsSpaceKindPersistent(kind) {
+		gwlog.Panicf("LoadPersistentSpaceAnywhere: space kind %d is not persistent", kind)
+	}
+
+	spaceID := PersistentSpaceID(kind, instance)
+	storage.Exists(_SPACE_ENTITY_TYPE, spaceID, func(exists bool, err error) {
+		if err != nil {
+			gwlog.Errorf("LoadPersistentSpaceAnywhere: check space %s of kind %d failed: %s", spaceID, kind, err)
+			if callback != nil {
+				callCreateEntityCallback(callback, spaceID, 0, err)
+			}
+			return
+		}
+
+		var requestID uint32
+		if callback != nil {
+			requestID = addPendingCreateReply(spaceID, callback)
+		}
+		if exists {
+			loadEntityAnywhere(_SPACE_ENTITY_TYPE, spaceID, requestID)
+		} else {
+			createEntityAnywhere(_SPACE_ENTITY_TYPE, spaceID, map[string]interface{}{
+				_SPACE_KIND_ATTR_KEY: kind,
+			}, requestID)
+		}
+	})
+	return spaceID
+}
+
+// isPersistentMember returns if the entity is saved as a member of the space
+func (space *Space) isPersistentMember(entity *Entity) bool {
+	psk := persistentSpaceKinds[space.Kind]
+	return psk != nil && psk.memberTypes.Contains(entity.TypeName) && entity.IsPersistent()
+}
+
+func (space *Space) getMembersAttr() *MapAttr {
+	return space.GetMapAttr(_SPACE_MEMBERS_ATTR_KEY)
+}
+
+// onPersistentSpaceCreated saves the persistent space and re-enters saved members
+func (space *Space) onPersistentSpaceCreated() {
+	if !space.IsPersistent() {
+		return
+	}
+
+	space.saveAll = true // save the new space immediately
+	space.Save()
+
+	members := space.getMembersAttr()
+	for _, key := range members.Keys() {
+		// members are added back when they enter the space
+		member := members.Pop(key).(*MapAttr)
+		typeName := member.GetStr("T")
+		pos := Vector3{
+			Coord(typeconv.Float(member.Get("X"))),
+			Coord(typeconv.Float(member.Get("Y"))),
+			Coord(typeconv.Float(member.Get("Z"))),
+		}
+		if _, ok := registeredEntityTypes[typeName]; !ok {
+			gwlog.Errorf("%s: member %s.%s is not re-entered: entity type is not registered", space, typeName, key)
+			continue
+		}
+		space.loadMember(typeName, common.EntityID(key), pos)
+	}
+}
+
+// loadMember loads the member in the space through dispatcher, so that members already on any game are not loaded again
+func (space *Space) loadMember(typeName string, entityID common.EntityID, pos Vector3) {
+	dispatcherclient.GetDispatcherClientForSend().SendLoadEntityInSpace(typeName, entityID, space.ID, float32(pos.X), float32(pos.Y), float32(pos.Z))
+}
+
+func (space *Space) onPersistentMemberEnter(entity *Entity) {
+	if !space.isPersistentMember(entity) {
+		return
+	}
+
+	member := NewMapAttr()
+	member.SetStr("T", entity.TypeName)
+	space.getMembersAttr().SetMapAttr(string(entity.ID), member)
+	space.saveMemberPosition(member, entity)
+}
+
+func (space *Space) onPersistentMemberLeave(entity *Entity) {
+	if space.destroying || gameTerminating || !space.isPersistentMember(entity) {
+		return // members are kept if they leave because the space or game is shutting down
+	}
+
+	members := space.getMembersAttr()
+	if members.HasKey(string(entity.ID)) {
+		members.Del(string(entity.ID))
+	}
+}
+
+// saveMemberPositions updates positions of members in space before saving
+func (space *Space) saveMemberPositions() {
+	members := space.getMembersAttr()
+	for e := range space.entities {
+		if members.HasKey(string(e.ID)) {
+			space.saveMemberPosition(members.GetMapAttr(string(e.ID)), e)
+		}
+	}
+}
+
+func (space *Space) saveMemberPosition(member *MapAttr, entity *Entity) {
+	pos := entity.aoi.pos
+	if member.HasKey("X") && Coord(typeconv.Float(member.Get("X"))) == pos.X &&
+		Coord(typeconv.Float(member.Get("Y"))) == pos.Y && Coord(typeconv.Float(member.Get("Z"))) == pos.Z {
+		return // not moved
+	}
+
+	member.SetFloat("X", float64(pos.X))
+	member.SetFloat("Y", float64(pos.Y))
+	member.SetFloat("Z", float64(pos.Z))
+}
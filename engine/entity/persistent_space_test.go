@@ -0,0 +1,63 @@
+package entity
+
+import (
+	"testing"
+
+	"github.com/xiaonanln/typeconv"
+)
+
+type testPersistentMember struct {
+	Entity
+}
+
+func (e *testPersistentMember) DefineAttrs(desc *EntityTypeDesc) {
+}
+
+func TestPersistentSpaceID(t *testing.T) {
+	id := PersistentSpaceID(1, "house")
+	if id != PersistentSpaceID(1, "house") || id == PersistentSpaceID(2, "house") || id == PersistentSpaceID(1, "") {
+		t.Fatalf("persistent space ID should be stable and unique by kind and instance: %s", id)
+	}
+}
+
+func TestPersistentSpaceMembers(t *testing.T) {
+	const kind = 100
+	SetSpaceKindPersistent(kind, "testPersistentMember")
+	defer delete(persistentSpaceKinds, kind)
+
+	space := newTestSpace(kind)
+	space.Attrs.SetInt(_SPACE_KIND_ATTR_KEY, kind)
+	space.assignAttrDefaults()
+	if !space.IsPersistent() || newTestSpace(1).IsPersistent() {
+		t.Fatalf("only spaces of persistent kinds should be persistent")
+	}
+
+	member := newTestEntity("testPersistentMember", &testPersistentMember{}, true, true)
+	other := newTestAOIEntity("testAOIEntity")
+	space.enter(member, Vector3{1, 2, 3}, false)
+	space.enter(other, Vector3{}, false)
+	members := space.getMembersAttr()
+	if members.Size() != 1 || !members.HasKey(string(member.ID)) {
+		t.Fatalf("only persistent entities of member types should be members: %v", members.Keys())
+	}
+
+	space.move(member, Vector3{4, 5, 6})
+	space.saveMemberPositions()
+	saved := members.GetMapAttr(string(member.ID))
+	if saved.GetStr("T") != "testPersistentMember" || typeconv.Float(saved.Get("X")) != 4 || typeconv.Float(saved.Get("Z")) != 6 {
+		t.Fatalf("member should be saved with type and position: %v", saved.ToMap())
+	}
+
+	space.leave(member)
+	if members.Size() != 0 {
+		t.Fatalf("member should be removed after leaving space")
+	}
+
+	// members are kept when the space is destroying
+	space.enter(member, Vector3{}, false)
+	space.destroying = true
+	space.leave(member)
+	if members.Size() != 1 {
+		t.Fatalf("member should be kept when leaving destroying space")
+	}
+}
@@ -0,0 +1,166 @@
+package entity
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/lovelly/goworld/engine/gwlog"
+)
+
+const (
+	// _PERSISTENT_TIMERS_ATTR_KEY is the persistent attribute for saving persistent timers
+	_PERSISTENT_TIMERS_ATTR_KEY = "_T"
+	// _MAX_TIMER_CATCH_UP_FIRES is the max number of missed fires of a timer to catch up
+	_MAX_TIMER_CATCH_UP_FIRES = 100
+)
+
+// TimerCatchUpPolicy decides how persistent timers expired while the entity is not loaded are fired
+type TimerCatchUpPolicy int
+
+const (
+	// TimerCatchUpFireOnce fires expired callbacks and timers once when entity is loaded
+	TimerCatchUpFireOnce TimerCatchUpPolicy = iota
+	// TimerCatchUpFireAll fires expired callbacks once and expired timers once for every missed interval when entity is loaded
+	TimerCatchUpFireAll
+	// TimerCatchUpSkip drops expired callbacks and skips missed fires of expired timers
+	TimerCatchUpSkip
+)
+
+// SetTimerCatchUpPolicy sets how persistent timers expired while the entity is not loaded are fired
+func (desc *EntityTypeDesc) SetTimerCatchUpPolicy(policy TimerCatchUpPolicy) {
+	desc.timerCatchUpPolicy = policy
+}
+
+// AddPersistentCallback adds a one-time callback which is saved with persistent attributes
+//
+// The callback is restored when the entity is loaded again, arguments should be able to be saved as attributes
+func (e *Entity) AddPersistentCallback(d time.Duration, method string, args ...interface{}) EntityTimerID {
+	e.checkPersistentTimer(method)
+	tid := e.AddCallback(d, method, args...)
+	e.savePersistentTimer(tid)
+	return tid
+}
+
+// AddPersistentTimer adds a repeat timer which is saved with persistent attributes
+//
+// The timer is restored when the entity is loaded again, arguments should be able to be saved as attributes
+func (e *Entity) AddPersistentTimer(d time.Duration, method string, args ...interface{}) EntityTimerID {
+	e.checkPersistentTimer(method)
+	tid := e.AddTimer(d, method, args...)
+	e.savePersistentTimer(tid)
+	return tid
+}
+
+func (e *Entity) checkPersistentTimer(method string) {
+	if !e.IsPersistent() {
+		gwlog.Panicf("%s is not persistent, can not add persistent timer %s", e, method)
+	}
+}
+
+func (e *Entity) getPersistentTimersAttr() *MapAttr {
+	if !e.Attrs.HasKey(_PERSISTENT_TIMERS_ATTR_KEY) {
+		e.Attrs.SetMapAttr(_PERSISTENT_TIMERS_ATTR_KEY, NewMapAttr())
+	}
+	return e.Attrs.GetMapAttr(_PERSISTENT_TIMERS_ATTR_KEY)
+}
+
+// savePersistentTimer saves the timer to persistent attributes
+func (e *Entity) savePersistentTimer(tid EntityTimerID) {
+	info := e.timers[tid]
+	info.Persistent = true
+
+	args := NewListAttr()
+	args.AssignList(info.Args)
+	ta := NewMapAttr()
+	ta.SetInt("t", info.FireTime.UnixNano())
+	ta.SetInt("i", int64(info.RepeatInterval))
+	ta.SetStr("m", info.Method)
+	ta.SetListAttr("a", args)
+	ta.SetBool("r", info.Repeat)
+	e.getPersistentTimersAttr().SetMapAttr(strconv.Itoa(int(tid)), ta)
+}
+
+// updatePersistentTimer updates the next fire time of persistent timer
+func (e *Entity) updatePersistentTimer(tid EntityTimerID, fireTime time.Time) {
+	e.getPersistentTimersAttr().GetMapAttr(strconv.Itoa(int(tid))).SetInt("t", fireTime.UnixNano())
+}
+
+// delPersistentTimer deletes the persistent timer from persistent attributes
+func (e *Entity) delPersistentTimer(tid EntityTimerID) {
+	timers := e.getPersistentTimersAttr()
+	key := strconv.Itoa(int(tid))
+	if timers.HasKey(key) {
+		timers.Del(key)
+	}
+}
+
+// restorePersistentTimers restores persistent timers from persistent attributes
+func (e *Entity) restorePersistentTimers() {
+	if !e.Attrs.HasKey(_PERSISTENT_TIMERS_ATTR_KEY) {
+		return
+	}
+
+	timers := e.Attrs.GetMapAttr(_PERSISTENT_TIMERS_ATTR_KEY)
+	keys := timers.Keys()
+	// keep timer IDs if possible, so that they can be used to cancel timers after loaded
+	for _, key := range keys {
+		if id, err := strconv.Atoi(key); err == nil && EntityTimerID(id) > e.lastTimerId {
+			e.lastTimerId = EntityTimerID(id)
+		}
+	}
+
+	policy := e.typeDesc.timerCatchUpPolicy
+	now := time.Now()
+	for _, key := range keys {
+		ta := timers.GetMapAttr(key)
+		info := &entityTimerInfo{
+			FireTime:       time.Unix(0, ta.GetInt("t")),
+			RepeatInterval: time.Duration(ta.GetInt("i")),
+			Method:         ta.GetStr("m"),
+			Args:           ta.GetListAttr("a").ToList(),
+			Repeat:         ta.GetBool("r"),
+			Persistent:     true,
+		}
+
+		var tid EntityTimerID
+		if id, err := strconv.Atoi(key); err == nil && id > 0 && e.timers[EntityTimerID(id)] == nil {
+			tid = EntityTimerID(id)
+		} else {
+			// timer ID is used, use a new one
+			tid = e.genTimerId()
+			timers.SetMapAttr(strconv.Itoa(int(tid)), timers.PopMapAttr(key))
+		}
+
+		delay := info.FireTime.Sub(now)
+		if delay < 0 {
+			missed := 1
+			if info.Repeat && info.RepeatInterval > 0 {
+				missed += int(-delay / info.RepeatInterval)
+			}
+
+			if policy == TimerCatchUpSkip {
+				if !info.Repeat {
+					gwlog.Debugf("%s: skip expired persistent callback %s", e, info.Method)
+					timers.Del(strconv.Itoa(int(tid)))
+					continue
+				}
+				// skip missed fires and wait for the next fire
+				delay += time.Duration(missed) * info.RepeatInterval
+			} else {
+				delay = 0
+				if policy == TimerCatchUpFireAll && missed > 1 {
+					if missed > _MAX_TIMER_CATCH_UP_FIRES {
+						missed = _MAX_TIMER_CATCH_UP_FIRES
+					}
+					info.catchUpFires = missed - 1
+				}
+			}
+		}
+
+		e.timers[tid] = info
+		info.rawTimer = e.addRawCallback(delay, func() {
+			e.triggerTimer(tid, false)
+		})
+	}
+	gwlog.Debugf("%s: %d persistent timers restored", e, len(keys))
+}
@@ -0,0 +1,97 @@
+package entity
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+type testPersistentTimerEntity struct {
+	Entity
+}
+
+func (e *testPersistentTimerEntity) DefineAttrs(desc *EntityTypeDesc) {
+	desc.DefineAttr("level", "Persistent")
+}
+
+func (e *testPersistentTimerEntity) OnTimer(arg string) {
+}
+
+func newTestPersistentTimerEntity(data map[string]interface{}) *testPersistentTimerEntity {
+	e := newTestEntity("testPersistentTimerEntity", &testPersistentTimerEntity{}, true, false).I.(*testPersistentTimerEntity)
+	if data != nil {
+		e.loadPersistentData(data)
+	}
+	return e
+}
+
+func TestPersistentTimers(t *testing.T) {
+	e := newTestPersistentTimerEntity(nil)
+	cid := e.AddPersistentCallback(time.Hour, "OnTimer", "callback")
+	tid := e.AddPersistentTimer(time.Minute, "OnTimer", "timer")
+	e.AddCallback(time.Hour, "OnTimer", "not persistent")
+
+	data := e.getPersistentData()
+	if timers, ok := data[_PERSISTENT_TIMERS_ATTR_KEY].(map[string]interface{}); !ok || len(timers) != 2 {
+		t.Fatalf("persistent timers should be saved, but got %v", data[_PERSISTENT_TIMERS_ATTR_KEY])
+	}
+
+	e2 := newTestPersistentTimerEntity(data)
+	e2.restorePersistentTimers()
+	if len(e2.timers) != 2 {
+		t.Fatalf("2 persistent timers should be restored, but got %d", len(e2.timers))
+	}
+	if info := e2.timers[cid]; info == nil || info.Repeat || info.Method != "OnTimer" || !reflect.DeepEqual(info.Args, []interface{}{"callback"}) {
+		t.Errorf("callback is not restored: %+v", info)
+	}
+	if info := e2.timers[tid]; info == nil || !info.Repeat || info.RepeatInterval != time.Minute || !info.FireTime.Equal(e.timers[tid].FireTime) {
+		t.Errorf("timer is not restored: %+v", info)
+	}
+
+	e2.CancelTimer(cid)
+	if e2.Attrs.GetMapAttr(_PERSISTENT_TIMERS_ATTR_KEY).Size() != 1 {
+		t.Errorf("cancelled timer should be removed from attributes")
+	}
+}
+
+func TestPersistentTimerCatchUp(t *testing.T) {
+	e := newTestPersistentTimerEntity(nil)
+	cid := e.AddPersistentCallback(time.Second, "OnTimer", "callback")
+	tid := e.AddPersistentTimer(time.Minute, "OnTimer", "timer")
+	// pretend the timers were saved 10.5 minutes ago, so the timer missed 10 fires
+	e.timers[cid].FireTime = e.timers[cid].FireTime.Add(-10*time.Minute - 30*time.Second)
+	e.timers[tid].FireTime = e.timers[tid].FireTime.Add(-10*time.Minute - 30*time.Second)
+	e.savePersistentTimer(cid)
+	e.savePersistentTimer(tid)
+	data := e.getPersistentData()
+
+	desc := registeredEntityTypes["testPersistentTimerEntity"]
+	defer desc.SetTimerCatchUpPolicy(TimerCatchUpFireOnce)
+
+	desc.SetTimerCatchUpPolicy(TimerCatchUpFireOnce)
+	e2 := newTestPersistentTimerEntity(data)
+	e2.restorePersistentTimers()
+	if e2.timers[cid] == nil || e2.timers[tid] == nil || e2.timers[tid].catchUpFires != 0 {
+		t.Errorf("expired timers should be fired once")
+	}
+
+	desc.SetTimerCatchUpPolicy(TimerCatchUpFireAll)
+	e2 = newTestPersistentTimerEntity(data)
+	e2.restorePersistentTimers()
+	if e2.timers[cid] == nil || e2.timers[cid].catchUpFires != 0 {
+		t.Errorf("expired callback should be fired once")
+	}
+	if e2.timers[tid] == nil || e2.timers[tid].catchUpFires != 9 {
+		t.Errorf("expired timer should be fired 10 times for missed fires")
+	}
+
+	desc.SetTimerCatchUpPolicy(TimerCatchUpSkip)
+	e2 = newTestPersistentTimerEntity(data)
+	e2.restorePersistentTimers()
+	if e2.timers[cid] != nil {
+		t.Errorf("expired callback should be dropped")
+	}
+	if e2.timers[tid] == nil || e2.timers[tid].catchUpFires != 0 {
+		t.Errorf("expired timer should skip missed fires")
+	}
+}
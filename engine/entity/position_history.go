@@ -0,0 +1,200 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/lovelly/goworld/engine/gwlog"
+)
+
+const (
+	_POSITION_HISTORY_INITIAL_CAPACITY = 16
+)
+
+type positionSample struct {
+	time time.Time
+	pos  Vector3
+	yaw  Yaw
+}
+
+// positionHistory is a ring buffer of position samples within the window
+type positionHistory struct {
+	window  time.Duration
+	samples []positionSample
+	head    int // index of the oldest sample
+	count   int
+
+	boundsMin, boundsMax Vector3 // bounds of all samples, valid if boundsValid
+	boundsValid          bool
+}
+
+func (h *positionHistory) at(i int) *positionSample {
+	return &h.samples[(h.head+i)%len(h.samples)]
+}
+
+func (h *positionHistory) record(now time.Time, pos Vector3, yaw Yaw) {
+	h.boundsValid = false
+	if h.count > 0 && !h.at(h.count-1).time.Before(now) {
+		// replace the sample of the same time
+		*h.at(h.count - 1) = positionSample{now, pos, yaw}
+		return
+	}
+
+	// drop samples out of the window, but keep the latest one before the window for interpolation
+	windowStart := now.Add(-h.window)
+	for h.count >= 2 && !h.at(1).time.After(windowStart) {
+		h.head = (h.head + 1) % len(h.samples)
+		h.count -= 1
+	}
+
+	if h.count == len(h.samples) {
+		samples := make([]positionSample, len(h.samples)*2)
+		for i := 0; i < h.count; i++ {
+			samples[i] = *h.at(i)
+		}
+		h.samples, h.head = samples, 0
+	}
+	*h.at(h.count) = positionSample{now, pos, yaw}
+	h.count += 1
+}
+
+// bounds returns the min and max corners of all samples, which contain positions at any time in the window
+func (h *positionHistory) bounds() (min, max Vector3) {
+	if !h.boundsValid {
+		h.boundsMin, h.boundsMax = h.at(0).pos, h.at(0).pos
+		for i := 1; i < h.count; i++ {
+			pos := h.at(i).pos
+			h.boundsMin = Vector3{minCoord(h.boundsMin.X, pos.X), minCoord(h.boundsMin.Y, pos.Y), minCoord(h.boundsMin.Z, pos.Z)}
+			h.boundsMax = Vector3{maxCoord(h.boundsMax.X, pos.X), maxCoord(h.boundsMax.Y, pos.Y), maxCoord(h.boundsMax.Z, pos.Z)}
+		}
+		h.boundsValid = true
+	}
+	return h.boundsMin, h.boundsMax
+}
+
+// positionAt returns the position and yaw at time t by interpolating samples
+//
+// t older than the oldest sample is clamped to the oldest sample.
+func (h *positionHistory) positionAt(t time.Time) (Vector3, Yaw) {
+	for i := h.count - 1; i >= 0; i-- {
+		s := h.at(i)
+		if s.time.After(t) {
+			continue
+		}
+		if i == h.count-1 {
+			return s.pos, s.yaw
+		}
+
+		next := h.at(i + 1)
+		ratio := Coord(t.Sub(s.time).Seconds() / next.time.Sub(s.time).Seconds())
+		return s.pos.Add(next.pos.Sub(s.pos).Mul(ratio)), s.yaw
+	}
+	oldest := h.at(0)
+	return oldest.pos, oldest.yaw
+}
+
+// EnablePositionHistory records positions and yaws of entity within the window for lag compensation
+//
+// Positions of entity at a past time can be queried by GetPositionYawAt and rewinding queries of Space, e.g. Space.RaycastAt.
+func (e *Entity) EnablePositionHistory(window time.Duration) {
+	if window <= 0 {
+		gwlog.Panicf("%s.EnablePositionHistory: invalid window %s", e, window)
+	}
+
+	if e.history == nil {
+		e.history = &positionHistory{samples: make([]positionSample, _POSITION_HISTORY_INITIAL_CAPACITY)}
+		if e.Space != nil && !e.Space.IsNil() {
+			e.Space.historyEntities.Add(e)
+		}
+	}
+	e.history.window = window
+	e.recordPositionHistory()
+}
+
+// DisablePositionHistory stops recording positions of entity and drops the history
+func (e *Entity) DisablePositionHistory() {
+	e.history = nil
+	if e.Space != nil && !e.Space.IsNil() {
+		e.Space.historyEntities.Del(e)
+	}
+}
+
+// GetPositionYawAt returns the position and yaw of entity at time t
+//
+// Positions between samples are interpolated, and t older than the history window is clamped to the oldest sample.
+// Returns the current position and yaw if position history is not enabled.
+func (e *Entity) GetPositionYawAt(t time.Time) (Vector3, Yaw) {
+	if e.history == nil || e.history.count == 0 {
+		return e.aoi.pos, e.yaw
+	}
+	return e.history.positionAt(t)
+}
+
+func (e *Entity) recordPositionHistory() {
+	if e.history != nil && e.Space != nil && !e.Space.IsNil() {
+		e.history.record(time.Now(), e.aoi.pos, e.yaw)
+	}
+}
+
+// resetPositionHistory drops samples of entity, called when entity enters a new space
+func (e *Entity) resetPositionHistory() {
+	if e.history != nil {
+		e.Space.historyEntities.Add(e)
+		e.history.head, e.history.count = 0, 0
+		e.recordPositionHistory()
+	}
+}
+
+// visitEntitiesAt calls f with entities in space and their positions at time t
+//
+// Entities with position history are rewound, other entities are at current positions within the box from min to max.
+func (space *Space) visitEntitiesAt(t time.Time, min, max Vector3, filter EntityFilter, f func(e *Entity, pos Vector3)) {
+	space.visitBox(min, max, filter, func(e *Entity) {
+		if e.history == nil {
+			f(e, e.aoi.pos)
+		}
+	})
+
+	for e := range space.historyEntities {
+		if e.history.count == 0 || (filter != nil && !filter(e)) {
+			continue
+		}
+
+		// cull by bounds of samples before interpolating
+		bmin, bmax := e.history.bounds()
+		if bmax.X < min.X || bmin.X > max.X || bmax.Y < min.Y || bmin.Y > max.Y || bmax.Z < min.Z || bmin.Z > max.Z {
+			continue
+		}
+		pos, _ := e.history.positionAt(t)
+		f(e, pos)
+	}
+}
+
+// DistanceAt returns the distance between two entities at time t
+func (space *Space) DistanceAt(t time.Time, e1, e2 *Entity) Coord {
+	pos1, _ := e1.GetPositionYawAt(t)
+	pos2, _ := e2.GetPositionYawAt(t)
+	return pos1.DistanceTo(pos2)
+}
+
+// GetEntitiesInRadiusAt returns entities within the radius of center at time t
+//
+// filter can be nil to select all entities
+func (space *Space) GetEntitiesInRadiusAt(t time.Time, center Vector3, radius Coord, filter EntityFilter) EntitySet {
+	entities := EntitySet{}
+	extent := Vector3{radius, radius, radius}
+	space.visitEntitiesAt(t, center.Sub(extent), center.Add(extent), filter, func(e *Entity, pos Vector3) {
+		if center.DistanceTo(pos) <= radius {
+			entities.Add(e)
+		}
+	})
+	return entities
+}
+
+// RaycastAt returns the first entity hit by the ray at time t, and the distance of the hit
+//
+// See Raycast for details.
+func (space *Space) RaycastAt(t time.Time, origin Vector3, dir Vector3, maxDist Coord, hitRadius Coord, filter EntityFilter) (*Entity, Coord) {
+	return space.raycast(origin, dir, maxDist, hitRadius, func(min, max Vector3, f func(e *Entity, pos Vector3)) {
+		space.visitEntitiesAt(t, min, max, filter, f)
+	})
+}
@@ -0,0 +1,82 @@
+package entity
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPositionHistory(t *testing.T) {
+	h := &positionHistory{window: time.Second, samples: make([]positionSample, 2)}
+	t0 := time.Now()
+	for i := 0; i <= 20; i++ {
+		h.record(t0.Add(time.Millisecond*100*time.Duration(i)), Vector3{Coord(i), 0, 0}, Yaw(i))
+	}
+
+	// samples older than the window are dropped, except the latest one before the window
+	if h.count != 11 || h.at(0).pos.X != 10 {
+		t.Fatalf("history should keep 11 samples from 10, but got %d samples from %v", h.count, h.at(0).pos)
+	}
+
+	for _, c := range []struct {
+		ms  int
+		x   Coord
+		yaw Yaw
+	}{{1500, 15, 15}, {1550, 15.5, 15}, {2000, 20, 20}, {3000, 20, 20}, {0, 10, 10}} {
+		pos, yaw := h.positionAt(t0.Add(time.Millisecond * time.Duration(c.ms)))
+		if pos.DistanceTo(Vector3{c.x, 0, 0}) > 0.001 || yaw != c.yaw {
+			t.Errorf("position at %dms should be %v, %v, but got %v, %v", c.ms, c.x, c.yaw, pos, yaw)
+		}
+	}
+}
+
+func TestSpaceQueriesAt(t *testing.T) {
+	space := newTestSpace(1)
+	shooter := newTestAOIEntity("testAOIEntity")
+	target := newTestAOIEntity("testAOIEntity")
+	other := newTestAOIEntity("testAOIEntity")
+	space.enter(shooter, Vector3{}, false)
+	space.enter(target, Vector3{10, 0, 0}, false)
+	space.enter(other, Vector3{0, 0, 10}, false)
+
+	target.EnablePositionHistory(time.Second)
+	defer target.DisablePositionHistory()
+	t0 := time.Now()
+	target.history.record(t0, Vector3{10, 0, 0}, 0)
+	space.move(target, Vector3{10, 0, 20})
+	target.history.record(t0.Add(time.Millisecond*200), Vector3{10, 0, 20}, 0)
+
+	// target was on the ray 200ms ago, but has moved away
+	notShooter := func(e *Entity) bool { return e != shooter }
+	if hit, _ := space.Raycast(Vector3{}, Vector3{1, 0, 0}, 100, 1, notShooter); hit != nil {
+		t.Fatalf("current raycast should hit nothing, but hit %s", hit)
+	}
+	if hit, dist := space.RaycastAt(t0, Vector3{}, Vector3{1, 0, 0}, 100, 1, notShooter); hit != target || dist != 9 {
+		t.Fatalf("rewound raycast should hit target at 9, but hit %v at %v", hit, dist)
+	}
+
+	if d := space.DistanceAt(t0.Add(time.Millisecond*100), shooter, target); d < 14.14 || d > 14.15 {
+		t.Errorf("distance at 100ms should be sqrt(200), but got %v", d)
+	}
+	entities := space.GetEntitiesInRadiusAt(t0, Vector3{}, 11, nil)
+	if len(entities) != 3 || !entities.Contains(target) {
+		t.Errorf("all entities should be in radius at t0: %v", entities)
+	}
+	if entities := space.GetEntitiesInRadiusAt(t0, Vector3{}, 11, FilterByType("none")); len(entities) != 0 {
+		t.Errorf("filter should exclude all entities: %v", entities)
+	}
+	if entities := space.GetEntitiesInRadiusAt(t0, Vector3{100, 0, 100}, 5, nil); len(entities) != 0 {
+		t.Errorf("entities out of the box should be culled: %v", entities)
+	}
+
+	// entities with position history are visited only by queries of their own space
+	other.EnablePositionHistory(time.Second)
+	space2 := newTestSpace(1)
+	space.leave(other)
+	space2.enter(other, Vector3{0, 0, 10}, false)
+	if space.historyEntities.Contains(other) || !space2.historyEntities.Contains(other) {
+		t.Fatalf("entities with position history should be moved to the new space")
+	}
+	if entities := space.GetEntitiesInRadiusAt(t0, Vector3{}, 11, nil); entities.Contains(other) {
+		t.Errorf("entity in other space should not be visited: %v", entities)
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"strings"
 )
 
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
 const (
 	rfServer      = 1 << iota
 	rfOwnClient   = 1 << iota
@@ -16,6 +18,7 @@ type rpcDesc struct {
 	Flags      uint
 	MethodType reflect.Type
 	NumArgs    int
+	Limits     *RPCLimits
 }
 
 type rpcDescMap map[string]*rpcDesc
@@ -44,3 +47,24 @@ func (rdm rpcDescMap) visit(method reflect.Method) {
 		NumArgs:    methodType.NumIn() - 1, // do not count the receiver
 	}
 }
+
+// callResults converts the return values of RPC method to results and error
+//
+// If the last return value of the method is an error, it is returned as the error of the call
+func (rd *rpcDesc) callResults(out []reflect.Value) (results []interface{}, err error) {
+	numOut := len(out)
+	if numOut > 0 && rd.MethodType.Out(numOut-1) == errorType {
+		numOut -= 1
+		if errVal := out[numOut]; !errVal.IsNil() {
+			err = errVal.Interface().(error)
+		}
+	}
+
+	if numOut > 0 {
+		results = make([]interface{}, numOut)
+		for i := 0; i < numOut; i++ {
+			results[i] = out[i].Interface()
+		}
+	}
+	return
+}
@@ -0,0 +1,51 @@
+package entity
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type testRpcReceiver struct{}
+
+func (r *testRpcReceiver) NoResult()                 {}
+func (r *testRpcReceiver) TwoResults() (int, string) { return 1, "a" }
+func (r *testRpcReceiver) ResultWithError(fail bool) (int, error) {
+	if fail {
+		return 0, errors.New("failed")
+	}
+	return 2, nil
+}
+
+func callTestRpc(t *testing.T, method string, args ...interface{}) ([]interface{}, error) {
+	rdm := rpcDescMap{}
+	m, ok := reflect.TypeOf(&testRpcReceiver{}).MethodByName(method)
+	if !ok {
+		t.Fatalf("method %s not found", method)
+	}
+	rdm.visit(m)
+	rd := rdm[method]
+	in := []reflect.Value{reflect.ValueOf(&testRpcReceiver{})}
+	for _, arg := range args {
+		in = append(in, reflect.ValueOf(arg))
+	}
+	return rd.callResults(rd.Func.Call(in))
+}
+
+func TestRpcCallResults(t *testing.T) {
+	if results, err := callTestRpc(t, "NoResult"); results != nil || err != nil {
+		t.Errorf("NoResult: results=%v, err=%v", results, err)
+	}
+
+	if results, err := callTestRpc(t, "TwoResults"); err != nil || len(results) != 2 || results[0] != 1 || results[1] != "a" {
+		t.Errorf("TwoResults: results=%v, err=%v", results, err)
+	}
+
+	if results, err := callTestRpc(t, "ResultWithError", false); err != nil || len(results) != 1 || results[0] != 2 {
+		t.Errorf("ResultWithError(false): results=%v, err=%v", results, err)
+	}
+
+	if _, err := callTestRpc(t, "ResultWithError", true); err == nil || err.Error() != "failed" {
+		t.Errorf("ResultWithError(true): err=%v", err)
+	}
+}
@@ -0,0 +1,340 @@
+package entity
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/lovelly/goworld/engine/common"
+	"github.com/lovelly/goworld/engine/gwlog"
+)
+
+const (
+	// DefaultMaxRPCArgDepth is the max nesting depth of slice and map arguments of RPC methods called by clients,
+	// if MaxArgDepth is not declared by DefineRPC
+	DefaultMaxRPCArgDepth = 8
+)
+
+// RPCLimits declares the constraints of a RPC method which are enforced before the method is called by clients
+type RPCLimits struct {
+	MaxArgBytes       int        // max total bytes of all encoded arguments, 0 for no limit
+	MaxArgDepth       int        // max nesting depth of slice and map arguments, 0 for DefaultMaxRPCArgDepth
+	MaxCallsPerSecond int        // max calls per second of each client, 0 for no limit
+	Args              []ArgLimit // constraints of arguments, indexed by argument position
+}
+
+// ArgLimit declares the constraint of a RPC argument
+type ArgLimit struct {
+	CheckRange bool    // check if numeric argument is in [Min, Max]
+	Min, Max   float64 //
+	CheckLen   bool    // check if length of string, slice or map argument is in [MinLen, MaxLen]
+	MinLen     int     //
+	MaxLen     int     //
+}
+
+// ArgRange returns an ArgLimit which restricts numeric argument in [min, max]
+func ArgRange(min, max float64) ArgLimit {
+	return ArgLimit{CheckRange: true, Min: min, Max: max}
+}
+
+// ArgLen returns an ArgLimit which restricts length of string, slice or map argument in [minLen, maxLen]
+func ArgLen(minLen, maxLen int) ArgLimit {
+	return ArgLimit{CheckLen: true, MinLen: minLen, MaxLen: maxLen}
+}
+
+// RPCViolationKind is the kind of RPC constraint violation
+type RPCViolationKind int
+
+const (
+	// RPCViolationArgBytes means arguments are too large
+	RPCViolationArgBytes RPCViolationKind = 1 + iota
+	// RPCViolationArgDecode means argument can not be decoded to the parameter type
+	RPCViolationArgDecode
+	// RPCViolationArgRange means numeric argument is out of range
+	RPCViolationArgRange
+	// RPCViolationArgLen means length of argument is out of range
+	RPCViolationArgLen
+	// RPCViolationRate means client calls the method too frequently
+	RPCViolationRate
+	// RPCViolationArgDepth means slices and maps in argument are nested too deep
+	RPCViolationArgDepth
+)
+
+var rpcViolationKindNames = map[RPCViolationKind]string{
+	RPCViolationArgBytes:  "ArgBytes",
+	RPCViolationArgDecode: "ArgDecode",
+	RPCViolationArgRange:  "ArgRange",
+	RPCViolationArgLen:    "ArgLen",
+	RPCViolationRate:      "Rate",
+	RPCViolationArgDepth:  "ArgDepth",
+}
+
+func (kind RPCViolationKind) String() string {
+	if name, ok := rpcViolationKindNames[kind]; ok {
+		return name
+	}
+	return fmt.Sprintf("RPCViolationKind<%d>", int(kind))
+}
+
+// RPCViolation is the error of client RPC call which violates the declared constraints
+type RPCViolation struct {
+	EntityID common.EntityID
+	Method   string
+	ClientID common.ClientID
+	Kind     RPCViolationKind
+	ArgIndex int // index of the violating argument, -1 if not related to any argument
+	Detail   string
+}
+
+func (v *RPCViolation) Error() string {
+	return fmt.Sprintf("RPC violation %s: %s.%s from client %s, arg=%d: %s", v.Kind, v.EntityID, v.Method, v.ClientID, v.ArgIndex, v.Detail)
+}
+
+// RPCViolationStats is the statistics of RPC violations of a client
+type RPCViolationStats struct {
+	Total         int
+	ByKind        map[RPCViolationKind]int
+	LastViolation *RPCViolation
+	LastTime      time.Time
+}
+
+var rpcViolationStats = map[common.ClientID]*RPCViolationStats{}
+
+// GetRPCViolationStats returns the RPC violation statistics of all violating clients in this game
+//
+// Never modify the return value !
+func GetRPCViolationStats() map[common.ClientID]*RPCViolationStats {
+	return rpcViolationStats
+}
+
+func clearRPCViolationStats(clientid common.ClientID) {
+	delete(rpcViolationStats, clientid)
+}
+
+func recordRPCViolation(v *RPCViolation) {
+	stats := rpcViolationStats[v.ClientID]
+	if stats == nil {
+		stats = &RPCViolationStats{ByKind: map[RPCViolationKind]int{}}
+		rpcViolationStats[v.ClientID] = stats
+	}
+	stats.Total += 1
+	stats.ByKind[v.Kind] += 1
+	stats.LastViolation = v
+	stats.LastTime = time.Now()
+
+	if stats.Total%100 == 1 { // do not flood the log when client keeps violating
+		gwlog.Warnf("%s (total %d violations of this client)", v, stats.Total)
+	}
+}
+
+// DefineRPC declares constraints of RPC method which can be called by clients
+func (desc *EntityTypeDesc) DefineRPC(method string, limits RPCLimits) {
+	gwlog.Infof("        RPC %s = %+v", method, limits)
+	rpcDesc := desc.rpcDescs[method]
+	if rpcDesc == nil {
+		gwlog.Panicf("rpc %s: method not found in entity type %s", method, desc.entityType.Name())
+	}
+	if rpcDesc.Flags&(rfOwnClient|rfOtherClient) == 0 {
+		gwlog.Panicf("rpc %s: method can not be called by clients", method)
+	}
+	if len(limits.Args) > rpcDesc.NumArgs {
+		gwlog.Panicf("rpc %s: method receives %d arguments, but %d argument limits are defined", method, rpcDesc.NumArgs, len(limits.Args))
+	}
+	rpcDesc.Limits = &limits
+}
+
+type rpcRateCounter struct {
+	windowStart time.Time
+	count       int
+}
+
+type rpcRateKey struct {
+	clientid common.ClientID
+	method   string
+}
+
+var rpcRateClients = map[common.ClientID]EntitySet{} // entities with rate counters of each client
+
+// clearRPCRateCounters removes rate counters of the client, or all rate counters if clientid is nil
+func (e *Entity) clearRPCRateCounters(clientid common.ClientID) {
+	for key := range e.rpcRateCounters {
+		if !clientid.IsNil() && key.clientid != clientid {
+			continue
+		}
+
+		delete(e.rpcRateCounters, key)
+		if entities := rpcRateClients[key.clientid]; entities != nil {
+			entities.Del(e)
+			if len(entities) == 0 {
+				delete(rpcRateClients, key.clientid)
+			}
+		}
+	}
+}
+
+// clearRPCRateCountersOfClient removes rate counters of the disconnected client from all entities
+func clearRPCRateCountersOfClient(clientid common.ClientID) {
+	for e := range rpcRateClients[clientid] {
+		e.clearRPCRateCounters(clientid)
+	}
+	delete(rpcRateClients, clientid)
+}
+
+func (e *Entity) checkRPCRate(methodName string, clientid common.ClientID, limits *RPCLimits) *RPCViolation {
+	if limits.MaxCallsPerSecond <= 0 {
+		return nil
+	}
+
+	if e.rpcRateCounters == nil {
+		e.rpcRateCounters = map[rpcRateKey]*rpcRateCounter{}
+	}
+
+	key := rpcRateKey{clientid, methodName}
+	counter := e.rpcRateCounters[key]
+	now := time.Now()
+	if counter == nil {
+		counter = &rpcRateCounter{windowStart: now}
+		e.rpcRateCounters[key] = counter
+		if rpcRateClients[clientid] == nil {
+			rpcRateClients[clientid] = EntitySet{}
+		}
+		rpcRateClients[clientid].Add(e)
+	} else if now.Sub(counter.windowStart) >= time.Second {
+		counter.windowStart = now
+		counter.count = 0
+	}
+
+	counter.count += 1
+	if counter.count > limits.MaxCallsPerSecond {
+		return e.newRPCViolation(methodName, clientid, RPCViolationRate, -1, fmt.Sprintf("%d calls in 1 second, limit %d", counter.count, limits.MaxCallsPerSecond))
+	}
+	return nil
+}
+
+func (e *Entity) checkRPCArgBytes(methodName string, clientid common.ClientID, limits *RPCLimits, args [][]byte) *RPCViolation {
+	if limits.MaxArgBytes <= 0 {
+		return nil
+	}
+
+	total := 0
+	for _, arg := range args {
+		total += len(arg)
+	}
+	if total > limits.MaxArgBytes {
+		return e.newRPCViolation(methodName, clientid, RPCViolationArgBytes, -1, fmt.Sprintf("%d bytes, limit %d", total, limits.MaxArgBytes))
+	}
+	return nil
+}
+
+func (e *Entity) checkRPCArgDepth(methodName string, clientid common.ClientID, limits *RPCLimits, index int, val reflect.Value) *RPCViolation {
+	maxDepth := DefaultMaxRPCArgDepth
+	if limits != nil && limits.MaxArgDepth > 0 {
+		maxDepth = limits.MaxArgDepth
+	}
+
+	if depth := argDepth(val, maxDepth); depth > maxDepth {
+		return e.newRPCViolation(methodName, clientid, RPCViolationArgDepth, index, fmt.Sprintf("nesting depth exceeds limit %d", maxDepth))
+	}
+	return nil
+}
+
+// argDepth returns the nesting depth of slices and maps in val, or any depth larger than limit if it is too deep
+func argDepth(val reflect.Value, limit int) int {
+	if limit < 0 {
+		return 0 // too deep already, stop
+	}
+
+	switch val.Kind() {
+	case reflect.Interface, reflect.Ptr:
+		if val.IsNil() {
+			return 0
+		}
+		return argDepth(val.Elem(), limit)
+	case reflect.Slice, reflect.Array:
+		if val.Type().Elem().Kind() == reflect.Uint8 {
+			return 1 // bytes
+		}
+		depth := 1
+		for i := 0; i < val.Len() && depth <= limit; i++ {
+			if d := 1 + argDepth(val.Index(i), limit-1); d > depth {
+				depth = d
+			}
+		}
+		return depth
+	case reflect.Map:
+		depth := 1
+		for _, key := range val.MapKeys() {
+			if depth > limit {
+				break
+			}
+			if d := 1 + argDepth(val.MapIndex(key), limit-1); d > depth {
+				depth = d
+			}
+		}
+		return depth
+	case reflect.Struct:
+		depth := 0
+		for i := 0; i < val.NumField() && depth <= limit; i++ {
+			if d := argDepth(val.Field(i), limit); d > depth {
+				depth = d
+			}
+		}
+		return depth
+	default:
+		return 0
+	}
+}
+
+func (e *Entity) checkRPCArgValue(methodName string, clientid common.ClientID, limits *RPCLimits, index int, val reflect.Value) *RPCViolation {
+	if index >= len(limits.Args) {
+		return nil
+	}
+
+	argLimit := limits.Args[index]
+	if argLimit.CheckRange {
+		var fv float64
+		switch val.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			fv = float64(val.Int())
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			fv = float64(val.Uint())
+		case reflect.Float32, reflect.Float64:
+			fv = val.Float()
+		default:
+			return e.newRPCViolation(methodName, clientid, RPCViolationArgRange, index, fmt.Sprintf("range check on non-numeric type %s", val.Type()))
+		}
+
+		if fv < argLimit.Min || fv > argLimit.Max {
+			return e.newRPCViolation(methodName, clientid, RPCViolationArgRange, index, fmt.Sprintf("%v not in [%v, %v]", fv, argLimit.Min, argLimit.Max))
+		}
+	}
+
+	if argLimit.CheckLen {
+		switch val.Kind() {
+		case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		default:
+			return e.newRPCViolation(methodName, clientid, RPCViolationArgLen, index, fmt.Sprintf("length check on type %s", val.Type()))
+		}
+
+		if l := val.Len(); l < argLimit.MinLen || l > argLimit.MaxLen {
+			return e.newRPCViolation(methodName, clientid, RPCViolationArgLen, index, fmt.Sprintf("length %d not in [%d, %d]", l, argLimit.MinLen, argLimit.MaxLen))
+		}
+	}
+	return nil
+}
+
+func (e *Entity) newRPCViolation(methodName string, clientid common.ClientID, kind RPCViolationKind, argIndex int, detail string) *RPCViolation {
+	return &RPCViolation{
+		EntityID: e.ID,
+		Method:   methodName,
+		ClientID: clientid,
+		Kind:     kind,
+		ArgIndex: argIndex,
+		Detail:   detail,
+	}
+}
+
+func (e *Entity) onRPCViolation(v *RPCViolation) {
+	recordRPCViolation(v)
+	e.callCompositiveMethod("OnRPCViolation", v)
+}
@@ -0,0 +1,93 @@
+package entity
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRPCLimitsArgValue(t *testing.T) {
+	e := &Entity{ID: "TestEntity"}
+	limits := &RPCLimits{
+		Args: []ArgLimit{ArgRange(1, 10), ArgLen(1, 3)},
+	}
+
+	if v := e.checkRPCArgValue("Test", "client", limits, 0, reflect.ValueOf(5)); v != nil {
+		t.Errorf("5 should be in range: %s", v)
+	}
+	if v := e.checkRPCArgValue("Test", "client", limits, 0, reflect.ValueOf(11.0)); v == nil || v.Kind != RPCViolationArgRange || v.ArgIndex != 0 {
+		t.Errorf("11.0 should be out of range: %v", v)
+	}
+	if v := e.checkRPCArgValue("Test", "client", limits, 1, reflect.ValueOf("abcd")); v == nil || v.Kind != RPCViolationArgLen {
+		t.Errorf("abcd should be too long: %v", v)
+	}
+	if v := e.checkRPCArgValue("Test", "client", limits, 1, reflect.ValueOf([]int{1})); v != nil {
+		t.Errorf("[1] should be valid: %s", v)
+	}
+	if v := e.checkRPCArgValue("Test", "client", limits, 2, reflect.ValueOf("no limit")); v != nil {
+		t.Errorf("argument without limit should be valid: %s", v)
+	}
+}
+
+func TestRPCLimitsArgBytesAndRate(t *testing.T) {
+	e := &Entity{ID: "TestEntity"}
+	limits := &RPCLimits{MaxArgBytes: 4, MaxCallsPerSecond: 2}
+
+	if v := e.checkRPCArgBytes("Test", "client", limits, [][]byte{{1, 2}, {3, 4}}); v != nil {
+		t.Errorf("4 bytes should be valid: %s", v)
+	}
+	if v := e.checkRPCArgBytes("Test", "client", limits, [][]byte{{1, 2}, {3, 4, 5}}); v == nil || v.Kind != RPCViolationArgBytes {
+		t.Errorf("5 bytes should be too large: %v", v)
+	}
+
+	for i := 0; i < 2; i++ {
+		if v := e.checkRPCRate("Test", "client", limits); v != nil {
+			t.Errorf("call %d should be allowed: %s", i, v)
+		}
+	}
+	if v := e.checkRPCRate("Test", "client", limits); v == nil || v.Kind != RPCViolationRate {
+		t.Errorf("call 3 should be rejected: %v", v)
+	}
+	if v := e.checkRPCRate("Test", "client2", limits); v != nil {
+		t.Errorf("other client should be allowed: %s", v)
+	}
+}
+
+func TestRPCLimitsArgDepth(t *testing.T) {
+	e := &Entity{ID: "TestEntity"}
+	limits := &RPCLimits{MaxArgDepth: 2}
+
+	if v := e.checkRPCArgDepth("Test", "client", limits, 0, reflect.ValueOf(map[string][]int{"a": {1}})); v != nil {
+		t.Errorf("depth 2 should be valid: %s", v)
+	}
+	nested := []interface{}{[]interface{}{map[string]interface{}{"a": 1}}}
+	if v := e.checkRPCArgDepth("Test", "client", limits, 0, reflect.ValueOf(nested)); v == nil || v.Kind != RPCViolationArgDepth {
+		t.Errorf("depth 3 should be too deep: %v", v)
+	}
+	if v := e.checkRPCArgDepth("Test", "client", nil, 0, reflect.ValueOf(nested)); v != nil {
+		t.Errorf("depth 3 should be valid with default limit: %s", v)
+	}
+
+	var deep interface{} = 1
+	for i := 0; i < DefaultMaxRPCArgDepth+1; i++ {
+		deep = []interface{}{deep}
+	}
+	if v := e.checkRPCArgDepth("Test", "client", nil, 0, reflect.ValueOf(deep)); v == nil || v.Kind != RPCViolationArgDepth {
+		t.Errorf("argument deeper than default limit should be rejected: %v", v)
+	}
+}
+
+func TestRPCRateCountersCleared(t *testing.T) {
+	e := &Entity{ID: "TestEntity"}
+	limits := &RPCLimits{MaxCallsPerSecond: 2}
+	e.checkRPCRate("Test", "client", limits)
+	e.checkRPCRate("Test", "client2", limits)
+
+	clearRPCRateCountersOfClient("client")
+	if len(e.rpcRateCounters) != 1 || rpcRateClients["client"].Contains(e) {
+		t.Fatalf("rate counters of disconnected client should be removed")
+	}
+	e.clearRPCRateCounters("")
+	if len(e.rpcRateCounters) != 0 || rpcRateClients["client2"].Contains(e) {
+		t.Fatalf("all rate counters should be removed")
+	}
+}
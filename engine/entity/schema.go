@@ -0,0 +1,139 @@
+package entity
+
+import (
+	"encoding/json"
+	"io"
+	"reflect"
+	"sort"
+)
+
+// EntityTypeSchema describes the client-facing protocol of a registered entity type
+type EntityTypeSchema struct {
+	TypeName     string        `json:"typeName"`
+	IsPersistent bool          `json:"isPersistent"`
+	UseAOI       bool          `json:"useAOI"`
+	ServerRPCs   []*RPCSchema  `json:"serverRPCs"` // RPCs that can only be called by servers
+	ClientRPCs   []*RPCSchema  `json:"clientRPCs"` // RPCs that can be called by clients
+	Attrs        []*AttrSchema `json:"attrs"`      // attributes synced to clients
+}
+
+// RPCSchema describes a RPC method of entity type
+type RPCSchema struct {
+	Name         string   `json:"name"`
+	Args         []string `json:"args"`         // schema types of arguments
+	OwnClient    bool     `json:"ownClient"`    // can be called by own client
+	OtherClients bool     `json:"otherClients"` // can be called by other clients
+}
+
+// AttrSchema describes an attribute synced to clients
+type AttrSchema struct {
+	Name       string `json:"name"`
+	Type       string `json:"type"`       // schema type of attribute
+	AllClients bool   `json:"allClients"` // synced to all clients, otherwise synced to own client only
+}
+
+var baseEntityPtrTypes = []reflect.Type{reflect.TypeOf(&Entity{}), reflect.TypeOf(&Space{})}
+
+// GetEntityTypeSchemas returns schemas of all registered entity types, sorted by type name
+func GetEntityTypeSchemas() []*EntityTypeSchema {
+	var schemas []*EntityTypeSchema
+	for typeName, desc := range registeredEntityTypes {
+		schemas = append(schemas, desc.schema(typeName))
+	}
+	sort.Slice(schemas, func(i, j int) bool {
+		return schemas[i].TypeName < schemas[j].TypeName
+	})
+	return schemas
+}
+
+// DumpEntityTypeSchemas writes schemas of all registered entity types in JSON
+func DumpEntityTypeSchemas(w io.Writer) error {
+	data, err := json.MarshalIndent(GetEntityTypeSchemas(), "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+func (desc *EntityTypeDesc) schema(typeName string) *EntityTypeSchema {
+	schema := &EntityTypeSchema{
+		TypeName:     typeName,
+		IsPersistent: desc.isPersistent,
+		UseAOI:       desc.useAOI,
+	}
+
+	for rpcName, rpcDesc := range desc.rpcDescs {
+		if isBaseEntityMethod(rpcName) {
+			// methods of Entity and Space are not RPCs
+			continue
+		}
+
+		rpcSchema := &RPCSchema{
+			Name:         rpcName,
+			OwnClient:    rpcDesc.Flags&rfOwnClient != 0,
+			OtherClients: rpcDesc.Flags&rfOtherClient != 0,
+		}
+		for i := 0; i < rpcDesc.NumArgs; i++ {
+			rpcSchema.Args = append(rpcSchema.Args, schemaTypeOf(rpcDesc.MethodType.In(i+1)))
+		}
+
+		if rpcSchema.OwnClient || rpcSchema.OtherClients {
+			schema.ClientRPCs = append(schema.ClientRPCs, rpcSchema)
+		} else {
+			schema.ServerRPCs = append(schema.ServerRPCs, rpcSchema)
+		}
+	}
+
+	for attr := range desc.clientAttrs {
+		schema.Attrs = append(schema.Attrs, &AttrSchema{
+			Name:       attr,
+			Type:       desc.attrDescs[attr].typ.schemaType(),
+			AllClients: desc.allClientAttrs.Contains(attr),
+		})
+	}
+
+	sort.Slice(schema.ServerRPCs, func(i, j int) bool { return schema.ServerRPCs[i].Name < schema.ServerRPCs[j].Name })
+	sort.Slice(schema.ClientRPCs, func(i, j int) bool { return schema.ClientRPCs[i].Name < schema.ClientRPCs[j].Name })
+	sort.Slice(schema.Attrs, func(i, j int) bool { return schema.Attrs[i].Name < schema.Attrs[j].Name })
+	return schema
+}
+
+func isBaseEntityMethod(methodName string) bool {
+	for _, t := range baseEntityPtrTypes {
+		if _, ok := t.MethodByName(methodName); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// schemaTypeOf returns the schema type name of Go type
+//
+// Schema types are: int, float, bool, string, bytes, list<T>, map<K,V>, object and any
+func schemaTypeOf(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "int"
+	case reflect.Float32, reflect.Float64:
+		return "float"
+	case reflect.Bool:
+		return "bool"
+	case reflect.String:
+		return "string"
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return "bytes"
+		}
+		return "list<" + schemaTypeOf(t.Elem()) + ">"
+	case reflect.Map:
+		return "map<" + schemaTypeOf(t.Key()) + "," + schemaTypeOf(t.Elem()) + ">"
+	case reflect.Struct:
+		return "object"
+	case reflect.Ptr:
+		return schemaTypeOf(t.Elem())
+	default:
+		return "any"
+	}
+}
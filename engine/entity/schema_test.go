@@ -0,0 +1,43 @@
+package entity
+
+import "testing"
+
+type testSchemaEntity struct {
+	Entity
+}
+
+func (e *testSchemaEntity) DefineAttrs(desc *EntityTypeDesc) {
+	desc.DefineAttr("name", "AllClients")
+	desc.DefineAttr("exp", "Client")
+	desc.DefineAttr("secret")
+}
+
+func (e *testSchemaEntity) Hello_Client(name string, times int) {}
+
+func (e *testSchemaEntity) Broadcast_AllClient(items []string, props map[string]float64) {}
+
+func (e *testSchemaEntity) ServerOnly(data []byte) {}
+
+func TestEntityTypeSchema(t *testing.T) {
+	RegisterEntity("testSchemaEntity", &testSchemaEntity{}, false, false)
+	schema := registeredEntityTypes["testSchemaEntity"].schema("testSchemaEntity")
+
+	if len(schema.ClientRPCs) != 2 || len(schema.ServerRPCs) != 1 {
+		t.Fatalf("wrong rpcs: client=%v, server=%v", schema.ClientRPCs, schema.ServerRPCs)
+	}
+
+	broadcast, hello := schema.ClientRPCs[0], schema.ClientRPCs[1]
+	if hello.Name != "Hello" || !hello.OwnClient || hello.OtherClients || len(hello.Args) != 2 || hello.Args[0] != "string" || hello.Args[1] != "int" {
+		t.Errorf("wrong schema of Hello: %+v", hello)
+	}
+	if broadcast.Name != "Broadcast" || !broadcast.OtherClients || broadcast.Args[0] != "list<string>" || broadcast.Args[1] != "map<string,float>" {
+		t.Errorf("wrong schema of Broadcast: %+v", broadcast)
+	}
+	if serverOnly := schema.ServerRPCs[0]; serverOnly.Name != "ServerOnly" || serverOnly.Args[0] != "bytes" {
+		t.Errorf("wrong schema of ServerOnly: %+v", serverOnly)
+	}
+
+	if len(schema.Attrs) != 2 || schema.Attrs[0].Name != "exp" || schema.Attrs[0].AllClients || schema.Attrs[1].Name != "name" || !schema.Attrs[1].AllClients {
+		t.Errorf("wrong attrs: %+v", schema.Attrs)
+	}
+}
@@ -0,0 +1,510 @@
+package entity
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/lovelly/goworld/engine/common"
+	"github.com/lovelly/goworld/engine/consts"
+	"github.com/lovelly/goworld/engine/gwlog"
+	"github.com/xiaonanln/goTimer"
+	"github.com/xiaonanln/typeconv"
+)
+
+const (
+	_SPACE_SERVICE_TYPE = "__space_service__"
+	_SPACE_SERVICE_NAME = "__space_service__"
+
+	_SPACE_SERVICE_REGISTRY_ATTR_KEY = "_R" // managed spaces saved in freeze data: kind => {S: spaces, C: creating, P: pending}
+
+	_SPACE_CREATE_RETRY_MIN_DELAY = time.Second // delay before creating spaces again after the first failure, doubled on each failure
+	_SPACE_CREATE_RETRY_MAX_DELAY = time.Minute
+)
+
+// SpaceKindPolicy declares the lifecycle of spaces of a kind
+type SpaceKindPolicy struct {
+	Capacity    int           // max number of counted entities in each space chosen by EnterSpaceByKind, 0 for no limit
+	CountTypes  []string      // entity types counted for capacity and idleness, empty for all entities
+	IdleTimeout time.Duration // space is destroyed when it has no counted entities for the duration, 0 for never
+	PoolSize    int           // number of empty spaces kept ready for EnterSpaceByKind
+}
+
+var (
+	spaceKindPolicies = map[int]*SpaceKindPolicy{}
+)
+
+// SetSpaceKindPolicy sets the lifecycle policy of spaces of the kind
+//
+// Spaces of kinds with policies are managed by the space service, which is created on game 1 when all games are connected.
+// Should be called on all games before goworld.Run.
+func SetSpaceKindPolicy(kind int, policy SpaceKindPolicy) {
+	if kind == 0 || policy.Capacity < 0 || policy.IdleTimeout < 0 || policy.PoolSize < 0 {
+		gwlog.Panicf("SetSpaceKindPolicy: invalid policy %+v of kind %d", policy, kind)
+	}
+
+	if _, ok := registeredEntityTypes[_SPACE_SERVICE_TYPE]; !ok {
+		RegisterEntity(_SPACE_SERVICE_TYPE, &spaceService{}, false, false)
+	}
+	spaceKindPolicies[kind] = &policy
+}
+
+// GetSpaceKindPolicy returns the lifecycle policy of spaces of the kind, or nil if not set
+func GetSpaceKindPolicy(kind int) *SpaceKindPolicy {
+	return spaceKindPolicies[kind]
+}
+
+// counts returns if entities of the type are counted by the policy
+func (policy *SpaceKindPolicy) counts(typeName string) bool {
+	if len(policy.CountTypes) == 0 {
+		return true
+	}
+	for _, t := range policy.CountTypes {
+		if t == typeName {
+			return true
+		}
+	}
+	return false
+}
+
+// OnGameReady is called by engine when all games are connected, creates the space service on game 1 if needed
+func OnGameReady(gameid uint16) {
+	if gameid != 1 || len(spaceKindPolicies) == 0 {
+		return
+	}
+
+	for _, e := range entityManager.entities {
+		if e.TypeName == _SPACE_SERVICE_TYPE {
+			return // space service is restored
+		}
+	}
+	gwlog.Infof("Creating space service for space kinds with policies ...")
+	CreateEntityLocally(_SPACE_SERVICE_TYPE, nil, nil)
+}
+
+func isSpaceServiceReady() bool {
+	return len(entityManager.registeredServices[_SPACE_SERVICE_NAME]) > 0
+}
+
+// onSpaceServiceReady registers policy spaces which are created before the space service is declared
+func onSpaceServiceReady() {
+	for _, space := range spaceManager.spaces {
+		if space.policy != nil && !space.IsDestroyed() {
+			space.CallService(_SPACE_SERVICE_NAME, "RegisterSpace", space.Kind, space.ID, space.countPolicyEntities())
+		}
+	}
+}
+
+// EnterSpaceByKind enters a space of the kind chosen by the space service
+//
+// The space service chooses a space on any game which is not full according to the capacity of SpaceKindPolicy,
+// or creates a new space if all spaces of the kind are full. The kind should have a policy set by SetSpaceKindPolicy.
+func (e *Entity) EnterSpaceByKind(kind int, pos Vector3) {
+	if spaceKindPolicies[kind] == nil {
+		gwlog.Errorf("%s.EnterSpaceByKind(%d): space kind has no policy", e, kind)
+		return
+	}
+	if !isSpaceServiceReady() {
+		gwlog.Errorf("%s.EnterSpaceByKind(%d): space service is not ready", e, kind)
+		return
+	}
+	e.CallService(_SPACE_SERVICE_NAME, "RequestEnterSpaceByKind", e.ID, kind, pos)
+}
+
+// countPolicyEntities returns the number of entities counted by the policy of space
+func (space *Space) countPolicyEntities() int {
+	count := 0
+	for e := range space.entities {
+		if space.policy.counts(e.TypeName) {
+			count += 1
+		}
+	}
+	return count
+}
+
+// onPolicySpaceCreated registers the space to the space service and starts idle checking
+func (space *Space) onPolicySpaceCreated() {
+	space.policy = spaceKindPolicies[space.Kind]
+	if space.policy == nil {
+		return
+	}
+
+	if isSpaceServiceReady() {
+		space.CallService(_SPACE_SERVICE_NAME, "RegisterSpace", space.Kind, space.ID, space.countPolicyEntities())
+	}
+	space.checkIdle()
+}
+
+// onPolicyEntitiesChanged is called when an entity enters or leaves the space
+func (space *Space) onPolicyEntitiesChanged(entity *Entity) {
+	if space.policy == nil || !space.policy.counts(entity.TypeName) {
+		return
+	}
+
+	if isSpaceServiceReady() {
+		space.CallService(_SPACE_SERVICE_NAME, "UpdateSpaceLoad", space.Kind, space.ID, space.countPolicyEntities())
+	}
+	space.checkIdle()
+}
+
+func (space *Space) onPolicySpaceDestroy() {
+	if space.policy != nil && isSpaceServiceReady() {
+		space.CallService(_SPACE_SERVICE_NAME, "UnregisterSpace", space.Kind, space.ID)
+	}
+	space.policy = nil // entities leaving the destroying space are not reported
+}
+
+// checkIdle starts the idle timer if the space has no counted entities, or cancels the idle timer otherwise
+func (space *Space) checkIdle() {
+	if space.policy.IdleTimeout <= 0 {
+		return
+	}
+
+	idle := space.countPolicyEntities() == 0
+	if idle && space.idleTimer == nil {
+		space.idleTimer = space.addRawCallback(space.policy.IdleTimeout, func() {
+			space.idleTimer = nil
+			space.onIdleTimeout()
+		})
+	} else if !idle && space.idleTimer != nil {
+		space.cancelRawTimer(space.idleTimer)
+		space.idleTimer = nil
+	}
+}
+
+func (space *Space) onIdleTimeout() {
+	if consts.DEBUG_SPACES {
+		gwlog.Debugf("%s is idle for %s", space, space.policy.IdleTimeout)
+	}
+
+	if isSpaceServiceReady() {
+		// space service might have chosen the space for entering, so ask it before destroying
+		space.CallService(_SPACE_SERVICE_NAME, "RequestDestroySpace", space.Kind, space.ID)
+	} else {
+		space.ConfirmIdleDestroy(true)
+	}
+}
+
+// ConfirmIdleDestroy is called by the space service to confirm if the idle space can be destroyed
+func (space *Space) ConfirmIdleDestroy(ok bool) {
+	if space.IsDestroyed() {
+		return
+	}
+
+	if !ok {
+		space.checkIdle()
+	} else if count := space.countPolicyEntities(); count == 0 {
+		gwlog.Infof("%s is destroyed because it is idle for %s", space, space.policy.IdleTimeout)
+		space.Destroy()
+	} else if isSpaceServiceReady() {
+		// entities entered the space directly after it was removed by space service
+		space.CallService(_SPACE_SERVICE_NAME, "RegisterSpace", space.Kind, space.ID, count)
+	}
+}
+
+// managedSpace is a space managed by the space service
+type managedSpace struct {
+	id       common.EntityID
+	count    int         // number of counted entities reported by space
+	reserves []time.Time // times of enter requests which are sent to the space but not reported yet
+}
+
+// load returns the number of counted entities in space including entities which are entering
+func (ms *managedSpace) load(now time.Time) int {
+	expired := 0
+	for expired < len(ms.reserves) && now.Sub(ms.reserves[expired]) > consts.ENTER_SPACE_REQUEST_TIMEOUT {
+		expired += 1
+	}
+	ms.reserves = ms.reserves[expired:]
+	return ms.count + len(ms.reserves)
+}
+
+type enterSpaceByKindRequest struct {
+	entityID common.EntityID
+	pos      Vector3
+}
+
+// spaceKindInfo is the spaces of a kind managed by the space service
+type spaceKindInfo struct {
+	policy   *SpaceKindPolicy
+	spaces   map[common.EntityID]*managedSpace
+	creating EntityIDSet // spaces being created by the space service
+	pending  []enterSpaceByKindRequest
+
+	createFailures int          // number of successive failures of creating spaces
+	retryTimer     *timer.Timer // timer to create spaces again after failures
+}
+
+func newSpaceKindInfo(policy *SpaceKindPolicy) *spaceKindInfo {
+	return &spaceKindInfo{
+		policy:   policy,
+		spaces:   map[common.EntityID]*managedSpace{},
+		creating: EntityIDSet{},
+	}
+}
+
+// choose returns the fullest space which is not full, or nil if all spaces are full
+func (ki *spaceKindInfo) choose(now time.Time) *managedSpace {
+	var best *managedSpace
+	bestLoad := 0
+	for _, ms := range ki.spaces {
+		load := ms.load(now)
+		if ki.policy.Capacity > 0 && load >= ki.policy.Capacity {
+			continue
+		}
+		if best == nil || load > bestLoad {
+			best, bestLoad = ms, load
+		}
+	}
+	return best
+}
+
+// reserve assigns the enter request to the space
+func (ki *spaceKindInfo) reserve(ms *managedSpace, now time.Time) {
+	ms.reserves = append(ms.reserves, now)
+}
+
+// updateLoad updates the number of counted entities reported by space
+func (ki *spaceKindInfo) updateLoad(ms *managedSpace, count int) {
+	// entities which are reported entering the space are not reserved any more
+	if entered := count - ms.count; entered > 0 {
+		if entered > len(ms.reserves) {
+			entered = len(ms.reserves)
+		}
+		ms.reserves = ms.reserves[entered:]
+	}
+	ms.count = count
+}
+
+func (ki *spaceKindInfo) countEmptySpaces(now time.Time) int {
+	empty := 0
+	for _, ms := range ki.spaces {
+		if ms.load(now) == 0 {
+			empty += 1
+		}
+	}
+	return empty
+}
+
+// spacesToCreate returns the number of spaces which should be created for pending requests and the pool
+func (ki *spaceKindInfo) spacesToCreate(now time.Time) int {
+	needed := 0
+	if len(ki.pending) > 0 {
+		needed = 1
+		if ki.policy.Capacity > 0 {
+			needed = (len(ki.pending) + ki.policy.Capacity - 1) / ki.policy.Capacity
+		}
+	}
+	needed += ki.policy.PoolSize - ki.countEmptySpaces(now)
+	if n := needed - len(ki.creating); n > 0 {
+		return n
+	}
+	return 0
+}
+
+// canDestroy returns if the idle space can be destroyed without shrinking the pool
+func (ki *spaceKindInfo) canDestroy(ms *managedSpace, now time.Time) bool {
+	return ms.load(now) == 0 && ki.countEmptySpaces(now) > ki.policy.PoolSize
+}
+
+// spaceService is the service entity which manages spaces of kinds with SpaceKindPolicy across games
+type spaceService struct {
+	Entity
+	kinds map[int]*spaceKindInfo
+}
+
+func (s *spaceService) DefineAttrs(desc *EntityTypeDesc) {
+}
+
+func (s *spaceService) OnInit() {
+	s.kinds = map[int]*spaceKindInfo{}
+}
+
+func (s *spaceService) OnCreated() {
+	s.DeclareService(_SPACE_SERVICE_NAME)
+	for kind := range spaceKindPolicies {
+		s.maintainSpaces(kind)
+	}
+}
+
+// OnFreeze saves managed spaces to attributes, so that they are restored with the space service
+func (s *spaceService) OnFreeze() {
+	registry := map[string]interface{}{}
+	for kind, ki := range s.kinds {
+		spaces := map[string]interface{}{}
+		for id, ms := range ki.spaces {
+			spaces[string(id)] = ms.count
+		}
+		creating := []interface{}{}
+		for id := range ki.creating {
+			creating = append(creating, string(id))
+		}
+		pending := []interface{}{}
+		for _, req := range ki.pending {
+			pending = append(pending, map[string]interface{}{
+				"E": string(req.entityID), "X": float64(req.pos.X), "Y": float64(req.pos.Y), "Z": float64(req.pos.Z),
+			})
+		}
+		registry[strconv.Itoa(kind)] = map[string]interface{}{"S": spaces, "C": creating, "P": pending}
+	}
+
+	attr := NewMapAttr()
+	attr.AssignMap(registry)
+	s.Attrs.SetMapAttr(_SPACE_SERVICE_REGISTRY_ATTR_KEY, attr)
+}
+
+// OnRestored loads managed spaces saved by OnFreeze
+func (s *spaceService) OnRestored() {
+	if !s.Attrs.HasKey(_SPACE_SERVICE_REGISTRY_ATTR_KEY) {
+		return
+	}
+
+	registry := s.GetMapAttr(_SPACE_SERVICE_REGISTRY_ATTR_KEY)
+	s.Attrs.Del(_SPACE_SERVICE_REGISTRY_ATTR_KEY)
+	for _, key := range registry.Keys() {
+		kind, err := strconv.Atoi(key)
+		if err != nil {
+			continue
+		}
+
+		info := registry.GetMapAttr(key)
+		ki := s.getKindInfo(kind)
+		spaces := info.GetMapAttr("S")
+		for _, id := range spaces.Keys() {
+			ki.spaces[common.EntityID(id)] = &managedSpace{id: common.EntityID(id), count: int(typeconv.Int(spaces.Get(id)))}
+		}
+		creating := info.GetListAttr("C")
+		for i := 0; i < creating.Size(); i++ {
+			ki.creating.Add(common.EntityID(creating.GetStr(i)))
+		}
+		pending := info.GetListAttr("P")
+		for i := 0; i < pending.Size(); i++ {
+			req := pending.GetMapAttr(i)
+			ki.pending = append(ki.pending, enterSpaceByKindRequest{
+				entityID: common.EntityID(req.GetStr("E")),
+				pos: Vector3{
+					Coord(typeconv.Float(req.Get("X"))),
+					Coord(typeconv.Float(req.Get("Y"))),
+					Coord(typeconv.Float(req.Get("Z"))),
+				},
+			})
+		}
+	}
+
+	for kind := range spaceKindPolicies {
+		s.maintainSpaces(kind)
+	}
+}
+
+func (s *spaceService) getKindInfo(kind int) *spaceKindInfo {
+	ki := s.kinds[kind]
+	if ki == nil {
+		policy := spaceKindPolicies[kind]
+		if policy == nil {
+			gwlog.Errorf("%s: space kind %d has no policy on this game", s, kind)
+			policy = &SpaceKindPolicy{}
+		}
+		ki = newSpaceKindInfo(policy)
+		s.kinds[kind] = ki
+	}
+	return ki
+}
+
+// RegisterSpace is called by spaces when created
+func (s *spaceService) RegisterSpace(kind int, spaceID common.EntityID, count int) {
+	ki := s.getKindInfo(kind)
+	ki.creating.Del(spaceID) // spaces created by others are not in creating
+	ki.spaces[spaceID] = &managedSpace{id: spaceID, count: count}
+	s.maintainSpaces(kind)
+}
+
+// UpdateSpaceLoad is called by spaces when counted entities enter or leave
+func (s *spaceService) UpdateSpaceLoad(kind int, spaceID common.EntityID, count int) {
+	ki := s.getKindInfo(kind)
+	if ms := ki.spaces[spaceID]; ms != nil {
+		ki.updateLoad(ms, count)
+		s.maintainSpaces(kind)
+	}
+}
+
+// UnregisterSpace is called by spaces when destroyed
+func (s *spaceService) UnregisterSpace(kind int, spaceID common.EntityID) {
+	ki := s.getKindInfo(kind)
+	delete(ki.spaces, spaceID)
+	s.maintainSpaces(kind)
+}
+
+// RequestDestroySpace is called by idle spaces to request destroying
+func (s *spaceService) RequestDestroySpace(kind int, spaceID common.EntityID) {
+	ki := s.getKindInfo(kind)
+	ms := ki.spaces[spaceID]
+	ok := ms == nil || ki.canDestroy(ms, time.Now())
+	if ok {
+		delete(ki.spaces, spaceID)
+	}
+	s.Call(spaceID, "ConfirmIdleDestroy", ok)
+}
+
+// RequestEnterSpaceByKind is called by entities to enter a space of the kind
+func (s *spaceService) RequestEnterSpaceByKind(entityID common.EntityID, kind int, pos Vector3) {
+	ki := s.getKindInfo(kind)
+	ki.pending = append(ki.pending, enterSpaceByKindRequest{entityID, pos})
+	s.maintainSpaces(kind)
+}
+
+// maintainSpaces assigns pending requests to spaces and creates spaces for pending requests and the pool
+func (s *spaceService) maintainSpaces(kind int) {
+	ki := s.getKindInfo(kind)
+	now := time.Now()
+	for len(ki.pending) > 0 {
+		ms := ki.choose(now)
+		if ms == nil {
+			break
+		}
+		req := ki.pending[0]
+		ki.pending = ki.pending[1:]
+		ki.reserve(ms, now)
+		s.Call(req.entityID, "EnterSpace", ms.id, req.pos)
+	}
+
+	if ki.retryTimer != nil {
+		return // wait for retrying after failures
+	}
+	for i := ki.spacesToCreate(now); i > 0; i-- {
+		spaceID := common.GenEntityID()
+		ki.creating.Add(spaceID)
+		if consts.DEBUG_SPACES {
+			gwlog.Debugf("%s: creating space %s of kind %d", s, spaceID, kind)
+		}
+		requestID := addPendingCreateReply(spaceID, func(spaceID common.EntityID, gameid uint16, err error) {
+			if err == nil {
+				ki.createFailures = 0 // space is removed from creating when registered
+				return
+			}
+
+			gwlog.Errorf("%s: create space of kind %d failed: %s", s, kind, err)
+			ki.creating.Del(spaceID)
+			ki.createFailures += 1
+			if ki.retryTimer == nil {
+				ki.retryTimer = s.addRawCallback(spaceCreateRetryDelay(ki.createFailures), func() {
+					ki.retryTimer = nil
+					s.maintainSpaces(kind)
+				})
+			}
+		})
+		createEntityAnywhere(_SPACE_ENTITY_TYPE, spaceID, map[string]interface{}{
+			_SPACE_KIND_ATTR_KEY: kind,
+		}, requestID)
+	}
+}
+
+// spaceCreateRetryDelay returns the delay before creating spaces again after successive failures
+func spaceCreateRetryDelay(failures int) time.Duration {
+	delay := _SPACE_CREATE_RETRY_MIN_DELAY
+	for i := 1; i < failures && delay < _SPACE_CREATE_RETRY_MAX_DELAY; i++ {
+		delay *= 2
+	}
+	if delay > _SPACE_CREATE_RETRY_MAX_DELAY {
+		delay = _SPACE_CREATE_RETRY_MAX_DELAY
+	}
+	return delay
+}
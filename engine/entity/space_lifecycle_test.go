@@ -0,0 +1,165 @@
+package entity
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lovelly/goworld/engine/common"
+	"github.com/lovelly/goworld/engine/consts"
+	"github.com/xiaonanln/goTimer"
+)
+
+func TestSpaceKindInfo(t *testing.T) {
+	ki := newSpaceKindInfo(&SpaceKindPolicy{Capacity: 2, PoolSize: 1})
+	now := time.Now()
+	if ki.choose(now) != nil || ki.spacesToCreate(now) != 1 {
+		t.Fatalf("no space should be chosen, and 1 space should be created for pool")
+	}
+
+	ki.pending = make([]enterSpaceByKindRequest, 3)
+	if n := ki.spacesToCreate(now); n != 3 {
+		t.Fatalf("3 spaces should be created for 3 requests and pool, but got %d", n)
+	}
+	for i := 0; i < 3; i++ {
+		ki.creating.Add(common.GenEntityID())
+	}
+	if n := ki.spacesToCreate(now); n != 0 {
+		t.Fatalf("no more space should be created, but got %d", n)
+	}
+	ki.pending = nil
+
+	s1 := &managedSpace{id: common.GenEntityID(), count: 1}
+	s2 := &managedSpace{id: common.GenEntityID()}
+	ki.spaces[s1.id], ki.spaces[s2.id] = s1, s2
+
+	// fuller space is chosen, and reserved slots count
+	if ms := ki.choose(now); ms != s1 {
+		t.Fatalf("fuller space should be chosen")
+	}
+	ki.reserve(s1, now)
+	if ms := ki.choose(now); ms != s2 {
+		t.Fatalf("full space should not be chosen")
+	}
+	ki.updateLoad(s1, 2)
+	if s1.load(now) != 2 || len(s1.reserves) != 0 {
+		t.Fatalf("reserve should be cleared when entity is reported: load=%d, reserves=%d", s1.load(now), len(s1.reserves))
+	}
+	ki.reserve(s2, now)
+	if s2.load(now.Add(consts.ENTER_SPACE_REQUEST_TIMEOUT+time.Second)) != 0 {
+		t.Fatalf("reserve should expire")
+	}
+
+	// the only empty space is kept for pool
+	if ki.canDestroy(s2, now) {
+		t.Fatalf("empty space in pool should not be destroyed")
+	}
+	s3 := &managedSpace{id: common.GenEntityID()}
+	ki.spaces[s3.id] = s3
+	if !ki.canDestroy(s3, now) || ki.canDestroy(s1, now) {
+		t.Fatalf("only empty space beyond pool can be destroyed")
+	}
+}
+
+func TestSpaceServiceRegistry(t *testing.T) {
+	s := newTestEntity(_SPACE_SERVICE_TYPE, &spaceService{}, false, false).I.(*spaceService)
+	ki := s.getKindInfo(1)
+	spaceID, creatingID, otherID := common.GenEntityID(), common.GenEntityID(), common.GenEntityID()
+	ki.creating.Add(spaceID)
+	ki.creating.Add(creatingID)
+	s.RegisterSpace(1, spaceID, 2)
+	s.RegisterSpace(1, otherID, 0)
+	if len(ki.creating) != 1 || !ki.creating.Contains(creatingID) {
+		t.Fatalf("only the registered space should be removed from creating: %v", ki.creating)
+	}
+	ki.pending = append(ki.pending, enterSpaceByKindRequest{entityID: common.GenEntityID(), pos: Vector3{X: 1, Y: 2, Z: 3}})
+
+	s.OnFreeze()
+	data := s.Attrs.ToMap()
+	restored := newTestEntity(_SPACE_SERVICE_TYPE, &spaceService{}, false, false).I.(*spaceService)
+	restored.Attrs.AssignMap(data)
+	restored.OnRestored()
+
+	rki := restored.kinds[1]
+	if rki == nil || len(rki.spaces) != 2 || rki.spaces[spaceID].count != 2 || rki.spaces[otherID] == nil {
+		t.Fatalf("spaces should be restored: %+v", rki)
+	}
+	if len(rki.creating) != 1 || !rki.creating.Contains(creatingID) {
+		t.Fatalf("creating spaces should be restored: %v", rki.creating)
+	}
+	if len(rki.pending) != 1 || rki.pending[0] != ki.pending[0] {
+		t.Fatalf("pending requests should be restored: %v", rki.pending)
+	}
+	if restored.Attrs.HasKey(_SPACE_SERVICE_REGISTRY_ATTR_KEY) {
+		t.Fatalf("registry should be removed from attrs after restore")
+	}
+}
+
+func TestSpaceIdleTimer(t *testing.T) {
+	space := newTestSpace(1)
+	space.policy = &SpaceKindPolicy{CountTypes: []string{"testAOIEntity"}, IdleTimeout: time.Minute}
+	space.checkIdle()
+	if space.idleTimer == nil {
+		t.Fatalf("empty space should be idle")
+	}
+
+	boss := newTestAOIEntity("testAOIBossEntity")
+	space.enter(boss, Vector3{}, false)
+	if space.idleTimer == nil || space.countPolicyEntities() != 0 {
+		t.Fatalf("entities not counted should not affect idleness")
+	}
+
+	e := newTestAOIEntity("testAOIEntity")
+	space.enter(e, Vector3{}, false)
+	if space.idleTimer != nil || space.countPolicyEntities() != 1 {
+		t.Fatalf("space with counted entities should not be idle")
+	}
+	if len(space.rawTimers) != 0 {
+		t.Fatalf("idle timer should be cancelled")
+	}
+
+	space.leave(e)
+	if space.idleTimer == nil {
+		t.Fatalf("space should be idle after counted entities left")
+	}
+	space.cancelRawTimer(space.idleTimer)
+}
+
+func TestSpaceServiceReady(t *testing.T) {
+	const kind = 300
+	spaceKindPolicies[kind] = &SpaceKindPolicy{}
+	defer delete(spaceKindPolicies, kind)
+
+	space := newTestSpace(kind)
+	space.onPolicySpaceCreated() // space service is not ready yet
+	spaceManager.putSpace(space)
+	defer spaceManager.delSpace(space.ID)
+
+	s := newTestEntity(_SPACE_SERVICE_TYPE, &spaceService{}, false, false).I.(*spaceService)
+	entityManager.put(&s.Entity)
+	defer entityManager.del(s.ID)
+	entityManager.onDeclareService(_SPACE_SERVICE_NAME, s.ID)
+	defer entityManager.onUndeclareService(_SPACE_SERVICE_NAME, s.ID)
+
+	waitPost(t, func() bool { return s.kinds[kind] != nil && s.kinds[kind].spaces[space.ID] != nil })
+}
+
+func TestSpaceCreateRetry(t *testing.T) {
+	for failures, delay := range map[int]time.Duration{1: time.Second, 2: time.Second * 2, 4: time.Second * 8, 100: time.Minute} {
+		if d := spaceCreateRetryDelay(failures); d != delay {
+			t.Errorf("delay after %d failures should be %s, but got %s", failures, delay, d)
+		}
+	}
+
+	const kind = 300
+	spaceKindPolicies[kind] = &SpaceKindPolicy{PoolSize: 1}
+	defer delete(spaceKindPolicies, kind)
+	s := newTestEntity(_SPACE_SERVICE_TYPE, &spaceService{}, false, false).I.(*spaceService)
+	ki := s.getKindInfo(kind)
+	ki.retryTimer = timer.AddCallback(time.Hour, func() {})
+	defer ki.retryTimer.Cancel()
+
+	s.maintainSpaces(kind)
+	if len(ki.creating) != 0 {
+		t.Fatalf("spaces should not be created before retrying: %v", ki.creating)
+	}
+}
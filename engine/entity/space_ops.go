@@ -11,7 +11,7 @@ func CreateSpaceLocally(kind int) common.EntityID {
 
 // CreateSpaceAnywhere creates a space in any game server
 func CreateSpaceAnywhere(kind int) {
-	createEntityAnywhere(_SPACE_ENTITY_TYPE, map[string]interface{}{
+	createEntityAnywhere(_SPACE_ENTITY_TYPE, common.GenEntityID(), map[string]interface{}{
 		_SPACE_KIND_ATTR_KEY: kind,
-	})
+	}, 0)
 }
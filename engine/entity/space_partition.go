@@ -0,0 +1,669 @@
+package entity
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/lovelly/goworld/engine/common"
+	"github.com/lovelly/goworld/engine/gwlog"
+	"github.com/lovelly/goworld/engine/uuid"
+	"github.com/xiaonanln/typeconv"
+)
+
+const (
+	_SPACE_CELL_ATTR_KEY = "_C" // index of the cell in partitioned space
+
+	_PARTITION_TICK_INTERVAL = time.Millisecond * 100 // interval of ghost syncing and handover checking
+	_GHOST_EXPIRE_TIME       = time.Second * 5        // ghosts not synced for the duration are removed
+	_GHOST_HANDOVER_GRACE    = time.Second            // ghosts left by handover are kept until the new cell syncs them
+
+	_CLIENT_KEEPS_MIGRATE_KEY = "__clientKeeps__" // entities kept on client during handover, carried in migrate data
+)
+
+// SpacePartition splits a large world of a space kind into a grid of cells on the X-Z plane
+//
+// Each cell is a space of the kind which can be on any game. Entities near cell borders are ghosted in neighbor cells,
+// and entities crossing cell borders are handed over to the neighbor cell by migration.
+type SpacePartition struct {
+	CellSize       Coord // side length of square cells, cell (0, 0) starts at the origin
+	CellsX, CellsZ int   // number of cells on X and Z axes
+	GhostDistance  Coord // entities within the distance to a neighbor cell are ghosted in the neighbor cell
+	HandoverMargin Coord // entities are handed over when they are beyond the cell border by the margin
+
+	kind        int
+	cellIndices map[common.EntityID]int
+}
+
+// spaceCell is the cell of a partitioned space
+type spaceCell struct {
+	partition *SpacePartition
+	index     int
+	cx, cz    int
+	ghosts    map[common.EntityID]*Entity
+	ghostedTo map[int]EntityIDSet // entities last ghosted to each neighbor cell
+}
+
+// ghostInfo is the information of ghost entity, which is the read-only copy of an entity in the neighbor cell
+type ghostInfo struct {
+	source    int // index of the cell of the real entity
+	lastSync  time.Time
+	keepUntil time.Time
+	partial   bool // created without full data, waiting for the source cell to resend attributes
+}
+
+// ghostSyncData is the data of entities ghosted to neighbor cells
+//
+// Changes of client attributes, calls to all clients and movements are forwarded to ghosts after first ghosted.
+type ghostSyncData struct {
+	ID        common.EntityID
+	Type      string
+	Pos       Vector3
+	Yaw       Yaw
+	Full      bool                   // Attrs, MoveTo and MoveSpeed are only sent when entity is first ghosted to the cell
+	Attrs     map[string]interface{} // all client attributes
+	MoveTo    Vector3                // current movement target
+	MoveSpeed Coord                  // current movement speed, 0 if entity is not moving
+}
+
+var (
+	spacePartitions       = map[int]*SpacePartition{}
+	cellSpaces            = map[common.EntityID]*Space{}
+	ghostEntities         = EntitySet{}
+	clientKeepingEntities = EntitySet{} // entities with clientKeeps to be flushed
+	keepingOnClient       bool          // entities hidden are kept on clients during handover
+	lastPartitionTickTime time.Time
+)
+
+// SetSpaceKindPartition makes spaces of the kind cells of a partitioned world
+//
+// Cells are created by CreatePartitionedSpaceAnywhere, and entities enter the world by Entity.EnterPartitionedSpace.
+// Clients see all cells as one continuous space. Should be called on all games before goworld.Run.
+func SetSpaceKindPartition(kind int, partition SpacePartition) {
+	if kind == 0 || partition.CellSize <= 0 || partition.CellsX <= 0 || partition.CellsZ <= 0 ||
+		partition.GhostDistance < 0 || partition.HandoverMargin < 0 || partition.GhostDistance > partition.CellSize {
+		gwlog.Panicf("SetSpaceKindPartition: invalid partition %+v of kind %d", partition, kind)
+	}
+
+	partition.kind = kind
+	partition.cellIndices = map[common.EntityID]int{}
+	for cz := 0; cz < partition.CellsZ; cz++ {
+		for cx := 0; cx < partition.CellsX; cx++ {
+			partition.cellIndices[PartitionCellID(kind, cx, cz)] = cz*partition.CellsX + cx
+		}
+	}
+	spacePartitions[kind] = &partition
+}
+
+// PartitionCellID returns the stable space ID of cell (cx, cz) of the partitioned space kind
+func PartitionCellID(kind int, cx, cz int) common.EntityID {
+	return common.EntityID(uuid.GenFixedUUID([]byte(fmt.Sprintf("%s:cell:%d:%d:%d", _SPACE_ENTITY_TYPE, kind, cx, cz))))
+}
+
+// PartitionWorldID returns the space ID of the partitioned space kind which is shown to clients
+func PartitionWorldID(kind int) common.EntityID {
+	return common.EntityID(uuid.GenFixedUUID([]byte(fmt.Sprintf("%s:world:%d", _SPACE_ENTITY_TYPE, kind))))
+}
+
+// CreatePartitionedSpaceAnywhere creates all cells of the partitioned space kind on any games
+//
+// Should be called only once, e.g. on game 1 when the game is ready.
+func CreatePartitionedSpaceAnywhere(kind int) {
+	partition := spacePartitions[kind]
+	if partition == nil {
+		gwlog.Panicf("CreatePartitionedSpaceAnywhere: space kind %d is not partitioned", kind)
+	}
+
+	for cellID, index := range partition.cellIndices {
+		createEntityAnywhere(_SPACE_ENTITY_TYPE, cellID, map[string]interface{}{
+			_SPACE_KIND_ATTR_KEY: kind,
+			_SPACE_CELL_ATTR_KEY: index,
+		}, 0)
+	}
+}
+
+// EnterPartitionedSpace enters the cell containing the position of the partitioned space kind
+func (e *Entity) EnterPartitionedSpace(kind int, pos Vector3) {
+	partition := spacePartitions[kind]
+	if partition == nil {
+		gwlog.Errorf("%s.EnterPartitionedSpace: space kind %d is not partitioned", e, kind)
+		return
+	}
+
+	cx, cz := partition.cellOf(pos)
+	e.EnterSpace(PartitionCellID(kind, cx, cz), pos)
+}
+
+// IsPartitionCell returns if the space is a cell of partitioned space
+func (space *Space) IsPartitionCell() bool {
+	return space.cell != nil
+}
+
+// IsGhost returns if the entity is a read-only ghost of an entity in the neighbor cell of partitioned space
+func (e *Entity) IsGhost() bool {
+	return e.ghost != nil
+}
+
+// cellOf returns the cell containing the position, positions out of the world belong to the nearest cell
+func (partition *SpacePartition) cellOf(pos Vector3) (cx, cz int) {
+	clamp := func(v Coord, n int) int {
+		c := int(v / partition.CellSize)
+		if v < 0 || c < 0 {
+			return 0
+		} else if c >= n {
+			return n - 1
+		}
+		return c
+	}
+	return clamp(pos.X, partition.CellsX), clamp(pos.Z, partition.CellsZ)
+}
+
+// cellBounds returns the min and max corners of cell on X-Z plane
+func (partition *SpacePartition) cellBounds(cx, cz int) (min, max Vector3) {
+	min = Vector3{Coord(cx) * partition.CellSize, 0, Coord(cz) * partition.CellSize}
+	max = Vector3{min.X + partition.CellSize, 0, min.Z + partition.CellSize}
+	return
+}
+
+// distanceToCell returns the distance from position to cell on X-Z plane, 0 if the position is in the cell
+func (partition *SpacePartition) distanceToCell(pos Vector3, cx, cz int) Coord {
+	min, max := partition.cellBounds(cx, cz)
+	dist := func(v, min, max Coord) Coord {
+		if v < min {
+			return min - v
+		} else if v > max {
+			return v - max
+		}
+		return 0
+	}
+	dx, dz := dist(pos.X, min.X, max.X), dist(pos.Z, min.Z, max.Z)
+	if dx > dz {
+		return dx
+	}
+	return dz
+}
+
+func (space *Space) onCellSpaceCreated() {
+	partition := spacePartitions[space.Kind]
+	if partition == nil || !space.Attrs.HasKey(_SPACE_CELL_ATTR_KEY) {
+		return
+	}
+
+	index := int(space.GetInt(_SPACE_CELL_ATTR_KEY))
+	space.cell = &spaceCell{
+		partition: partition,
+		index:     index,
+		cx:        index % partition.CellsX,
+		cz:        index / partition.CellsX,
+		ghosts:    map[common.EntityID]*Entity{},
+		ghostedTo: map[int]EntityIDSet{},
+	}
+	cellSpaces[space.ID] = space
+}
+
+func (space *Space) onCellSpaceDestroy() {
+	if space.cell == nil {
+		return
+	}
+
+	for _, ghost := range space.cell.ghosts {
+		space.leaveGhost(ghost)
+	}
+	delete(cellSpaces, space.ID)
+}
+
+// TickPartitionedSpaces syncs ghosts to neighbor cells and hands over entities crossing cell borders, called by engine in each tick
+func TickPartitionedSpaces() {
+	if len(cellSpaces) == 0 {
+		return
+	}
+
+	now := time.Now()
+	if now.Sub(lastPartitionTickTime) < _PARTITION_TICK_INTERVAL {
+		return
+	}
+	lastPartitionTickTime = now
+
+	for _, space := range cellSpaces {
+		space.tickCell(now)
+	}
+}
+
+func (space *Space) tickCell(now time.Time) {
+	cell := space.cell
+	partition := cell.partition
+	snapshots := map[int][]ghostSyncData{}
+
+	for e := range space.entities {
+		pos := e.aoi.pos
+		if !e.isEnteringSpace() {
+			if cx, cz := partition.cellOf(pos); (cx != cell.cx || cz != cell.cz) &&
+				partition.distanceToCell(pos, cell.cx, cell.cz) > partition.HandoverMargin {
+				cellID := PartitionCellID(partition.kind, cx, cz)
+				e.EnterSpace(cellID, pos)
+				if e.isEnteringSpace() {
+					e.handoverTo = cellID
+				}
+			}
+		}
+
+		for dz := -1; dz <= 1; dz++ {
+			for dx := -1; dx <= 1; dx++ {
+				cx, cz := cell.cx+dx, cell.cz+dz
+				if (dx == 0 && dz == 0) || cx < 0 || cx >= partition.CellsX || cz < 0 || cz >= partition.CellsZ {
+					continue
+				}
+				if partition.distanceToCell(pos, cx, cz) <= partition.GhostDistance {
+					index := cz*partition.CellsX + cx
+					snapshots[index] = append(snapshots[index], ghostSyncData{ID: e.ID, Type: e.TypeName, Pos: pos, Yaw: e.yaw})
+				}
+			}
+		}
+	}
+
+	for index, ghostedBefore := range cell.ghostedTo {
+		if _, ok := snapshots[index]; !ok && len(ghostedBefore) > 0 {
+			snapshots[index] = []ghostSyncData{} // remove all ghosts in the neighbor cell
+		}
+	}
+
+	for index, snapshot := range snapshots {
+		ghostedBefore := cell.ghostedTo[index]
+		ghosted := EntityIDSet{}
+		for i := range snapshot {
+			data := &snapshot[i]
+			ghosted.Add(data.ID)
+			if !ghostedBefore.Contains(data.ID) {
+				if e := entityManager.get(data.ID); e != nil {
+					data.Full = true
+					data.Attrs = e.getAllClientData()
+					if e.movement != nil {
+						data.MoveTo, data.MoveSpeed = e.movement.path[0], e.movement.speed
+					}
+				}
+			}
+		}
+		cell.ghostedTo[index] = ghosted
+		space.Call(PartitionCellID(partition.kind, index%partition.CellsX, index/partition.CellsX), "SyncGhosts", cell.index, snapshot)
+	}
+
+	for _, ghost := range cell.ghosts {
+		if now.Sub(ghost.ghost.lastSync) > _GHOST_EXPIRE_TIME && now.After(ghost.ghost.keepUntil) {
+			space.leaveGhost(ghost)
+		}
+	}
+}
+
+// SyncGhosts is called by the neighbor cell of partitioned space to sync ghosts of entities near the border
+func (space *Space) SyncGhosts(source int, snapshot []ghostSyncData) {
+	cell := space.cell
+	if cell == nil {
+		gwlog.Errorf("%s.SyncGhosts: space is not a cell of partitioned space", space)
+		return
+	}
+
+	now := time.Now()
+	synced := EntityIDSet{}
+	var resync []common.EntityID
+	for _, data := range snapshot {
+		synced.Add(data.ID)
+		if e := entityManager.get(data.ID); e != nil && e.Space == space {
+			continue // entity has been handed over to this cell
+		}
+
+		ghost := cell.ghosts[data.ID]
+		if ghost != nil && ghost.ghost.partial && data.Full {
+			space.leaveGhost(ghost) // recreate the ghost with full data
+			ghost = nil
+		}
+		if ghost == nil {
+			if ghost = newGhostEntity(data.Type, data.ID, data.Attrs); ghost == nil {
+				continue
+			}
+			ghost.yaw = data.Yaw
+			ghost.ghost.source = source
+			ghost.ghost.lastSync = now
+			ghost.setGhostMovement(data.MoveTo, data.MoveSpeed)
+			space.enterGhost(ghost, data.Pos)
+			if !data.Full {
+				// ghost was dropped by this cell while the source cell still takes it as ghosted, e.g. expired or cell restarted
+				ghost.ghost.partial = true
+				resync = append(resync, data.ID)
+			}
+			continue
+		}
+
+		ghost.ghost.source = source
+		ghost.ghost.lastSync = now
+		if ghost.aoi.pos != data.Pos || ghost.yaw != data.Yaw {
+			ghost.yaw = data.Yaw
+			space.move(ghost, data.Pos)
+			ghost.syncInfoFlag |= sifSyncNeighborClients
+		}
+	}
+
+	for id, ghost := range cell.ghosts {
+		if ghost.ghost.source == source && !synced.Contains(id) && now.After(ghost.ghost.keepUntil) {
+			space.leaveGhost(ghost)
+		}
+	}
+
+	if len(resync) > 0 {
+		partition := cell.partition
+		space.Call(PartitionCellID(partition.kind, source%partition.CellsX, source/partition.CellsX), "ResyncGhosts", cell.index, resync)
+	}
+}
+
+// ResyncGhosts is called by the neighbor cell of partitioned space to request full data of ghosts in the next sync
+func (space *Space) ResyncGhosts(index int, entityIDs []common.EntityID) {
+	cell := space.cell
+	if cell == nil {
+		gwlog.Errorf("%s.ResyncGhosts: space is not a cell of partitioned space", space)
+		return
+	}
+
+	ghosted := cell.ghostedTo[index]
+	for _, id := range entityIDs {
+		ghosted.Del(id)
+	}
+}
+
+// newGhostEntity creates a ghost entity which is not managed by entity manager
+//
+// OnInit is not called for ghosts, so that game logic does not run on ghosts.
+func newGhostEntity(typeName string, entityID common.EntityID, attrs map[string]interface{}) *Entity {
+	desc := registeredEntityTypes[typeName]
+	if desc == nil || !desc.useAOI {
+		gwlog.Errorf("newGhostEntity: invalid entity type %s of %s", typeName, entityID)
+		return nil
+	}
+
+	instance := reflect.New(desc.entityType)
+	e := reflect.Indirect(instance).FieldByName("Entity").Addr().Interface().(*Entity)
+	e.initFields(typeName, entityID, instance)
+	e.Space = nilSpace
+	e.ghost = &ghostInfo{}
+	if attrs != nil {
+		e.LoadMigrateData(attrs)
+	}
+	return e
+}
+
+// enterGhost enters the ghost into the cell, ghosts are visible to entities in the cell but not counted as entities of space
+func (space *Space) enterGhost(ghost *Entity, pos Vector3) {
+	ghost.Space = space
+	ghost.aoi.dist = space.getAOIDistanceOf(ghost)
+	ghost.aoi.height = space.aoiHeight
+	if ghost.aoi.dist > space.maxAOIDistance {
+		space.maxAOIDistance = ghost.aoi.dist
+	}
+	space.aoiCalc.Enter(&ghost.aoi, pos)
+	space.adjust(ghost)
+
+	space.cell.ghosts[ghost.ID] = ghost
+	ghostEntities.Add(ghost)
+}
+
+func (space *Space) leaveGhost(ghost *Entity) {
+	ghost.flushAttrDeltas()
+	for neighbor := range ghost.aoi.neighbors {
+		ghost.uninterest(neighbor)
+	}
+	for other := range ghost.aoi.interestedBy {
+		other.uninterest(ghost)
+	}
+	space.aoiCalc.Leave(&ghost.aoi)
+	ghost.Space = nilSpace
+
+	delete(space.cell.ghosts, ghost.ID)
+	ghostEntities.Del(ghost)
+	ghost.movement = nil
+	ghost.clearRawTimers()
+	visibilityCappedEntities.Del(ghost)
+	movingEntities.Del(ghost)
+}
+
+// forwardToGhosts calls the method of neighbor cells holding ghosts of the entity with entity ID and args
+func (e *Entity) forwardToGhosts(method string, args ...interface{}) {
+	space := e.Space
+	if space == nil || space.cell == nil || e.IsGhost() {
+		return
+	}
+
+	partition := space.cell.partition
+	for index, ghosted := range space.cell.ghostedTo {
+		if ghosted.Contains(e.ID) {
+			space.Call(PartitionCellID(partition.kind, index%partition.CellsX, index/partition.CellsX), method, append([]interface{}{e.ID}, args...)...)
+		}
+	}
+}
+
+// forwardAttrOpToGhosts forwards the change of all-clients attribute to ghosts, op is the same as ops of attribute deltas
+func (e *Entity) forwardAttrOpToGhosts(op ...interface{}) {
+	e.forwardToGhosts("SyncGhostAttrs", []interface{}{op})
+}
+
+// SyncGhostAttrs is called by the neighbor cell to apply changes of all-clients attributes to the ghost
+//
+// Each op is [path, key] for deleting or [path, key, val] for setting, where path is from leaf to root.
+func (space *Space) SyncGhostAttrs(entityID common.EntityID, ops []interface{}) {
+	ghost := space.getGhost(entityID)
+	if ghost == nil {
+		return
+	}
+
+	for _, op := range ops {
+		if op, ok := op.([]interface{}); ok && (len(op) == 2 || len(op) == 3) {
+			ghost.applyGhostAttrOp(op)
+		}
+	}
+}
+
+// CallGhostClients is called by the neighbor cell to call the method on clients of entities seeing the ghost
+func (space *Space) CallGhostClients(entityID common.EntityID, method string, args []interface{}) {
+	ghost := space.getGhost(entityID)
+	if ghost == nil {
+		return
+	}
+
+	for neighbor := range ghost.aoi.visibleBy {
+		neighbor.client.call(ghost.ID, method, args)
+	}
+}
+
+// MoveGhostOnClients is called by the neighbor cell to send the movement to clients of entities seeing the ghost
+func (space *Space) MoveGhostOnClients(entityID common.EntityID, target Vector3, speed Coord) {
+	ghost := space.getGhost(entityID)
+	if ghost == nil {
+		return
+	}
+
+	ghost.setGhostMovement(target, speed)
+	for neighbor := range ghost.aoi.visibleBy {
+		neighbor.client.sendMoveEntity(ghost, target, speed)
+	}
+}
+
+func (space *Space) getGhost(entityID common.EntityID) *Entity {
+	if space.cell == nil {
+		return nil
+	}
+	return space.cell.ghosts[entityID]
+}
+
+// setGhostMovement records the movement of ghost for clients which see the ghost later, ghosts are moved by SyncGhosts
+func (e *Entity) setGhostMovement(target Vector3, speed Coord) {
+	if speed <= 0 {
+		e.movement = nil
+		return
+	}
+	e.movement = &entityMovement{path: []Vector3{target}, speed: speed}
+}
+
+// applyGhostAttrOp applies the attribute change forwarded from the real entity, and notifies clients seeing the ghost
+func (e *Entity) applyGhostAttrOp(op []interface{}) {
+	path, _ := op[0].([]interface{})
+	var parent interface{} = e.Attrs
+	for i := len(path) - 1; i >= 0 && parent != nil; i-- {
+		key := path[i]
+		if _, ok := key.(string); !ok {
+			key = int(typeconv.Int(key)) // list indices might be decoded as other integer types
+		}
+		parent, _ = getChildAttr(parent, key)
+	}
+
+	switch a := parent.(type) {
+	case *MapAttr:
+		key, ok := op[1].(string)
+		if !ok {
+			return
+		}
+		if len(op) == 2 {
+			if a.HasKey(key) {
+				a.Del(key)
+			}
+		} else {
+			a.set(key, attrDataToValue(op[2]))
+		}
+	case *ListAttr:
+		index := int(typeconv.Int(op[1]))
+		if len(op) == 3 && index >= 0 && index < a.Size() {
+			a.set(index, attrDataToValue(op[2]))
+		}
+	}
+}
+
+// replaceGhostOf removes the ghost of entity entering the cell, and keeps it on clients which will see the entity
+func (space *Space) replaceGhostOf(entity *Entity) {
+	if space.cell == nil {
+		return
+	}
+
+	if ghost := space.cell.ghosts[entity.ID]; ghost != nil {
+		keepingOnClient = true
+		space.leaveGhost(ghost)
+		keepingOnClient = false
+	}
+}
+
+// leaveGhostOf leaves a ghost of entity handed over to the neighbor cell, so that clients in this cell keep seeing it
+func (space *Space) leaveGhostOf(entity *Entity, pos Vector3) {
+	partition := space.cell.partition
+	index, ok := partition.cellIndices[entity.handoverTo]
+	if !ok {
+		return
+	}
+
+	ghost := newGhostEntity(entity.TypeName, entity.ID, entity.getAllClientData())
+	if ghost == nil {
+		return
+	}
+	ghost.yaw = entity.yaw
+	ghost.ghost.source = index
+	ghost.ghost.lastSync = time.Now()
+	ghost.ghost.keepUntil = ghost.ghost.lastSync.Add(_GHOST_HANDOVER_GRACE)
+	space.enterGhost(ghost, pos)
+}
+
+// clientSpaceID returns the space ID shown to clients, cells of partitioned space are shown as the same space
+func (space *Space) clientSpaceID() common.EntityID {
+	if space.cell != nil {
+		return PartitionWorldID(space.Kind)
+	}
+	return space.ID
+}
+
+// showSpaceOnClient creates the space on client of entity
+func (e *Entity) showSpaceOnClient(space *Space) {
+	if e.client == nil {
+		return
+	}
+
+	spaceID := space.clientSpaceID()
+	if e.unkeepOnClient(spaceID) {
+		return
+	}
+	e.client.sendCreateEntityAs(&space.Entity, spaceID)
+}
+
+// hideSpaceOnClient destroys the space on client of entity, or keeps it during handover
+func (e *Entity) hideSpaceOnClient(space *Space) {
+	if e.client == nil {
+		return
+	}
+
+	spaceID := space.clientSpaceID()
+	if keepingOnClient {
+		e.keepOnClient(space.TypeName, spaceID)
+		return
+	}
+	e.client.sendDestroyEntityID(space.TypeName, spaceID)
+}
+
+// keepOnClient records the entity hidden but not destroyed on client, which is expected to be shown again soon
+func (e *Entity) keepOnClient(typeName string, entityID common.EntityID) {
+	if e.clientKeeps == nil {
+		e.clientKeeps = map[common.EntityID]string{}
+		clientKeepingEntities.Add(e)
+	}
+	e.clientKeeps[entityID] = typeName
+}
+
+// unkeepOnClient returns true if the entity is kept on client, so that it is not created on client again
+func (e *Entity) unkeepOnClient(entityID common.EntityID) bool {
+	if _, ok := e.clientKeeps[entityID]; !ok {
+		return false
+	}
+	delete(e.clientKeeps, entityID)
+	return true
+}
+
+// flushClientKeeps destroys entities which are kept on client but not shown again
+func (e *Entity) flushClientKeeps() {
+	for entityID, typeName := range e.clientKeeps {
+		e.client.sendDestroyEntityID(typeName, entityID)
+	}
+	e.clientKeeps = nil
+	clientKeepingEntities.Del(e)
+}
+
+func flushClientKeeps(except *Entity) {
+	for e := range clientKeepingEntities {
+		if e != except {
+			e.flushClientKeeps()
+		}
+	}
+}
+
+// dumpClientKeeps puts entities kept on client to migrate data of handover
+func (e *Entity) dumpClientKeeps(migrateData map[string]interface{}) {
+	if len(e.clientKeeps) == 0 {
+		return
+	}
+
+	keeps := make(map[string]interface{}, len(e.clientKeeps))
+	for entityID, typeName := range e.clientKeeps {
+		keeps[string(entityID)] = typeName
+	}
+	migrateData[_CLIENT_KEEPS_MIGRATE_KEY] = keeps
+}
+
+// popClientKeeps removes entities kept on client from migrate data of handover
+func popClientKeeps(migrateData map[string]interface{}) map[string]interface{} {
+	keeps, _ := migrateData[_CLIENT_KEEPS_MIGRATE_KEY].(map[string]interface{})
+	delete(migrateData, _CLIENT_KEEPS_MIGRATE_KEY)
+	return keeps
+}
+
+// loadClientKeeps loads entities kept on client popped from migrate data
+func (e *Entity) loadClientKeeps(keeps map[string]interface{}) {
+	if e.client == nil {
+		return
+	}
+
+	for entityID, typeName := range keeps {
+		if typeName, ok := typeName.(string); ok {
+			e.keepOnClient(typeName, common.EntityID(entityID))
+		}
+	}
+}
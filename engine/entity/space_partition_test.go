@@ -0,0 +1,243 @@
+package entity
+
+import (
+	"testing"
+)
+
+func newTestCellSpace(t *testing.T, kind int, index int) *Space {
+	space := newTestSpace(kind)
+	space.Attrs.SetInt(_SPACE_CELL_ATTR_KEY, int64(index))
+	space.onCellSpaceCreated()
+	if !space.IsPartitionCell() {
+		t.Fatalf("space should be a cell of partitioned space")
+	}
+	return space
+}
+
+func TestSpacePartitionCells(t *testing.T) {
+	const kind = 200
+	SetSpaceKindPartition(kind, SpacePartition{CellSize: 100, CellsX: 3, CellsZ: 2, GhostDistance: 20, HandoverMargin: 5})
+	defer delete(spacePartitions, kind)
+	partition := spacePartitions[kind]
+
+	for _, c := range []struct {
+		pos    Vector3
+		cx, cz int
+	}{
+		{Vector3{50, 0, 50}, 0, 0},
+		{Vector3{150, 0, 150}, 1, 1},
+		{Vector3{-10, 0, 250}, 0, 1},
+		{Vector3{1000, 0, -1}, 2, 0},
+	} {
+		if cx, cz := partition.cellOf(c.pos); cx != c.cx || cz != c.cz {
+			t.Fatalf("cell of %v should be (%d, %d), but is (%d, %d)", c.pos, c.cx, c.cz, cx, cz)
+		}
+	}
+
+	if d := partition.distanceToCell(Vector3{90, 0, 50}, 1, 0); d != 10 {
+		t.Fatalf("distance to neighbor cell should be 10, but is %v", d)
+	}
+	if d := partition.distanceToCell(Vector3{150, 0, 50}, 1, 0); d != 0 {
+		t.Fatalf("distance to containing cell should be 0, but is %v", d)
+	}
+
+	if len(partition.cellIndices) != 6 || partition.cellIndices[PartitionCellID(kind, 2, 1)] != 5 {
+		t.Fatalf("cell indices are wrong: %v", partition.cellIndices)
+	}
+	if PartitionCellID(kind, 0, 1) == PartitionCellID(kind, 1, 0) || PartitionCellID(kind, 0, 0) == PartitionWorldID(kind) {
+		t.Fatalf("cell IDs should be unique")
+	}
+}
+
+func TestSpacePartitionGhosts(t *testing.T) {
+	const kind = 200
+	SetSpaceKindPartition(kind, SpacePartition{CellSize: 100, CellsX: 2, CellsZ: 1, GhostDistance: 20, HandoverMargin: 5})
+	defer delete(spacePartitions, kind)
+
+	space := newTestCellSpace(t, kind, 1)
+	defer space.onCellSpaceDestroy()
+	observer := newTestAOIEntity("testAOIEntity")
+	space.enter(observer, Vector3{105, 0, 50}, false)
+
+	real := newTestAOIEntity("testAOIEntity")
+	space.SyncGhosts(0, []ghostSyncData{{ID: real.ID, Type: real.TypeName, Pos: Vector3{95, 0, 50}, Full: true}})
+	ghost := space.cell.ghosts[real.ID]
+	if ghost == nil || !ghost.IsGhost() || !ghostEntities.Contains(ghost) {
+		t.Fatalf("ghost should be created by SyncGhosts")
+	}
+	if space.entities.Contains(ghost) || !observer.aoi.neighbors.Contains(ghost) {
+		t.Fatalf("ghost should be visible to entities in cell but not counted as entity of space")
+	}
+
+	space.SyncGhosts(0, []ghostSyncData{{ID: real.ID, Type: real.TypeName, Pos: Vector3{98, 0, 50}}})
+	if space.cell.ghosts[real.ID] != ghost || ghost.aoi.pos != (Vector3{98, 0, 50}) {
+		t.Fatalf("ghost should be moved by SyncGhosts")
+	}
+
+	// the real entity is handed over to this cell
+	space.enter(real, Vector3{101, 0, 50}, false)
+	if space.cell.ghosts[real.ID] != nil || ghostEntities.Contains(ghost) || observer.aoi.neighbors.Contains(ghost) {
+		t.Fatalf("ghost should be replaced by the real entity")
+	}
+	if !observer.aoi.neighbors.Contains(real) || len(clientKeepingEntities) != 0 {
+		t.Fatalf("real entity should be visible to observer and client keeps should be flushed")
+	}
+
+	space.SyncGhosts(0, []ghostSyncData{})
+	if len(space.cell.ghosts) != 0 {
+		t.Fatalf("ghosts should be removed when not synced")
+	}
+}
+
+type testGhostEntity struct {
+	Entity
+	inited bool
+}
+
+func (e *testGhostEntity) DefineAttrs(desc *EntityTypeDesc) {
+}
+
+func (e *testGhostEntity) OnInit() {
+	e.inited = true
+	e.SetMaxVisibleNeighbors(10, nil)
+}
+
+func TestSpacePartitionGhostSync(t *testing.T) {
+	const kind = 200
+	SetSpaceKindPartition(kind, SpacePartition{CellSize: 100, CellsX: 2, CellsZ: 1, GhostDistance: 20, HandoverMargin: 5})
+	defer delete(spacePartitions, kind)
+
+	space := newTestCellSpace(t, kind, 1)
+	defer space.onCellSpaceDestroy()
+	real := newTestEntity("testGhostEntity", &testGhostEntity{}, false, true)
+	visibilityCappedEntities.Del(real)
+	space.SyncGhosts(0, []ghostSyncData{{
+		ID: real.ID, Type: real.TypeName, Pos: Vector3{X: 95, Z: 50}, Full: true,
+		Attrs:  map[string]interface{}{"hp": 10, "items": []interface{}{"a", map[string]interface{}{"n": 1}}},
+		MoveTo: Vector3{X: 90, Z: 50}, MoveSpeed: 2,
+	}})
+	ghost := space.cell.ghosts[real.ID]
+	if ghost == nil || ghost.I.(*testGhostEntity).inited || visibilityCappedEntities.Contains(ghost) {
+		t.Fatalf("ghost should be created without OnInit")
+	}
+	if ghost.movement == nil || ghost.movement.path[0] != (Vector3{X: 90, Z: 50}) || movingEntities.Contains(ghost) {
+		t.Fatalf("ghost should keep movement for clients but not be moved by itself")
+	}
+
+	space.SyncGhostAttrs(real.ID, []interface{}{
+		[]interface{}{nil, "hp", int64(8)},
+		[]interface{}{[]interface{}{uint64(1), "items"}, "n", int64(2)},
+		[]interface{}{nil, "items2", []interface{}{int64(1)}},
+	})
+	if ghost.GetInt("hp") != 8 || ghost.GetListAttr("items").GetMapAttr(1).GetInt("n") != 2 || ghost.GetListAttr("items2").Size() != 1 {
+		t.Fatalf("attribute changes should be applied to ghost: %v", ghost.Attrs.ToMap())
+	}
+	space.SyncGhostAttrs(real.ID, []interface{}{[]interface{}{nil, "hp"}})
+	if ghost.Attrs.HasKey("hp") {
+		t.Fatalf("attribute deletion should be applied to ghost")
+	}
+
+	space.MoveGhostOnClients(real.ID, Vector3{X: 95, Z: 50}, 0)
+	if ghost.movement != nil {
+		t.Fatalf("ghost movement should be stopped")
+	}
+
+	observer := newTestAOIEntity("testAOIEntity")
+	space.enter(observer, Vector3{X: 105, Z: 50}, false)
+	if !observer.aoi.neighbors.Contains(ghost) || space.GetEntitiesInRadius(Vector3{X: 100, Z: 50}, 20, nil).Contains(ghost) {
+		t.Fatalf("ghost should be visible but not selected by spatial queries")
+	}
+
+	ghost.SetMaxVisibleNeighbors(10, nil)
+	space.leaveGhost(ghost)
+	if visibilityCappedEntities.Contains(ghost) {
+		t.Fatalf("ghost should be unregistered when it leaves")
+	}
+}
+
+func TestSpacePartitionGhostResync(t *testing.T) {
+	const kind = 200
+	SetSpaceKindPartition(kind, SpacePartition{CellSize: 100, CellsX: 2, CellsZ: 1, GhostDistance: 20, HandoverMargin: 5})
+	defer delete(spacePartitions, kind)
+
+	source := newTestSpace(kind)
+	source.ID = PartitionCellID(kind, 0, 0)
+	source.Attrs.SetInt(_SPACE_CELL_ATTR_KEY, 0)
+	source.onCellSpaceCreated()
+	defer source.onCellSpaceDestroy()
+	entityManager.put(&source.Entity)
+	defer entityManager.del(source.ID)
+
+	space := newTestCellSpace(t, kind, 1)
+	defer space.onCellSpaceDestroy()
+	real := newTestAOIEntity("testAOIEntity")
+	source.cell.ghostedTo[1] = EntityIDSet{real.ID: {}}
+
+	// the ghost was dropped by this cell, but the source cell only sends the position
+	space.SyncGhosts(0, []ghostSyncData{{ID: real.ID, Type: real.TypeName, Pos: Vector3{X: 95, Z: 50}}})
+	ghost := space.cell.ghosts[real.ID]
+	if ghost == nil || !ghost.ghost.partial {
+		t.Fatalf("ghost should be created as partial")
+	}
+	waitPost(t, func() bool { return !source.cell.ghostedTo[1].Contains(real.ID) })
+
+	space.SyncGhosts(0, []ghostSyncData{{ID: real.ID, Type: real.TypeName, Pos: Vector3{X: 95, Z: 50}, Full: true, Attrs: map[string]interface{}{"hp": 10}}})
+	resynced := space.cell.ghosts[real.ID]
+	if resynced == nil || resynced == ghost || resynced.ghost.partial || resynced.GetInt("hp") != 10 {
+		t.Fatalf("ghost should be recreated with full data")
+	}
+}
+
+func TestSpacePartitionGhostAOISettings(t *testing.T) {
+	const kind = 200
+	SetSpaceKindPartition(kind, SpacePartition{CellSize: 100, CellsX: 2, CellsZ: 1, GhostDistance: 20, HandoverMargin: 5})
+	defer delete(spacePartitions, kind)
+
+	space := newTestCellSpace(t, kind, 1)
+	defer space.onCellSpaceDestroy()
+	observer := newTestAOIEntity("testAOIEntity")
+	space.enter(observer, Vector3{X: 105, Z: 50}, false)
+	real := newTestAOIEntity("testAOIEntity")
+	space.SyncGhosts(0, []ghostSyncData{{ID: real.ID, Type: real.TypeName, Pos: Vector3{X: 95, Y: 50, Z: 50}, Full: true}})
+	ghost := space.cell.ghosts[real.ID]
+
+	space.SetAOIDistance(5)
+	if ghost.aoi.dist != 5 || observer.aoi.neighbors.Contains(ghost) || ghost.aoi.neighbors.Contains(observer) {
+		t.Fatalf("aoi distance should be applied to ghosts")
+	}
+	space.SetAOIDistance(100)
+	space.SetAOIHeight(10)
+	if ghost.aoi.height != 10 || ghost.aoi.neighbors.Contains(observer) {
+		t.Fatalf("aoi height should be applied to ghosts")
+	}
+	space.SetAOIHeight(0)
+	space.SetAOICalculator(NewGridAOICalculator(50))
+	space.move(observer, Vector3{X: 106, Z: 50})
+	if !ghost.aoi.neighbors.Contains(observer) || !observer.aoi.neighbors.Contains(ghost) {
+		t.Fatalf("ghosts should be moved to the new aoi calculator")
+	}
+}
+
+func TestKeepOnClient(t *testing.T) {
+	e := newTestAOIEntity("testAOIEntity")
+	other := newTestAOIEntity("testAOIEntity")
+	e.keepOnClient(other.TypeName, other.ID)
+	if !clientKeepingEntities.Contains(e) {
+		t.Fatalf("entity with client keeps should be recorded")
+	}
+
+	migrateData := map[string]interface{}{}
+	e.dumpClientKeeps(migrateData)
+	keeps := popClientKeeps(migrateData)
+	if len(keeps) != 1 || keeps[string(other.ID)] != other.TypeName || len(migrateData) != 0 {
+		t.Fatalf("client keeps should be carried in migrate data: %v", keeps)
+	}
+
+	if !e.unkeepOnClient(other.ID) || e.unkeepOnClient(other.ID) {
+		t.Fatalf("kept entity should be unkept only once")
+	}
+	e.flushClientKeeps()
+	if e.clientKeeps != nil || clientKeepingEntities.Contains(e) {
+		t.Fatalf("client keeps should be flushed")
+	}
+}
@@ -0,0 +1,217 @@
+package entity
+
+import (
+	"math"
+	"sort"
+)
+
+// EntityFilter is used by spatial queries of Space to select entities
+//
+// Ghosts in cells of partitioned space are never selected by spatial queries, see Entity.IsGhost.
+type EntityFilter func(e *Entity) bool
+
+// FilterByType returns an EntityFilter which selects entities of specified type
+func FilterByType(typeName string) EntityFilter {
+	return func(e *Entity) bool {
+		return e.TypeName == typeName
+	}
+}
+
+// visitBox calls f with entities within the box from min to max, and selected by filter if filter is not nil
+func (space *Space) visitBox(min, max Vector3, filter EntityFilter, f func(e *Entity)) {
+	if space.IsNil() {
+		return
+	}
+
+	visit := func(aoi *aoi) {
+		if aoi.pos.Y < min.Y || aoi.pos.Y > max.Y {
+			return
+		}
+		e := aoi.getEntity()
+		if e.IsGhost() {
+			return // ghosts are read-only copies of entities in neighbor cells
+		}
+		if filter == nil || filter(e) {
+			f(e)
+		}
+	}
+
+	if visitor, ok := space.aoiCalc.(AOIVisitor); ok {
+		visitor.VisitBox(min, max, visit)
+		return
+	}
+	for e := range space.entities {
+		if pos := e.aoi.pos; pos.X >= min.X && pos.X <= max.X && pos.Z >= min.Z && pos.Z <= max.Z {
+			visit(&e.aoi)
+		}
+	}
+}
+
+// GetEntitiesInBox returns entities within the box from min to max
+//
+// filter can be nil to select all entities
+func (space *Space) GetEntitiesInBox(min, max Vector3, filter EntityFilter) EntitySet {
+	entities := EntitySet{}
+	space.visitBox(min, max, filter, func(e *Entity) {
+		entities.Add(e)
+	})
+	return entities
+}
+
+// GetEntitiesInRadius returns entities within the radius of center
+//
+// filter can be nil to select all entities
+func (space *Space) GetEntitiesInRadius(center Vector3, radius Coord, filter EntityFilter) EntitySet {
+	entities := EntitySet{}
+	extent := Vector3{radius, radius, radius}
+	space.visitBox(center.Sub(extent), center.Add(extent), filter, func(e *Entity) {
+		if center.DistanceTo(e.aoi.pos) <= radius {
+			entities.Add(e)
+		}
+	})
+	return entities
+}
+
+// GetEntitiesInCone returns entities within the radius of origin and within the angle around the direction of yaw on XZ plane
+//
+// angle is the full angle of the cone in degrees, e.g. 90 selects entities within 45 degrees on both sides of yaw.
+// filter can be nil to select all entities
+func (space *Space) GetEntitiesInCone(origin Vector3, yaw Yaw, angle Yaw, radius Coord, filter EntityFilter) EntitySet {
+	entities := EntitySet{}
+	extent := Vector3{radius, radius, radius}
+	space.visitBox(origin.Sub(extent), origin.Add(extent), filter, func(e *Entity) {
+		pos := e.aoi.pos
+		if origin.DistanceTo(pos) > radius {
+			return
+		}
+
+		dx, dz := pos.X-origin.X, pos.Z-origin.Z
+		if dx == 0 && dz == 0 {
+			entities.Add(e) // entity at the apex of the cone
+			return
+		}
+		// yaw is the angle from Z axis to X axis in degrees
+		diff := math.Mod(math.Atan2(float64(dx), float64(dz))/math.Pi*180-float64(yaw), 360)
+		if diff > 180 {
+			diff -= 360
+		} else if diff < -180 {
+			diff += 360
+		}
+		if math.Abs(diff) <= float64(angle)/2 {
+			entities.Add(e)
+		}
+	})
+	return entities
+}
+
+// GetNearestEntities returns at most k nearest entities within the radius of center, sorted by distance
+//
+// Use FilterByType as filter to find nearest entities of a type, or nil to select all entities.
+func (space *Space) GetNearestEntities(center Vector3, radius Coord, k int, filter EntityFilter) []*Entity {
+	if k <= 0 {
+		return nil
+	}
+
+	type entityDistance struct {
+		entity   *Entity
+		distance Coord
+	}
+
+	// search in the aoi distance first, and expand the search range until k entities are found
+	searchRadius := space.aoiDistance
+	for {
+		if searchRadius > radius {
+			searchRadius = radius
+		}
+
+		var found []entityDistance
+		extent := Vector3{searchRadius, searchRadius, searchRadius}
+		space.visitBox(center.Sub(extent), center.Add(extent), filter, func(e *Entity) {
+			if dist := center.DistanceTo(e.aoi.pos); dist <= searchRadius {
+				found = append(found, entityDistance{e, dist})
+			}
+		})
+
+		if len(found) >= k || searchRadius >= radius {
+			// entities out of the search range are all farther than found entities
+			sort.Slice(found, func(i, j int) bool {
+				return found[i].distance < found[j].distance
+			})
+			if len(found) > k {
+				found = found[:k]
+			}
+			entities := make([]*Entity, len(found))
+			for i, ed := range found {
+				entities[i] = ed.entity
+			}
+			return entities
+		}
+		searchRadius *= 2
+	}
+}
+
+// Raycast returns the first entity hit by the ray from origin along dir within maxDist, and the distance of the hit
+//
+// Entities are treated as spheres of hitRadius. Returns nil if no entity is hit.
+// filter can be nil to select all entities, e.g. a filter can be used to exclude the caster itself.
+func (space *Space) Raycast(origin Vector3, dir Vector3, maxDist Coord, hitRadius Coord, filter EntityFilter) (*Entity, Coord) {
+	return space.raycast(origin, dir, maxDist, hitRadius, func(min, max Vector3, f func(e *Entity, pos Vector3)) {
+		space.visitBox(min, max, filter, func(e *Entity) {
+			f(e, e.aoi.pos)
+		})
+	})
+}
+
+// raycast finds the first entity hit by the ray in entities visited by visit within the box from min to max
+func (space *Space) raycast(origin Vector3, dir Vector3, maxDist Coord, hitRadius Coord, visit func(min, max Vector3, f func(e *Entity, pos Vector3))) (*Entity, Coord) {
+	length := Coord(math.Sqrt(float64(dir.X*dir.X + dir.Y*dir.Y + dir.Z*dir.Z)))
+	if length == 0 {
+		return nil, 0
+	}
+	dir = dir.Mul(1 / length)
+
+	end := origin.Add(dir.Mul(maxDist))
+	min := Vector3{minCoord(origin.X, end.X) - hitRadius, minCoord(origin.Y, end.Y) - hitRadius, minCoord(origin.Z, end.Z) - hitRadius}
+	max := Vector3{maxCoord(origin.X, end.X) + hitRadius, maxCoord(origin.Y, end.Y) + hitRadius, maxCoord(origin.Z, end.Z) + hitRadius}
+
+	var hit *Entity
+	hitDist := maxDist
+	visit(min, max, func(e *Entity, pos Vector3) {
+		v := pos.Sub(origin)
+		t := v.X*dir.X + v.Y*dir.Y + v.Z*dir.Z  // projection of entity on the ray
+		d2 := v.X*v.X + v.Y*v.Y + v.Z*v.Z - t*t // square distance from entity to the ray
+		if d2 > hitRadius*hitRadius {
+			return
+		}
+
+		dist := t - Coord(math.Sqrt(float64(hitRadius*hitRadius-d2)))
+		if dist < 0 {
+			if t+Coord(math.Sqrt(float64(hitRadius*hitRadius-d2))) < 0 {
+				return // entity is behind the origin
+			}
+			dist = 0 // origin is inside the entity
+		}
+		if dist < hitDist || (dist == hitDist && hit == nil) {
+			hit, hitDist = e, dist
+		}
+	})
+
+	if hit == nil {
+		return nil, 0
+	}
+	return hit, hitDist
+}
+
+func minCoord(a, b Coord) Coord {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxCoord(a, b Coord) Coord {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,133 @@
+package entity
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func newTestQuerySpace(cal AOICalculator) (*Space, []*Entity) {
+	space := newTestSpace(1)
+	space.SetAOICalculator(cal)
+	var entities []*Entity
+	for i := 0; i < 200; i++ {
+		typeName := "testAOIEntity"
+		if i%4 == 0 {
+			typeName = "testAOIBossEntity"
+		}
+		e := newTestAOIEntity(typeName)
+		space.enter(e, Vector3{Coord(rand.Float32() * 1000), Coord(rand.Float32() * 10), Coord(rand.Float32() * 1000)}, false)
+		entities = append(entities, e)
+	}
+	return space, entities
+}
+
+func TestSpace_GetEntitiesInRadius(t *testing.T) {
+	for _, cal := range []AOICalculator{NewXZListAOICalculator(), NewGridAOICalculator(_DEFAULT_AOI_DISTANCE)} {
+		space, entities := newTestQuerySpace(cal)
+		for i := 0; i < 100; i++ {
+			center := Vector3{Coord(rand.Float32() * 1000), 0, Coord(rand.Float32() * 1000)}
+			radius := Coord(rand.Float32() * 300)
+			result := space.GetEntitiesInRadius(center, radius, FilterByType("testAOIBossEntity"))
+			for _, e := range entities {
+				expected := e.TypeName == "testAOIBossEntity" && center.DistanceTo(e.aoi.pos) <= radius
+				if result.Contains(e) != expected {
+					t.Fatalf("GetEntitiesInRadius(%v, %v): %s at %v should be %v", center, radius, e, e.aoi.pos, expected)
+				}
+			}
+		}
+	}
+}
+
+func TestSpace_GetEntitiesInBox(t *testing.T) {
+	space, entities := newTestQuerySpace(NewGridAOICalculator(_DEFAULT_AOI_DISTANCE))
+	min, max := Vector3{100, 0, 200}, Vector3{400, 5, 300}
+	result := space.GetEntitiesInBox(min, max, nil)
+	for _, e := range entities {
+		pos := e.aoi.pos
+		expected := pos.X >= min.X && pos.X <= max.X && pos.Y >= min.Y && pos.Y <= max.Y && pos.Z >= min.Z && pos.Z <= max.Z
+		if result.Contains(e) != expected {
+			t.Fatalf("GetEntitiesInBox: %s at %v should be %v", e, pos, expected)
+		}
+	}
+}
+
+func TestSpace_GetEntitiesInCone(t *testing.T) {
+	space := newTestSpace(1)
+	front := newTestAOIEntity("testAOIEntity")
+	right := newTestAOIEntity("testAOIEntity")
+	back := newTestAOIEntity("testAOIEntity")
+	far := newTestAOIEntity("testAOIEntity")
+	space.enter(front, Vector3{1, 0, 10}, false)
+	space.enter(right, Vector3{10, 0, 0}, false)
+	space.enter(back, Vector3{0, 0, -10}, false)
+	space.enter(far, Vector3{0, 0, 100}, false)
+
+	result := space.GetEntitiesInCone(Vector3{}, 0, 90, 50, nil)
+	if len(result) != 1 || !result.Contains(front) {
+		t.Errorf("cone facing Z axis should contain front only, but got %v", result)
+	}
+	result = space.GetEntitiesInCone(Vector3{}, 90, 90, 50, nil)
+	if len(result) != 1 || !result.Contains(right) {
+		t.Errorf("cone facing X axis should contain right only, but got %v", result)
+	}
+	result = space.GetEntitiesInCone(Vector3{}, -180, 10, 50, nil)
+	if len(result) != 1 || !result.Contains(back) {
+		t.Errorf("cone facing back should contain back only, but got %v", result)
+	}
+}
+
+func TestSpace_GetNearestEntities(t *testing.T) {
+	space, entities := newTestQuerySpace(NewXZListAOICalculator())
+	for i := 0; i < 100; i++ {
+		center := Vector3{Coord(rand.Float32() * 1000), 0, Coord(rand.Float32() * 1000)}
+		k := 1 + rand.Intn(10)
+		result := space.GetNearestEntities(center, 2000, k, FilterByType("testAOIEntity"))
+		if len(result) != k {
+			t.Fatalf("GetNearestEntities should return %d entities, but got %d", k, len(result))
+		}
+		farthest := center.DistanceTo(result[k-1].aoi.pos)
+		selected := EntitySet{}
+		for i, e := range result {
+			selected.Add(e)
+			if e.TypeName != "testAOIEntity" || (i > 0 && center.DistanceTo(result[i-1].aoi.pos) > center.DistanceTo(e.aoi.pos)) {
+				t.Fatalf("GetNearestEntities returns wrong entities")
+			}
+		}
+		for _, e := range entities {
+			if e.TypeName == "testAOIEntity" && !selected.Contains(e) && center.DistanceTo(e.aoi.pos) < farthest {
+				t.Fatalf("GetNearestEntities missed %s", e)
+			}
+		}
+	}
+
+	if result := space.GetNearestEntities(Vector3{-1000, 0, -1000}, 100, 1, nil); len(result) != 0 {
+		t.Errorf("no entity should be found out of radius")
+	}
+}
+
+func TestSpace_Raycast(t *testing.T) {
+	space := newTestSpace(1)
+	near := newTestAOIEntity("testAOIEntity")
+	far := newTestAOIEntity("testAOIEntity")
+	aside := newTestAOIEntity("testAOIEntity")
+	space.enter(near, Vector3{0, 0, 10}, false)
+	space.enter(far, Vector3{0, 0, 20}, false)
+	space.enter(aside, Vector3{5, 0, 5}, false)
+
+	hit, dist := space.Raycast(Vector3{}, Vector3{0, 0, 2}, 100, 1, nil)
+	if hit != near || dist != 9 {
+		t.Errorf("ray should hit near at 9, but got %v at %v", hit, dist)
+	}
+	hit, _ = space.Raycast(Vector3{}, Vector3{0, 0, 1}, 100, 1, func(e *Entity) bool { return e != near })
+	if hit != far {
+		t.Errorf("ray should hit far, but got %v", hit)
+	}
+	hit, _ = space.Raycast(Vector3{}, Vector3{0, 0, 1}, 5, 1, nil)
+	if hit != nil {
+		t.Errorf("ray should hit nothing within max distance, but got %v", hit)
+	}
+	hit, _ = space.Raycast(Vector3{0, 0, 30}, Vector3{1, 0, 1}, 100, 1, nil)
+	if hit != nil {
+		t.Errorf("ray should hit nothing, but got %v", hit)
+	}
+}
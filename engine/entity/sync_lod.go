@@ -0,0 +1,78 @@
+package entity
+
+import (
+	"sort"
+
+	"github.com/lovelly/goworld/engine/gwlog"
+)
+
+// SyncLODTier declares the sync rate of positions to neighbors within the distance
+type SyncLODTier struct {
+	Distance Coord // neighbors within the distance use this tier
+	Interval int   // positions are synced to neighbors every Interval sync ticks, 1 for every tick
+}
+
+var (
+	syncTick int // number of sync ticks, increased by CollectEntitySyncInfos
+)
+
+// SetSyncLODTiers sets the sync level-of-detail tiers of space
+//
+// Positions of entities are synced to neighbors at the rate of the nearest tier within the distance,
+// and to neighbors out of all tiers at the rate of the farthest tier.
+// Neighbors skipped by their tier receive the latest position at their next sync tick.
+// Setting no tiers syncs positions to all neighbors every sync tick, which is the default.
+func (space *Space) SetSyncLODTiers(tiers ...SyncLODTier) {
+	for _, tier := range tiers {
+		if tier.Distance <= 0 || tier.Interval <= 0 {
+			gwlog.Panicf("%s.SetSyncLODTiers: invalid tier %+v", space, tier)
+		}
+	}
+
+	tiers = append([]SyncLODTier(nil), tiers...)
+	sort.Slice(tiers, func(i, j int) bool {
+		return tiers[i].Distance < tiers[j].Distance
+	})
+	space.syncLODTiers = tiers
+}
+
+// GetSyncLODTiers returns the sync level-of-detail tiers of space
+func (space *Space) GetSyncLODTiers() []SyncLODTier {
+	return space.syncLODTiers
+}
+
+// syncLODInterval returns the sync interval of positions between entities in space
+func (space *Space) syncLODInterval(e, neighbor *Entity) int {
+	tiers := space.syncLODTiers
+	dist := e.aoi.pos.DistanceTo(neighbor.aoi.pos)
+	for _, tier := range tiers {
+		if dist <= tier.Distance {
+			return tier.Interval
+		}
+	}
+	return tiers[len(tiers)-1].Interval
+}
+
+// visitSyncNeighbors visits neighbors which should receive the position of entity in this sync tick
+func (e *Entity) visitSyncNeighbors(syncNeighbors bool, visit func(neighbor *Entity)) {
+	// neighbors skipped in previous sync ticks should receive the latest position
+	pending := e.syncLODPending
+	e.syncLODPending = nil
+
+	space := e.Space
+	useLOD := space != nil && len(space.syncLODTiers) > 0
+	for neighbor := range e.aoi.visibleBy {
+		if !syncNeighbors && !pending.Contains(neighbor) {
+			continue
+		}
+
+		if !useLOD || syncTick%space.syncLODInterval(e, neighbor) == 0 {
+			visit(neighbor)
+		} else {
+			if e.syncLODPending == nil {
+				e.syncLODPending = EntitySet{}
+			}
+			e.syncLODPending.Add(neighbor)
+		}
+	}
+}
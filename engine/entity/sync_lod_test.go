@@ -0,0 +1,70 @@
+package entity
+
+import (
+	"testing"
+)
+
+// collectTestSyncNeighbors runs a sync tick for the entity and returns the neighbors receiving its position
+func collectTestSyncNeighbors(e *Entity, syncNeighbors bool) EntitySet {
+	syncTick += 1
+	synced := EntitySet{}
+	e.visitSyncNeighbors(syncNeighbors, func(neighbor *Entity) {
+		synced.Add(neighbor)
+	})
+	return synced
+}
+
+func TestSyncLODTiers(t *testing.T) {
+	space := newTestSpace(1)
+	space.SetSyncLODTiers(SyncLODTier{Distance: 50, Interval: 3}, SyncLODTier{Distance: 10, Interval: 1})
+	if tiers := space.GetSyncLODTiers(); tiers[0].Distance != 10 || tiers[1].Distance != 50 {
+		t.Fatalf("tiers should be sorted by distance: %v", tiers)
+	}
+
+	e := newTestAOIEntity("testAOIEntity")
+	near := newTestAOIEntity("testAOIEntity")
+	far := newTestAOIEntity("testAOIEntity")
+	farther := newTestAOIEntity("testAOIEntity")
+	space.enter(e, Vector3{}, false)
+	space.enter(near, Vector3{5, 0, 0}, false)
+	space.enter(far, Vector3{30, 0, 0}, false)
+	space.enter(farther, Vector3{0, 0, 80}, false)
+
+	syncTick = 0
+	nearCount, farCount, fartherCount := 0, 0, 0
+	for i := 0; i < 6; i++ {
+		synced := collectTestSyncNeighbors(e, true)
+		if synced.Contains(near) {
+			nearCount += 1
+		}
+		if synced.Contains(far) {
+			farCount += 1
+		}
+		if synced.Contains(farther) {
+			fartherCount += 1
+		}
+	}
+	if nearCount != 6 || farCount != 2 || fartherCount != 2 {
+		t.Fatalf("wrong sync counts: near %d, far %d, farther %d", nearCount, farCount, fartherCount)
+	}
+
+	// skipped neighbors should receive the latest position at the next sync tick of their tier
+	syncTick = 0
+	if synced := collectTestSyncNeighbors(e, true); !synced.Contains(near) || synced.Contains(far) {
+		t.Fatalf("only near neighbor should be synced: %v", synced)
+	}
+	if synced := collectTestSyncNeighbors(e, false); len(synced) != 0 {
+		t.Fatalf("no neighbor should be synced: %v", synced)
+	}
+	if synced := collectTestSyncNeighbors(e, false); !synced.Contains(far) || !synced.Contains(farther) || synced.Contains(near) {
+		t.Fatalf("skipped neighbors should be synced: %v", synced)
+	}
+	if e.syncLODPending != nil {
+		t.Fatalf("no neighbor should be pending: %v", e.syncLODPending)
+	}
+
+	space.SetSyncLODTiers()
+	if synced := collectTestSyncNeighbors(e, true); len(synced) != 3 {
+		t.Fatalf("all neighbors should be synced without tiers: %v", synced)
+	}
+}
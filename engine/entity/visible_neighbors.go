@@ -0,0 +1,96 @@
+package entity
+
+import (
+	"sort"
+)
+
+// NeighborPriorityFunc calculates the priority of neighbor to be visible to the client of observer
+//
+// Neighbors with smaller values have higher priorities.
+type NeighborPriorityFunc func(observer *Entity, neighbor *Entity) float64
+
+// NeighborPriorityByDistance is the default NeighborPriorityFunc: nearer neighbors have higher priorities
+func NeighborPriorityByDistance(observer *Entity, neighbor *Entity) float64 {
+	return float64(observer.DistanceTo(neighbor))
+}
+
+var (
+	visibilityCappedEntities = EntitySet{} // entities with max visible neighbors
+)
+
+// SetMaxVisibleNeighbors limits the count of neighbors visible to the client of this entity
+//
+// Only top max neighbors ranked by priority are created on the client, other neighbors are hidden until they rank back in.
+// Hidden neighbors are still in Neighbors() and receive no sync infos or attribute changes on this client.
+// Rankings are updated periodically by engine. If priority is nil, NeighborPriorityByDistance is used.
+// max <= 0 removes the limit. Should be called in OnInit or OnCreated.
+func (e *Entity) SetMaxVisibleNeighbors(max int, priority NeighborPriorityFunc) {
+	if max <= 0 {
+		e.aoi.maxVisible = 0
+		e.aoi.priority = nil
+		visibilityCappedEntities.Del(e)
+	} else {
+		if priority == nil {
+			priority = NeighborPriorityByDistance
+		}
+		e.aoi.maxVisible = max
+		e.aoi.priority = priority
+		visibilityCappedEntities.Add(e)
+	}
+	e.updateVisibleNeighbors()
+}
+
+// GetMaxVisibleNeighbors returns the max count of neighbors visible to the client of this entity, 0 means unlimited
+func (e *Entity) GetMaxVisibleNeighbors() int {
+	return e.aoi.maxVisible
+}
+
+// updateVisibleNeighbors shows top neighbors to client and hides others
+func (e *Entity) updateVisibleNeighbors() {
+	if e.aoi.maxVisible <= 0 || len(e.aoi.neighbors) <= e.aoi.maxVisible {
+		// all neighbors can be visible
+		for neighbor := range e.aoi.neighbors {
+			if !e.aoi.visible.Contains(neighbor) {
+				e.show(neighbor)
+			}
+		}
+		return
+	}
+
+	type rankedNeighbor struct {
+		entity   *Entity
+		priority float64
+	}
+	ranks := make([]rankedNeighbor, 0, len(e.aoi.neighbors))
+	for neighbor := range e.aoi.neighbors {
+		ranks = append(ranks, rankedNeighbor{neighbor, e.aoi.priority(e, neighbor)})
+	}
+	sort.Slice(ranks, func(i, j int) bool {
+		if ranks[i].priority != ranks[j].priority {
+			return ranks[i].priority < ranks[j].priority
+		}
+		return ranks[i].entity.ID < ranks[j].entity.ID
+	})
+
+	// hide neighbors ranked out before showing neighbors ranked in, so that visible neighbors never exceed the limit
+	for _, rank := range ranks[e.aoi.maxVisible:] {
+		if e.aoi.visible.Contains(rank.entity) {
+			e.hide(rank.entity)
+		}
+	}
+	for _, rank := range ranks[:e.aoi.maxVisible] {
+		if !e.aoi.visible.Contains(rank.entity) {
+			e.show(rank.entity)
+		}
+	}
+}
+
+// UpdateVisibleNeighbors updates visible neighbors of entities with max visible neighbors, called by engine periodically
+func UpdateVisibleNeighbors() {
+	for e := range visibilityCappedEntities {
+		if len(e.aoi.neighbors) > len(e.aoi.visible) {
+			// some neighbors are hidden, rank them again
+			e.updateVisibleNeighbors()
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package entity
+
+import (
+	"testing"
+)
+
+func checkVisibleNeighbors(t *testing.T, e *Entity, expected ...*Entity) {
+	if len(e.VisibleNeighbors()) != len(expected) {
+		t.Fatalf("%s should have %d visible neighbors, but got %d", e, len(expected), len(e.VisibleNeighbors()))
+	}
+	for _, other := range expected {
+		if !e.VisibleNeighbors().Contains(other) || !other.aoi.visibleBy.Contains(e) {
+			t.Fatalf("%s should be visible to %s", other, e)
+		}
+	}
+}
+
+func TestMaxVisibleNeighbors(t *testing.T) {
+	space := newTestSpace(1)
+	observer := newTestAOIEntity("testAOIEntity")
+	space.enter(observer, Vector3{0, 0, 0}, false)
+
+	var others []*Entity
+	for i := 0; i < 5; i++ {
+		other := newTestAOIEntity("testAOIEntity")
+		space.enter(other, Vector3{Coord(10 * (i + 1)), 0, 0}, false)
+		others = append(others, other)
+	}
+
+	observer.SetMaxVisibleNeighbors(2, nil)
+	checkVisibleNeighbors(t, observer, others[0], others[1])
+	if len(observer.Neighbors()) != 5 {
+		t.Errorf("hidden entities should still be neighbors")
+	}
+
+	space.move(others[4], Vector3{1, 0, 0})
+	checkVisibleNeighbors(t, observer, others[0], others[1])
+	UpdateVisibleNeighbors()
+	checkVisibleNeighbors(t, observer, others[4], others[0])
+
+	space.leave(others[4])
+	checkVisibleNeighbors(t, observer, others[0])
+	UpdateVisibleNeighbors()
+	checkVisibleNeighbors(t, observer, others[0], others[1])
+
+	// farther entities have higher priorities
+	observer.SetMaxVisibleNeighbors(2, func(observer *Entity, neighbor *Entity) float64 {
+		return -float64(observer.DistanceTo(neighbor))
+	})
+	checkVisibleNeighbors(t, observer, others[3], others[2])
+
+	observer.SetMaxVisibleNeighbors(0, nil)
+	checkVisibleNeighbors(t, observer, others[0], others[1], others[2], others[3])
+	for _, other := range others[:4] {
+		if !other.VisibleNeighbors().Contains(observer) {
+			t.Errorf("observer should be visible to %s", other)
+		}
+	}
+}
@@ -125,28 +125,28 @@ func (sl *xAOIList) Move(aoi *aoi, oldCoord Coord) {
 	}
 }
 
-func (sl *xAOIList) Mark(aoi *aoi) {
+func (sl *xAOIList) Mark(aoi *aoi, dist Coord) {
 	prev := aoi.xPrev
 	coord := aoi.pos.X
 
-	minCoord := coord - _DEFAULT_AOI_DISTANCE
+	minCoord := coord - dist
 	for prev != nil && prev.pos.X >= minCoord {
 		prev.markVal += 1
 		prev = prev.xPrev
 	}
 
 	next := aoi.xNext
-	maxCoord := coord + _DEFAULT_AOI_DISTANCE
+	maxCoord := coord + dist
 	for next != nil && next.pos.X <= maxCoord {
 		next.markVal += 1
 		next = next.xNext
 	}
 }
 
-func (sl *xAOIList) GetClearMarkedNeighbors(aoi *aoi) (enter []*aoi) {
+func (sl *xAOIList) GetClearMarkedNeighbors(aoi *aoi, dist Coord) (enter []*aoi) {
 	prev := aoi.xPrev
 	coord := aoi.pos.X
-	minCoord := coord - _DEFAULT_AOI_DISTANCE
+	minCoord := coord - dist
 	for prev != nil && prev.pos.X >= minCoord {
 		if prev.markVal == 2 {
 			enter = append(enter, prev)
@@ -156,7 +156,7 @@ func (sl *xAOIList) GetClearMarkedNeighbors(aoi *aoi) (enter []*aoi) {
 	}
 
 	next := aoi.xNext
-	maxCoord := coord + _DEFAULT_AOI_DISTANCE
+	maxCoord := coord + dist
 	for next != nil && next.pos.X <= maxCoord {
 		if next.markVal == 2 {
 			enter = append(enter, next)
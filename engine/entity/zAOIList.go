@@ -125,36 +125,36 @@ func (sl *zAOIList) Move(aoi *aoi, oldCoord Coord) {
 	}
 }
 
-func (sl *zAOIList) Mark(aoi *aoi) {
+func (sl *zAOIList) Mark(aoi *aoi, dist Coord) {
 	prev := aoi.zPrev
 	coord := aoi.pos.Z
 
-	minCoord := coord - _DEFAULT_AOI_DISTANCE
+	minCoord := coord - dist
 	for prev != nil && prev.pos.Z >= minCoord {
 		prev.markVal += 1
 		prev = prev.zPrev
 	}
 
 	next := aoi.zNext
-	maxCoord := coord + _DEFAULT_AOI_DISTANCE
+	maxCoord := coord + dist
 	for next != nil && next.pos.Z <= maxCoord {
 		next.markVal += 1
 		next = next.zNext
 	}
 }
 
-func (sl *zAOIList) ClearMark(aoi *aoi) {
+func (sl *zAOIList) ClearMark(aoi *aoi, dist Coord) {
 	prev := aoi.zPrev
 	coord := aoi.pos.Z
 
-	minCoord := coord - _DEFAULT_AOI_DISTANCE
+	minCoord := coord - dist
 	for prev != nil && prev.pos.Z >= minCoord {
 		prev.markVal = 0
 		prev = prev.zPrev
 	}
 
 	next := aoi.zNext
-	maxCoord := coord + _DEFAULT_AOI_DISTANCE
+	maxCoord := coord + dist
 	for next != nil && next.pos.Z <= maxCoord {
 		next.markVal = 0
 		next = next.zNext
@@ -0,0 +1,68 @@
+package nav
+
+import (
+	"container/heap"
+)
+
+type astarNode struct {
+	node   int
+	fScore float32
+}
+
+type astarOpenList []astarNode
+
+func (ol astarOpenList) Len() int            { return len(ol) }
+func (ol astarOpenList) Less(i, j int) bool  { return ol[i].fScore < ol[j].fScore }
+func (ol astarOpenList) Swap(i, j int)       { ol[i], ol[j] = ol[j], ol[i] }
+func (ol *astarOpenList) Push(x interface{}) { *ol = append(*ol, x.(astarNode)) }
+func (ol *astarOpenList) Pop() interface{} {
+	old := *ol
+	n := old[len(old)-1]
+	*ol = old[:len(old)-1]
+	return n
+}
+
+// astar finds the path of nodes from start to goal using A* algorithm, returns nil if not found
+//
+// neighbors visits all neighbors of a node with the costs, heuristic estimates the cost from a node to goal
+func astar(start, goal int, neighbors func(node int, visit func(neighbor int, cost float32)), heuristic func(node int) float32) []int {
+	gScores := map[int]float32{start: 0}
+	cameFrom := map[int]int{}
+	closed := map[int]bool{}
+	openList := &astarOpenList{{start, heuristic(start)}}
+
+	for openList.Len() > 0 {
+		current := heap.Pop(openList).(astarNode).node
+		if current == goal {
+			path := []int{goal}
+			for current != start {
+				current = cameFrom[current]
+				path = append(path, current)
+			}
+			// reverse to get path from start to goal
+			for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+				path[i], path[j] = path[j], path[i]
+			}
+			return path
+		}
+		if closed[current] {
+			continue // already visited with a lower cost
+		}
+		closed[current] = true
+
+		gScore := gScores[current]
+		neighbors(current, func(neighbor int, cost float32) {
+			if closed[neighbor] {
+				return
+			}
+			score := gScore + cost
+			if oldScore, ok := gScores[neighbor]; ok && oldScore <= score {
+				return
+			}
+			gScores[neighbor] = score
+			cameFrom[neighbor] = current
+			heap.Push(openList, astarNode{neighbor, score + heuristic(neighbor)})
+		})
+	}
+	return nil
+}
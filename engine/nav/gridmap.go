@@ -0,0 +1,207 @@
+package nav
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+
+	"github.com/lovelly/goworld/engine/gwlog"
+	"github.com/pkg/errors"
+)
+
+// GridMap is a NavMap of walkability grid on XZ plane
+//
+// Cell (x, z) covers positions from (originX + x * cellSize, originZ + z * cellSize) to (originX + (x+1) * cellSize, originZ + (z+1) * cellSize).
+// Positions out of the grid are not walkable.
+type GridMap struct {
+	width, height    int
+	cellSize         float32
+	originX, originZ float32
+	walkable         []bool
+}
+
+// NewGridMap creates a GridMap with all cells walkable
+func NewGridMap(width, height int, cellSize float32, originX, originZ float32) *GridMap {
+	if width <= 0 || height <= 0 || cellSize <= 0 {
+		gwlog.Panicf("NewGridMap: invalid grid map size: %dx%d, cell size %v", width, height, cellSize)
+	}
+
+	m := &GridMap{
+		width:    width,
+		height:   height,
+		cellSize: cellSize,
+		originX:  originX,
+		originZ:  originZ,
+		walkable: make([]bool, width*height),
+	}
+	for i := range m.walkable {
+		m.walkable[i] = true
+	}
+	return m
+}
+
+// LoadGridMap loads a GridMap from text format
+//
+// The first line is "<width> <height> <cellSize> [<originX> <originZ>]", followed by height lines of width characters.
+// The i-th line is cells with z = i, '.' is walkable and other characters are not walkable.
+// Empty lines and lines starting with '#' before the first line are ignored.
+func LoadGridMap(r io.Reader) (*GridMap, error) {
+	scanner := bufio.NewScanner(r)
+	var header string
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			header = line
+			break
+		}
+	}
+
+	var width, height int
+	var cellSize, originX, originZ float32
+	n, _ := fmt.Sscan(header, &width, &height, &cellSize, &originX, &originZ)
+	if n < 3 || width <= 0 || height <= 0 || cellSize <= 0 {
+		return nil, errors.Errorf("invalid grid map header: %q", header)
+	}
+
+	m := NewGridMap(width, height, cellSize, originX, originZ)
+	for z := 0; z < height; z++ {
+		if !scanner.Scan() {
+			return nil, errors.Errorf("grid map should have %d lines, but got %d", height, z)
+		}
+		line := scanner.Text()
+		if len(line) < width {
+			return nil, errors.Errorf("line %d of grid map should have %d cells, but got %d", z, width, len(line))
+		}
+		for x := 0; x < width; x++ {
+			m.SetWalkable(x, z, line[x] == '.')
+		}
+	}
+	return m, scanner.Err()
+}
+
+// SetWalkable sets if the cell (x, z) is walkable
+func (m *GridMap) SetWalkable(x, z int, walkable bool) {
+	m.walkable[z*m.width+x] = walkable
+}
+
+func (m *GridMap) isCellWalkable(x, z int) bool {
+	return x >= 0 && x < m.width && z >= 0 && z < m.height && m.walkable[z*m.width+x]
+}
+
+// cellCoord converts positions to coordinations in grid, cell (x, z) covers [x, x+1) and [z, z+1)
+func (m *GridMap) cellCoord(p Point) (float32, float32) {
+	return (p.X - m.originX) / m.cellSize, (p.Z - m.originZ) / m.cellSize
+}
+
+func (m *GridMap) cellOf(p Point) (int, int) {
+	x, z := m.cellCoord(p)
+	return int(math.Floor(float64(x))), int(math.Floor(float64(z)))
+}
+
+func (m *GridMap) cellCenter(x, z int, y float32) Point {
+	return Point{m.originX + (float32(x)+0.5)*m.cellSize, y, m.originZ + (float32(z)+0.5)*m.cellSize}
+}
+
+// IsWalkable returns if the position is walkable
+func (m *GridMap) IsWalkable(p Point) bool {
+	return m.isCellWalkable(m.cellOf(p))
+}
+
+// FindPath finds a walkable path from one position to another using A* algorithm on 8-direction grids
+func (m *GridMap) FindPath(from, to Point) ([]Point, error) {
+	fromX, fromZ := m.cellOf(from)
+	toX, toZ := m.cellOf(to)
+	if !m.isCellWalkable(fromX, fromZ) || !m.isCellWalkable(toX, toZ) {
+		return nil, ErrNoPath
+	}
+
+	start, goal := fromZ*m.width+fromX, toZ*m.width+toX
+	cells := astar(start, goal, func(node int, visit func(neighbor int, cost float32)) {
+		x, z := node%m.width, node/m.width
+		for dz := -1; dz <= 1; dz++ {
+			for dx := -1; dx <= 1; dx++ {
+				if (dx == 0 && dz == 0) || !m.isCellWalkable(x+dx, z+dz) {
+					continue
+				}
+				if dx != 0 && dz != 0 {
+					// never cut corners
+					if !m.isCellWalkable(x+dx, z) || !m.isCellWalkable(x, z+dz) {
+						continue
+					}
+					visit((z+dz)*m.width+x+dx, math.Sqrt2)
+				} else {
+					visit((z+dz)*m.width+x+dx, 1)
+				}
+			}
+		}
+	}, func(node int) float32 {
+		dx, dz := float64(node%m.width-toX), float64(node/m.width-toZ)
+		return float32(math.Sqrt(dx*dx + dz*dz))
+	})
+	if cells == nil {
+		return nil, ErrNoPath
+	}
+
+	// smooth the path by skipping cells in line of sight
+	path := []Point{from}
+	for i := 1; i < len(cells)-1; i++ {
+		next := m.cellCenter(cells[i+1]%m.width, cells[i+1]/m.width, from.Y)
+		if _, blocked := m.Raycast(path[len(path)-1], next); blocked {
+			path = append(path, m.cellCenter(cells[i]%m.width, cells[i]/m.width, from.Y))
+		}
+	}
+	if _, blocked := m.Raycast(path[len(path)-1], to); blocked {
+		path = append(path, m.cellCenter(toX, toZ, from.Y))
+	}
+	return append(path, to), nil
+}
+
+// Raycast checks if the straight line from one position to another is walkable by traversing cells along the line
+func (m *GridMap) Raycast(from, to Point) (Point, bool) {
+	x0, z0 := m.cellCoord(from)
+	x1, z1 := m.cellCoord(to)
+	cx, cz := m.cellOf(from)
+	if !m.isCellWalkable(cx, cz) {
+		return from, true
+	}
+
+	dx, dz := x1-x0, z1-z0
+	stepX, tMaxX, tDeltaX := traverseParams(x0, dx, cx)
+	stepZ, tMaxZ, tDeltaZ := traverseParams(z0, dz, cz)
+	for {
+		var t float32
+		if tMaxX < tMaxZ {
+			t = tMaxX
+			cx += stepX
+			tMaxX += tDeltaX
+		} else {
+			t = tMaxZ
+			cz += stepZ
+			tMaxZ += tDeltaZ
+		}
+
+		if t > 1 {
+			return to, false
+		}
+		if !m.isCellWalkable(cx, cz) {
+			// stop just before entering the blocked cell
+			t -= 0.001 / float32(math.Max(math.Abs(float64(dx)), math.Abs(float64(dz))))
+			if t < 0 {
+				t = 0
+			}
+			return lerp(from, to, t), true
+		}
+	}
+}
+
+// traverseParams returns the step direction, the first t crossing cell border and the t between cell borders on an axis
+func traverseParams(start, delta float32, cell int) (int, float32, float32) {
+	if delta > 0 {
+		return 1, (float32(cell+1) - start) / delta, 1 / delta
+	} else if delta < 0 {
+		return -1, (start - float32(cell)) / -delta, 1 / -delta
+	}
+	return 0, float32(math.Inf(1)), float32(math.Inf(1))
+}
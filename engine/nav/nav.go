@@ -0,0 +1,60 @@
+package nav
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+)
+
+// ErrNoPath is returned by FindPath if there is no path between the positions
+var ErrNoPath = errors.New("no path found")
+
+// Point is a position in navigation maps
+type Point struct {
+	X, Y, Z float32
+}
+
+// NavMap is the interface of navigation maps
+//
+// NavMaps must be read-only after loaded, because FindPath is called in async goroutines
+type NavMap interface {
+	// IsWalkable returns if the position is walkable
+	IsWalkable(p Point) bool
+	// FindPath finds a walkable path from one position to another, which includes both positions
+	FindPath(from, to Point) ([]Point, error)
+	// Raycast checks if the straight line from one position to another is walkable,
+	// returns the farthest walkable position along the line and true if the line is blocked
+	Raycast(from, to Point) (Point, bool)
+}
+
+// Load loads a navigation map file
+//
+// Files with extension .grid are loaded by LoadGridMap, and files with extension .navmesh are loaded by LoadNavMesh
+func Load(path string) (NavMap, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	switch filepath.Ext(path) {
+	case ".grid":
+		return LoadGridMap(f)
+	case ".navmesh":
+		return LoadNavMesh(f)
+	default:
+		return nil, errors.Errorf("unknown navigation map file: %s", path)
+	}
+}
+
+func lerp(from, to Point, t float32) Point {
+	return Point{from.X + (to.X-from.X)*t, from.Y + (to.Y-from.Y)*t, from.Z + (to.Z-from.Z)*t}
+}
+
+// distance2D calculates distance between two positions on XZ plane
+func distance2D(a, b Point) float32 {
+	dx, dz := b.X-a.X, b.Z-a.Z
+	return float32(math.Sqrt(float64(dx*dx + dz*dz)))
+}
@@ -0,0 +1,196 @@
+package nav
+
+import (
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+const testGridMap = `# a wall at x = 5 with a hole at z = 9
+10 10 1
+.....#....
+.....#....
+.....#....
+.....#....
+.....#....
+.....#....
+.....#....
+.....#....
+.....#....
+..........
+`
+
+// newTestNavMesh creates a NavMesh of unit squares, each square is split into 2 triangles
+func newTestNavMesh(t *testing.T, squares [][2]int) *NavMesh {
+	var vertices []Point
+	var triangles [][3]int
+	vertexIndex := map[Point]int{}
+	vertex := func(x, z int) int {
+		p := Point{float32(x), 0, float32(z)}
+		if index, ok := vertexIndex[p]; ok {
+			return index
+		}
+		vertices = append(vertices, p)
+		vertexIndex[p] = len(vertices) - 1
+		return len(vertices) - 1
+	}
+	for _, sq := range squares {
+		x, z := sq[0], sq[1]
+		v1, v2, v3, v4 := vertex(x, z), vertex(x+1, z), vertex(x+1, z+1), vertex(x, z+1)
+		triangles = append(triangles, [3]int{v1, v2, v3}, [3]int{v1, v3, v4})
+	}
+	m, err := NewNavMesh(vertices, triangles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func checkPath(t *testing.T, m NavMap, path []Point, from, to Point) {
+	if len(path) < 2 || path[0] != from || path[len(path)-1] != to {
+		t.Fatalf("wrong path from %v to %v: %v", from, to, path)
+	}
+	for i := 0; i < len(path)-1; i++ {
+		if _, blocked := m.Raycast(path[i], path[i+1]); blocked {
+			t.Fatalf("path is blocked from %v to %v: %v", path[i], path[i+1], path)
+		}
+	}
+}
+
+func TestGridMap(t *testing.T) {
+	m, err := LoadGridMap(strings.NewReader(testGridMap))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !m.IsWalkable(Point{1, 0, 1}) || m.IsWalkable(Point{5.5, 0, 1}) || m.IsWalkable(Point{-1, 0, 1}) || m.IsWalkable(Point{1, 0, 10}) {
+		t.Errorf("wrong walkability")
+	}
+
+	from, to := Point{1.5, 0, 1.5}, Point{8.5, 0, 1.5}
+	hit, blocked := m.Raycast(from, to)
+	if !blocked || hit.X > 5 || hit.X < 4.99 || !m.IsWalkable(hit) {
+		t.Errorf("raycast should be blocked by wall, but got %v, %v", hit, blocked)
+	}
+	if hit, blocked := m.Raycast(from, Point{4.5, 0, 8.5}); blocked || hit != (Point{4.5, 0, 8.5}) {
+		t.Errorf("raycast should not be blocked")
+	}
+
+	path, err := m.FindPath(from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkPath(t, m, path, from, to)
+	if len(path) != 4 {
+		t.Errorf("path should turn around the hole of wall: %v", path)
+	}
+
+	m.SetWalkable(5, 9, false)
+	if _, err := m.FindPath(from, to); err != ErrNoPath {
+		t.Errorf("there should be no path, but got %v", err)
+	}
+}
+
+func TestGridMapRandom(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		m := NewGridMap(20, 20, 2, -10, -10)
+		for j := 0; j < 100; j++ {
+			m.SetWalkable(rand.Intn(20), rand.Intn(20), false)
+		}
+
+		randPos := func() Point {
+			return Point{rand.Float32()*40 - 10, 0, rand.Float32()*40 - 10}
+		}
+		for j := 0; j < 10; j++ {
+			from, to := randPos(), randPos()
+			path, err := m.FindPath(from, to)
+			if !m.IsWalkable(from) || !m.IsWalkable(to) {
+				if err != ErrNoPath {
+					t.Fatalf("path from or to unwalkable positions should not be found")
+				}
+			} else if err == nil {
+				checkPath(t, m, path, from, to)
+			}
+		}
+	}
+}
+
+func TestNavMesh(t *testing.T) {
+	// an L-shaped corridor
+	m := newTestNavMesh(t, [][2]int{{0, 0}, {1, 0}, {2, 0}, {3, 0}, {3, 1}, {3, 2}})
+	from, to := Point{0.5, 0, 0.5}, Point{3.5, 0, 2.5}
+	if !m.IsWalkable(from) || !m.IsWalkable(to) || m.IsWalkable(Point{1.5, 0, 1.5}) {
+		t.Errorf("wrong walkability")
+	}
+
+	hit, blocked := m.Raycast(from, to)
+	if !blocked || m.IsWalkable(Point{hit.X, 0, hit.Z + 0.01}) {
+		t.Errorf("raycast should be blocked by the edge of navmesh, but got %v, %v", hit, blocked)
+	}
+	if _, blocked := m.Raycast(from, Point{3.9, 0, 0.1}); blocked {
+		t.Errorf("raycast should not be blocked")
+	}
+
+	path, err := m.FindPath(from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkPath(t, m, path, from, to)
+	if len(path) != 3 || path[1] != (Point{3, 0, 1}) {
+		t.Errorf("path should turn at the corner: %v", path)
+	}
+
+	if _, err := m.FindPath(from, Point{10, 0, 10}); err != ErrNoPath {
+		t.Errorf("there should be no path, but got %v", err)
+	}
+}
+
+func TestLoadNavMesh(t *testing.T) {
+	m, err := LoadNavMesh(strings.NewReader(`{"vertices": [[0, 1, 0], [10, 1, 0], [10, 3, 10], [0, 3, 10]], "triangles": [[0, 1, 2], [0, 2, 3]]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.neighbors[0][2] != 1 || m.neighbors[1][0] != 0 {
+		t.Errorf("wrong neighbors: %v", m.neighbors)
+	}
+	if h := m.heightAt(0, Point{5, 0, 5}); math.Abs(float64(h-2)) > 1e-5 {
+		t.Errorf("height should be 2, but got %v", h)
+	}
+	if _, err := LoadNavMesh(strings.NewReader(`{"vertices": [[0, 0, 0]], "triangles": [[0, 1, 2]]}`)); err == nil {
+		t.Errorf("invalid vertex index should fail")
+	}
+}
+
+func TestNavMeshGrid(t *testing.T) {
+	cells := [][2]int{}
+	for x := 0; x < 20; x++ {
+		for z := 0; z < 20; z++ {
+			if (x+z)%3 != 0 {
+				cells = append(cells, [2]int{x, z})
+			}
+		}
+	}
+	m := newTestNavMesh(t, cells)
+	if len(m.grid) < len(m.triangles)/4 {
+		t.Errorf("grid should have enough buckets: %d buckets for %d triangles", len(m.grid), len(m.triangles))
+	}
+
+	// the grid should find the same triangle as scanning all triangles
+	for x := float32(-1); x < 21; x += 0.37 {
+		for z := float32(-1); z < 21; z += 0.37 {
+			p := Point{x, 0, z}
+			found := m.findTriangle(p)
+			scanned := false
+			for i := range m.triangles {
+				if m.triangleContains(i, p) {
+					scanned = true
+					break
+				}
+			}
+			if (found >= 0) != scanned || (found >= 0 && !m.triangleContains(found, p)) {
+				t.Fatalf("findTriangle(%v) = %d, but scanning found %v", p, found, scanned)
+			}
+		}
+	}
+}
@@ -0,0 +1,390 @@
+package nav
+
+import (
+	"encoding/json"
+	"io"
+	"math"
+
+	"github.com/pkg/errors"
+)
+
+// NavMesh is a NavMap of triangles which are walkable
+//
+// Paths are found by A* algorithm on triangles, and smoothed by funnel algorithm.
+type NavMesh struct {
+	vertices  []Point
+	triangles [][3]int
+	neighbors [][3]int // neighbors[i][j] is the triangle sharing edge j (from vertex j to vertex j+1) of triangle i, or -1
+	centers   []Point
+
+	// triangles are indexed by a grid of buckets on XZ plane for finding the triangle containing a position
+	gridMin      Point
+	gridCellSize float32
+	gridCellsX   int
+	gridCellsZ   int
+	grid         [][]int // grid[z*gridCellsX+x] is the triangles overlapping cell (x, z)
+}
+
+type navMeshData struct {
+	Vertices  [][3]float32 `json:"vertices"`
+	Triangles [][3]int     `json:"triangles"`
+}
+
+// NewNavMesh creates a NavMesh of triangles, each triangle is 3 indices of vertices
+func NewNavMesh(vertices []Point, triangles [][3]int) (*NavMesh, error) {
+	type edgeKey struct {
+		v1, v2 int
+	}
+	type triangleEdge struct {
+		triangle, edge int
+	}
+
+	m := &NavMesh{
+		vertices:  vertices,
+		triangles: triangles,
+		neighbors: make([][3]int, len(triangles)),
+		centers:   make([]Point, len(triangles)),
+	}
+	edges := map[edgeKey]triangleEdge{}
+	for i, tri := range triangles {
+		for _, v := range tri {
+			if v < 0 || v >= len(vertices) {
+				return nil, errors.Errorf("triangle %d: invalid vertex index %d", i, v)
+			}
+		}
+
+		a, b, c := vertices[tri[0]], vertices[tri[1]], vertices[tri[2]]
+		m.centers[i] = Point{(a.X + b.X + c.X) / 3, (a.Y + b.Y + c.Y) / 3, (a.Z + b.Z + c.Z) / 3}
+		for j := 0; j < 3; j++ {
+			m.neighbors[i][j] = -1
+			key := edgeKey{tri[j], tri[(j+1)%3]}
+			if key.v1 > key.v2 {
+				key.v1, key.v2 = key.v2, key.v1
+			}
+			if other, ok := edges[key]; ok {
+				m.neighbors[i][j] = other.triangle
+				m.neighbors[other.triangle][other.edge] = i
+			} else {
+				edges[key] = triangleEdge{i, j}
+			}
+		}
+	}
+	m.buildGrid()
+	return m, nil
+}
+
+// triangleBounds returns the min and max corners of the triangle on XZ plane
+func (m *NavMesh) triangleBounds(tri int) (min, max Point) {
+	t := m.triangles[tri]
+	min, max = m.vertices[t[0]], m.vertices[t[0]]
+	extendBounds(&min, &max, m.vertices[t[1]])
+	extendBounds(&min, &max, m.vertices[t[2]])
+	return
+}
+
+// extendBounds extends the min and max corners on XZ plane to contain the position
+func extendBounds(min, max *Point, p Point) {
+	if p.X < min.X {
+		min.X = p.X
+	} else if p.X > max.X {
+		max.X = p.X
+	}
+	if p.Z < min.Z {
+		min.Z = p.Z
+	} else if p.Z > max.Z {
+		max.Z = p.Z
+	}
+}
+
+// buildGrid puts triangles into grid buckets, so that each bucket has about one triangle on average
+func (m *NavMesh) buildGrid() {
+	if len(m.triangles) == 0 {
+		return
+	}
+
+	min, max := m.triangleBounds(0)
+	for i := range m.triangles {
+		tmin, tmax := m.triangleBounds(i)
+		extendBounds(&min, &max, tmin)
+		extendBounds(&min, &max, tmax)
+	}
+
+	width, depth := max.X-min.X, max.Z-min.Z
+	cellSize := float32(math.Sqrt(float64(width) * float64(depth) / float64(len(m.triangles))))
+	if cellSize <= 0 {
+		cellSize = float32(math.Max(float64(width), float64(depth)))
+	}
+	if cellSize <= 0 {
+		cellSize = 1
+	}
+
+	m.gridMin = min
+	m.gridCellSize = cellSize
+	m.gridCellsX = int(width/cellSize) + 1
+	m.gridCellsZ = int(depth/cellSize) + 1
+	m.grid = make([][]int, m.gridCellsX*m.gridCellsZ)
+	for i := range m.triangles {
+		tmin, tmax := m.triangleBounds(i)
+		x0, z0 := m.gridCellOf(tmin)
+		x1, z1 := m.gridCellOf(tmax)
+		for z := z0; z <= z1; z++ {
+			for x := x0; x <= x1; x++ {
+				m.grid[z*m.gridCellsX+x] = append(m.grid[z*m.gridCellsX+x], i)
+			}
+		}
+	}
+}
+
+// gridCellOf returns the grid cell containing the position, positions out of the grid belong to the nearest cell
+func (m *NavMesh) gridCellOf(p Point) (x, z int) {
+	clamp := func(v float32, n int) int {
+		c := int(v / m.gridCellSize)
+		if v < 0 || c < 0 {
+			return 0
+		} else if c >= n {
+			return n - 1
+		}
+		return c
+	}
+	return clamp(p.X-m.gridMin.X, m.gridCellsX), clamp(p.Z-m.gridMin.Z, m.gridCellsZ)
+}
+
+// LoadNavMesh loads a NavMesh from JSON format
+//
+// The JSON object has "vertices" as an array of [x, y, z] and "triangles" as an array of [v1, v2, v3] indices of vertices.
+func LoadNavMesh(r io.Reader) (*NavMesh, error) {
+	var data navMeshData
+	if err := json.NewDecoder(r).Decode(&data); err != nil {
+		return nil, err
+	}
+
+	vertices := make([]Point, len(data.Vertices))
+	for i, v := range data.Vertices {
+		vertices[i] = Point{v[0], v[1], v[2]}
+	}
+	return NewNavMesh(vertices, data.Triangles)
+}
+
+func (m *NavMesh) edge(tri int, j int) (Point, Point) {
+	t := m.triangles[tri]
+	return m.vertices[t[j]], m.vertices[t[(j+1)%3]]
+}
+
+// triarea2 calculates twice of the signed area of triangle abc on XZ plane, positive if c is on the right of ab
+func triarea2(a, b, c Point) float32 {
+	return (c.X-a.X)*(b.Z-a.Z) - (b.X-a.X)*(c.Z-a.Z)
+}
+
+func (m *NavMesh) triangleContains(tri int, p Point) bool {
+	t := m.triangles[tri]
+	a, b, c := m.vertices[t[0]], m.vertices[t[1]], m.vertices[t[2]]
+	d1, d2, d3 := triarea2(a, b, p), triarea2(b, c, p), triarea2(c, a, p)
+	hasNeg := d1 < 0 || d2 < 0 || d3 < 0
+	hasPos := d1 > 0 || d2 > 0 || d3 > 0
+	return !(hasNeg && hasPos)
+}
+
+// heightAt calculates the height of the position on the plane of triangle
+func (m *NavMesh) heightAt(tri int, p Point) float32 {
+	t := m.triangles[tri]
+	a, b, c := m.vertices[t[0]], m.vertices[t[1]], m.vertices[t[2]]
+	area := triarea2(a, b, c)
+	if area == 0 {
+		return a.Y
+	}
+	wa, wb := triarea2(b, c, p)/area, triarea2(c, a, p)/area
+	return wa*a.Y + wb*b.Y + (1-wa-wb)*c.Y
+}
+
+// findTriangle returns the triangle containing the position on XZ plane with the nearest height, or -1 if not found
+func (m *NavMesh) findTriangle(p Point) int {
+	if m.grid == nil {
+		return -1
+	}
+
+	found := -1
+	var foundDY float32
+	x, z := m.gridCellOf(p)
+	for _, i := range m.grid[z*m.gridCellsX+x] {
+		if !m.triangleContains(i, p) {
+			continue
+		}
+		dy := m.heightAt(i, p) - p.Y
+		if dy < 0 {
+			dy = -dy
+		}
+		if found < 0 || dy < foundDY {
+			found, foundDY = i, dy
+		}
+	}
+	return found
+}
+
+// IsWalkable returns if the position is in any triangle of the NavMesh
+func (m *NavMesh) IsWalkable(p Point) bool {
+	return m.findTriangle(p) >= 0
+}
+
+// FindPath finds a walkable path from one position to another
+func (m *NavMesh) FindPath(from, to Point) ([]Point, error) {
+	start, goal := m.findTriangle(from), m.findTriangle(to)
+	if start < 0 || goal < 0 {
+		return nil, ErrNoPath
+	}
+
+	triangles := astar(start, goal, func(tri int, visit func(neighbor int, cost float32)) {
+		for _, neighbor := range m.neighbors[tri] {
+			if neighbor >= 0 {
+				visit(neighbor, distance2D(m.centers[tri], m.centers[neighbor]))
+			}
+		}
+	}, func(tri int) float32 {
+		return distance2D(m.centers[tri], to)
+	})
+	if triangles == nil {
+		return nil, ErrNoPath
+	}
+
+	// portals are shared edges of triangles along the path, as [left, right]
+	portals := [][2]Point{{from, from}}
+	for i := 0; i < len(triangles)-1; i++ {
+		tri, next := triangles[i], triangles[i+1]
+		for j, neighbor := range m.neighbors[tri] {
+			if neighbor != next {
+				continue
+			}
+			p, q := m.edge(tri, j)
+			if triarea2(m.centers[tri], p, q) > 0 {
+				portals = append(portals, [2]Point{p, q})
+			} else {
+				portals = append(portals, [2]Point{q, p})
+			}
+			break
+		}
+	}
+	portals = append(portals, [2]Point{to, to})
+	return stringPull(portals), nil
+}
+
+// stringPull finds the shortest path through portals using the simple stupid funnel algorithm
+func stringPull(portals [][2]Point) []Point {
+	apex, left, right := portals[0][0], portals[0][0], portals[0][1]
+	apexIndex, leftIndex, rightIndex := 0, 0, 0
+	path := []Point{apex}
+
+	for i := 1; i < len(portals); i++ {
+		l, r := portals[i][0], portals[i][1]
+
+		// update right vertex
+		if triarea2(apex, right, r) <= 0 {
+			if apex == right || triarea2(apex, left, r) > 0 {
+				// tighten the funnel
+				right, rightIndex = r, i
+			} else {
+				// right over left, left becomes the new apex
+				if path[len(path)-1] != left {
+					path = append(path, left)
+				}
+				apex, apexIndex = left, leftIndex
+				left, right = apex, apex
+				leftIndex, rightIndex = apexIndex, apexIndex
+				i = apexIndex
+				continue
+			}
+		}
+
+		// update left vertex
+		if triarea2(apex, left, l) >= 0 {
+			if apex == left || triarea2(apex, right, l) < 0 {
+				// tighten the funnel
+				left, leftIndex = l, i
+			} else {
+				// left over right, right becomes the new apex
+				if path[len(path)-1] != right {
+					path = append(path, right)
+				}
+				apex, apexIndex = right, rightIndex
+				left, right = apex, apex
+				leftIndex, rightIndex = apexIndex, apexIndex
+				i = apexIndex
+				continue
+			}
+		}
+	}
+
+	end := portals[len(portals)-1][0]
+	if path[len(path)-1] != end {
+		path = append(path, end)
+	}
+	return path
+}
+
+// Raycast checks if the straight line from one position to another is walkable by walking through triangles along the line
+func (m *NavMesh) Raycast(from, to Point) (Point, bool) {
+	tri := m.findTriangle(from)
+	if tri < 0 {
+		return from, true
+	}
+	if distance2D(from, to) == 0 {
+		return to, false
+	}
+	// if from is on edges or vertices, start from the triangle in the direction of the line
+	if probe := m.findTriangle(lerp(from, to, 0.001/distance2D(from, to))); probe >= 0 && m.triangleContains(probe, from) {
+		tri = probe
+	}
+
+	prev := -1
+	var t float32
+	for steps := 0; steps < len(m.triangles); steps++ {
+		if m.triangleContains(tri, to) {
+			return to, false
+		}
+
+		// find the edge where the line leaves the triangle
+		exitEdge, exitT := -1, t
+		for j := 0; j < 3; j++ {
+			if prev >= 0 && m.neighbors[tri][j] == prev {
+				continue // the edge where the line enters the triangle
+			}
+			a, b := m.edge(tri, j)
+			if et, ok := intersectSegments(from, to, a, b); ok && et >= exitT {
+				exitEdge, exitT = j, et
+			}
+		}
+		if exitEdge < 0 {
+			break
+		}
+
+		next := m.neighbors[tri][exitEdge]
+		if next < 0 {
+			// stop just before leaving the navmesh
+			t = exitT - 0.001/distance2D(from, to)
+			break
+		}
+		prev, tri, t = tri, next, exitT
+	}
+
+	if t < 0 {
+		t = 0
+	}
+	return lerp(from, to, t), true
+}
+
+// intersectSegments returns t where segment from p1 to p2 intersects with segment from a to b on XZ plane
+func intersectSegments(p1, p2, a, b Point) (float32, bool) {
+	rx, rz := p2.X-p1.X, p2.Z-p1.Z
+	sx, sz := b.X-a.X, b.Z-a.Z
+	denom := rx*sz - rz*sx
+	if denom == 0 {
+		return 0, false // parallel
+	}
+
+	qx, qz := a.X-p1.X, a.Z-p1.Z
+	t := (qx*sz - qz*sx) / denom
+	u := (qx*rz - qz*rx) / denom
+	const epsilon = 1e-5
+	if t < 0 || u < -epsilon || u > 1+epsilon {
+		return 0, false
+	}
+	return t, true
+}
@@ -82,23 +82,65 @@ func (gwc *GoWorldConnection) SendNotifyClientDisconnected(id common.ClientID) e
 }
 
 // SendCreateEntityAnywhere sends MT_CREATE_ENTITY_ANYWHERE message
-func (gwc *GoWorldConnection) SendCreateEntityAnywhere(typeName string, data map[string]interface{}) error {
+//
+// requestID is 0 if no reply is required
+func (gwc *GoWorldConnection) SendCreateEntityAnywhere(typeName string, entityID common.EntityID, requestID uint32, data map[string]interface{}) error {
 	packet := gwc.packetConn.NewPacket()
 	packet.AppendUint16(MT_CREATE_ENTITY_ANYWHERE)
+	packet.AppendEntityID(entityID)
 	packet.AppendVarStr(typeName)
+	packet.AppendUint32(requestID)
 	packet.AppendData(data)
 	return gwc.SendPacketRelease(packet)
 }
 
 // SendLoadEntityAnywhere sends MT_LOAD_ENTITY_ANYWHERE message
-func (gwc *GoWorldConnection) SendLoadEntityAnywhere(typeName string, entityID common.EntityID) error {
+//
+// requestID is 0 if no reply is required
+func (gwc *GoWorldConnection) SendLoadEntityAnywhere(typeName string, entityID common.EntityID, requestID uint32) error {
 	packet := gwc.packetConn.NewPacket()
 	packet.AppendUint16(MT_LOAD_ENTITY_ANYWHERE)
 	packet.AppendEntityID(entityID)
 	packet.AppendVarStr(typeName)
+	packet.AppendUint32(requestID)
+	return gwc.SendPacketRelease(packet)
+}
+
+// SendLoadEntityInSpace sends MT_LOAD_ENTITY_IN_SPACE message
+//
+// The dispatcher sends it back to load the entity in the space only if the entity is not on any game
+func (gwc *GoWorldConnection) SendLoadEntityInSpace(typeName string, entityID common.EntityID, spaceID common.EntityID, x, y, z float32) error {
+	packet := gwc.packetConn.NewPacket()
+	packet.AppendUint16(MT_LOAD_ENTITY_IN_SPACE)
+	packet.AppendEntityID(entityID)
+	packet.AppendVarStr(typeName)
+	packet.AppendEntityID(spaceID)
+	packet.AppendFloat32(x)
+	packet.AppendFloat32(y)
+	packet.AppendFloat32(z)
+	return gwc.SendPacketRelease(packet)
+}
+
+// SendNotifyCreateEntityFailed sends MT_NOTIFY_CREATE_ENTITY_FAILED message
+func (gwc *GoWorldConnection) SendNotifyCreateEntityFailed(id common.EntityID, errcode uint16, errmsg string) error {
+	packet := gwc.packetConn.NewPacket()
+	packet.AppendUint16(MT_NOTIFY_CREATE_ENTITY_FAILED)
+	packet.AppendEntityID(id)
+	packet.AppendUint16(errcode)
+	packet.AppendVarStr(errmsg)
 	return gwc.SendPacketRelease(packet)
 }
 
+// AppendCreateEntityAnywhereReply writes a MT_CREATE_ENTITY_ANYWHERE_REPLY message to the packet
+func AppendCreateEntityAnywhereReply(packet *netutil.Packet, requestID uint32, entityID common.EntityID, gameid uint16, errcode uint16, errmsg string) {
+	packet.AppendUint16(MT_CREATE_ENTITY_ANYWHERE_REPLY)
+	packet.AppendUint32(requestID)
+	packet.AppendEntityID(entityID)
+	packet.AppendUint16(gameid)
+	packet.AppendUint16(errcode)
+	packet.AppendVarStr(errmsg)
+}
+
 // SendDeclareService sends MT_DECLARE_SERVICE message
 func (gwc *GoWorldConnection) SendDeclareService(id common.EntityID, serviceName string) error {
 	packet := gwc.packetConn.NewPacket()
@@ -118,6 +160,44 @@ func (gwc *GoWorldConnection) SendCallEntityMethod(id common.EntityID, method st
 	return gwc.SendPacketRelease(packet)
 }
 
+// SendCallEntityMethodWithReply sends MT_CALL_ENTITY_METHOD_WITH_REPLY message
+func (gwc *GoWorldConnection) SendCallEntityMethodWithReply(id common.EntityID, requestID uint32, method string, args []interface{}) error {
+	packet := gwc.packetConn.NewPacket()
+	packet.AppendUint16(MT_CALL_ENTITY_METHOD_WITH_REPLY)
+	packet.AppendEntityID(id)
+	packet.AppendUint32(requestID)
+	packet.AppendVarStr(method)
+	packet.AppendArgs(args)
+	return gwc.SendPacketRelease(packet)
+}
+
+// SendCallEntityMethodReply sends MT_CALL_ENTITY_METHOD_REPLY message
+func (gwc *GoWorldConnection) SendCallEntityMethodReply(callerGame uint16, requestID uint32, errmsg string, results []interface{}) error {
+	packet := gwc.packetConn.NewPacket()
+	AppendCallEntityMethodReply(packet, callerGame, requestID, errmsg, results)
+	return gwc.SendPacketRelease(packet)
+}
+
+// AppendCallEntityMethodReply writes a MT_CALL_ENTITY_METHOD_REPLY message to the packet
+func AppendCallEntityMethodReply(packet *netutil.Packet, callerGame uint16, requestID uint32, errmsg string, results []interface{}) {
+	packet.AppendUint16(MT_CALL_ENTITY_METHOD_REPLY)
+	packet.AppendUint16(callerGame)
+	packet.AppendUint32(requestID)
+	packet.AppendVarStr(errmsg)
+	packet.AppendData(results)
+}
+
+// SendGiveClientToEntity sends MT_GIVE_CLIENT_TO_ENTITY message
+func (gwc *GoWorldConnection) SendGiveClientToEntity(targetID common.EntityID, sourceID common.EntityID, gid uint16, clientid common.ClientID) error {
+	packet := gwc.packetConn.NewPacket()
+	packet.AppendUint16(MT_GIVE_CLIENT_TO_ENTITY)
+	packet.AppendEntityID(targetID)
+	packet.AppendEntityID(sourceID)
+	packet.AppendUint16(gid)
+	packet.AppendClientID(clientid)
+	return gwc.SendPacketRelease(packet)
+}
+
 // SendCallEntityMethodFromClient sends MT_CALL_ENTITY_METHOD_FROM_CLIENT message
 func (gwc *GoWorldConnection) SendCallEntityMethodFromClient(id common.EntityID, method string, args []interface{}) error {
 	packet := gwc.packetConn.NewPacket()
@@ -172,6 +252,22 @@ func (gwc *GoWorldConnection) SetHeartbeatFromClient() error {
 
 }
 
+// SendSetCompactSyncFromClient sends MT_SET_COMPACT_SYNC_FROM_CLIENT message
+func (gwc *GoWorldConnection) SendSetCompactSyncFromClient(enable bool) error {
+	packet := gwc.packetConn.NewPacket()
+	packet.AppendUint16(MT_SET_COMPACT_SYNC_FROM_CLIENT)
+	packet.AppendBool(enable)
+	return gwc.SendPacketRelease(packet)
+}
+
+// SendSetCompactSyncOnClient sends MT_SET_COMPACT_SYNC_ON_CLIENT message
+func (gwc *GoWorldConnection) SendSetCompactSyncOnClient(enabled bool) error {
+	packet := gwc.packetConn.NewPacket()
+	packet.AppendUint16(MT_SET_COMPACT_SYNC_ON_CLIENT)
+	packet.AppendBool(enabled)
+	return gwc.SendPacketRelease(packet)
+}
+
 // SendDestroyEntityOnClient sends MT_DESTROY_ENTITY_ON_CLIENT message
 func (gwc *GoWorldConnection) SendDestroyEntityOnClient(gid uint16, clientid common.ClientID, typeName string, entityid common.EntityID) error {
 	packet := gwc.packetConn.NewPacket()
@@ -244,6 +340,36 @@ func (gwc *GoWorldConnection) SendNotifyListAttrAppendOnClient(gid uint16, clien
 	return gwc.SendPacketRelease(packet)
 }
 
+// SendNotifyAttrDeltaOnClient sends MT_NOTIFY_ATTR_DELTA_ON_CLIENT message
+//
+// Each op is [path, key, val] for setting and [path, key] for deleting, key is string for MapAttr and int for ListAttr
+func (gwc *GoWorldConnection) SendNotifyAttrDeltaOnClient(gid uint16, clientid common.ClientID, entityid common.EntityID, ops []interface{}) error {
+	packet := gwc.packetConn.NewPacket()
+	packet.AppendUint16(MT_NOTIFY_ATTR_DELTA_ON_CLIENT)
+	packet.AppendUint16(gid)
+	packet.AppendClientID(clientid)
+	packet.AppendEntityID(entityid)
+	packet.AppendData(ops)
+	return gwc.SendPacketRelease(packet)
+}
+
+// SendMoveEntityOnClient sends MT_MOVE_ENTITY_ON_CLIENT message
+func (gwc *GoWorldConnection) SendMoveEntityOnClient(gid uint16, clientid common.ClientID, entityid common.EntityID, x, y, z float32, tx, ty, tz float32, speed float32) error {
+	packet := gwc.packetConn.NewPacket()
+	packet.AppendUint16(MT_MOVE_ENTITY_ON_CLIENT)
+	packet.AppendUint16(gid)
+	packet.AppendClientID(clientid)
+	packet.AppendEntityID(entityid)
+	packet.AppendFloat32(x)
+	packet.AppendFloat32(y)
+	packet.AppendFloat32(z)
+	packet.AppendFloat32(tx)
+	packet.AppendFloat32(ty)
+	packet.AppendFloat32(tz)
+	packet.AppendFloat32(speed)
+	return gwc.SendPacketRelease(packet)
+}
+
 // SendCallEntityMethodOnClient sends MT_CALL_ENTITY_METHOD_ON_CLIENT message
 func (gwc *GoWorldConnection) SendCallEntityMethodOnClient(gid uint16, clientid common.ClientID, entityID common.EntityID, method string, args []interface{}) (err error) {
 	packet := gwc.packetConn.NewPacket()
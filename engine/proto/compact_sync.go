@@ -0,0 +1,213 @@
+package proto
+
+import (
+	"math"
+
+	"github.com/lovelly/goworld/engine/common"
+	"github.com/lovelly/goworld/engine/gwlog"
+	"github.com/lovelly/goworld/engine/netutil"
+)
+
+// Compact sync encoding of MT_SYNC_POSITION_YAW_COMPACT_ON_CLIENT
+//
+// Each entry starts with a uint16 header, the highest 2 bits are the entry kind and the lower 14 bits are the entity handle.
+// Entity handles are assigned per client by gate, and the mapping is sent to client in a NEW entry,
+// which is always followed by a FULL or FLOAT entry of the same handle carrying the position.
+//
+//	COMPACT_SYNC_NEW:   header, EntityID
+//	COMPACT_SYNC_FULL:  header, X, Y, Z as int24 in COMPACT_SYNC_PRECISION relative to space origin, yaw byte
+//	COMPACT_SYNC_DELTA: header, dX, dY, dZ as int16 in COMPACT_SYNC_PRECISION, yaw byte
+//	COMPACT_SYNC_FLOAT: header, X, Y, Z as float32, yaw byte
+//
+// FULL positions are quantised on the same grid as deltas: X = float32(qX) * COMPACT_SYNC_PRECISION,
+// which covers ±83886 units at 0.01 precision. Positions out of this range fall back to FLOAT entries.
+// Deltas are relative to the last position of the entity decoded by client, which is calculated in float32 on both sides:
+// X = X + float32(dX) * COMPACT_SYNC_PRECISION. Yaw byte is yaw in degrees quantised to 256 steps.
+const (
+	COMPACT_SYNC_NEW = iota
+	COMPACT_SYNC_FULL
+	COMPACT_SYNC_DELTA
+	COMPACT_SYNC_FLOAT
+
+	// COMPACT_SYNC_PRECISION is the precision of quantised positions and position deltas
+	COMPACT_SYNC_PRECISION = float32(0.01)
+	// COMPACT_SYNC_MAX_HANDLES is the max number of entity handles of each client
+	COMPACT_SYNC_MAX_HANDLES = 1 << 14
+
+	_COMPACT_SYNC_KIND_SHIFT  = 14
+	_COMPACT_SYNC_HANDLE_MASK = COMPACT_SYNC_MAX_HANDLES - 1
+	_COMPACT_SYNC_MAX_INT24   = 1<<23 - 1
+	_COMPACT_SYNC_MIN_INT24   = -1 << 23
+)
+
+// CompactSyncEncoder encodes entity sync infos to a client in compact sync encoding
+//
+// Each client has its own encoder, which remembers entity handles and last positions sent to the client.
+type CompactSyncEncoder struct {
+	handles     map[common.EntityID]uint16
+	lastPos     [][3]float32 // last positions decoded by client, indexed by handle
+	freeHandles []uint16
+}
+
+// NewCompactSyncEncoder creates a CompactSyncEncoder
+func NewCompactSyncEncoder() *CompactSyncEncoder {
+	return &CompactSyncEncoder{
+		handles: map[common.EntityID]uint16{},
+	}
+}
+
+// Encode appends the sync info of entity to packet
+func (enc *CompactSyncEncoder) Encode(packet *netutil.Packet, entityID common.EntityID, info EntitySyncInfo) {
+	pos := [3]float32{info.X, info.Y, info.Z}
+	handle, ok := enc.handles[entityID]
+	if !ok {
+		if handle, ok = enc.allocHandle(); !ok {
+			gwlog.Warnf("CompactSyncEncoder: too many entities, sync of %s is dropped", entityID)
+			return
+		}
+		enc.handles[entityID] = handle
+
+		packet.AppendUint16(COMPACT_SYNC_NEW<<_COMPACT_SYNC_KIND_SHIFT | handle)
+		packet.AppendEntityID(entityID)
+		enc.appendFull(packet, handle, pos, info.Yaw)
+		return
+	}
+
+	last := &enc.lastPos[handle]
+	var deltas [3]int16
+	for i := range pos {
+		d := math.Floor(float64((pos[i]-last[i])/COMPACT_SYNC_PRECISION) + 0.5)
+		if d < math.MinInt16 || d > math.MaxInt16 {
+			// too far from last position
+			enc.appendFull(packet, handle, pos, info.Yaw)
+			return
+		}
+		deltas[i] = int16(d)
+	}
+
+	packet.AppendUint16(COMPACT_SYNC_DELTA<<_COMPACT_SYNC_KIND_SHIFT | handle)
+	for i, d := range deltas {
+		packet.AppendUint16(uint16(d))
+		last[i] += float32(d) * COMPACT_SYNC_PRECISION
+	}
+	packet.AppendByte(quantiseYaw(info.Yaw))
+}
+
+// appendFull appends the position of handle quantised relative to space origin, or as float32 if it is out of range
+func (enc *CompactSyncEncoder) appendFull(packet *netutil.Packet, handle uint16, pos [3]float32, yaw float32) {
+	last := &enc.lastPos[handle]
+	var qpos [3]int32
+	for i := range pos {
+		q := math.Floor(float64(pos[i]/COMPACT_SYNC_PRECISION) + 0.5)
+		if q < _COMPACT_SYNC_MIN_INT24 || q > _COMPACT_SYNC_MAX_INT24 {
+			*last = pos
+			packet.AppendUint16(COMPACT_SYNC_FLOAT<<_COMPACT_SYNC_KIND_SHIFT | handle)
+			appendSyncPosition(packet, pos)
+			packet.AppendByte(quantiseYaw(yaw))
+			return
+		}
+		qpos[i] = int32(q)
+	}
+
+	packet.AppendUint16(COMPACT_SYNC_FULL<<_COMPACT_SYNC_KIND_SHIFT | handle)
+	for i, q := range qpos {
+		appendInt24(packet, q)
+		last[i] = float32(q) * COMPACT_SYNC_PRECISION
+	}
+	packet.AppendByte(quantiseYaw(yaw))
+}
+
+// Remove releases the handle of entity, should be called when entity is destroyed on client
+func (enc *CompactSyncEncoder) Remove(entityID common.EntityID) {
+	if handle, ok := enc.handles[entityID]; ok {
+		delete(enc.handles, entityID)
+		enc.freeHandles = append(enc.freeHandles, handle)
+	}
+}
+
+func (enc *CompactSyncEncoder) allocHandle() (uint16, bool) {
+	if n := len(enc.freeHandles); n > 0 {
+		handle := enc.freeHandles[n-1]
+		enc.freeHandles = enc.freeHandles[:n-1]
+		return handle, true
+	}
+	if len(enc.lastPos) >= COMPACT_SYNC_MAX_HANDLES {
+		return 0, false
+	}
+	enc.lastPos = append(enc.lastPos, [3]float32{})
+	return uint16(len(enc.lastPos) - 1), true
+}
+
+// CompactSyncDecoder decodes entity sync infos in compact sync encoding on client
+type CompactSyncDecoder struct {
+	entityIDs []common.EntityID
+	lastPos   [][3]float32
+}
+
+// NewCompactSyncDecoder creates a CompactSyncDecoder
+func NewCompactSyncDecoder() *CompactSyncDecoder {
+	return &CompactSyncDecoder{
+		entityIDs: make([]common.EntityID, COMPACT_SYNC_MAX_HANDLES),
+		lastPos:   make([][3]float32, COMPACT_SYNC_MAX_HANDLES),
+	}
+}
+
+// Decode reads all entity sync infos from packet
+func (dec *CompactSyncDecoder) Decode(packet *netutil.Packet, visit func(entityID common.EntityID, info EntitySyncInfo)) {
+	for packet.HasUnreadPayload() {
+		header := packet.ReadUint16()
+		kind, handle := header>>_COMPACT_SYNC_KIND_SHIFT, header&_COMPACT_SYNC_HANDLE_MASK
+		pos := &dec.lastPos[handle]
+		switch kind {
+		case COMPACT_SYNC_NEW:
+			dec.entityIDs[handle] = packet.ReadEntityID()
+			continue // position follows in the next entry
+		case COMPACT_SYNC_FULL:
+			for i := range pos {
+				pos[i] = float32(readInt24(packet)) * COMPACT_SYNC_PRECISION
+			}
+		case COMPACT_SYNC_DELTA:
+			for i := range pos {
+				pos[i] += float32(int16(packet.ReadUint16())) * COMPACT_SYNC_PRECISION
+			}
+		case COMPACT_SYNC_FLOAT:
+			*pos = readSyncPosition(packet)
+		default:
+			gwlog.Panicf("CompactSyncDecoder: invalid entry kind %d", kind)
+		}
+
+		yaw := float32(packet.ReadOneByte()) * 360 / 256
+		visit(dec.entityIDs[handle], EntitySyncInfo{pos[0], pos[1], pos[2], yaw})
+	}
+}
+
+func appendSyncPosition(packet *netutil.Packet, pos [3]float32) {
+	packet.AppendFloat32(pos[0])
+	packet.AppendFloat32(pos[1])
+	packet.AppendFloat32(pos[2])
+}
+
+func readSyncPosition(packet *netutil.Packet) [3]float32 {
+	return [3]float32{packet.ReadFloat32(), packet.ReadFloat32(), packet.ReadFloat32()}
+}
+
+// appendInt24 appends the lower 24 bits of v
+func appendInt24(packet *netutil.Packet, v int32) {
+	packet.AppendUint16(uint16(v))
+	packet.AppendByte(byte(v >> 16))
+}
+
+func readInt24(packet *netutil.Packet) int32 {
+	low := uint32(packet.ReadUint16())
+	high := uint32(packet.ReadOneByte())
+	return int32((high<<16|low)<<8) >> 8 // sign extend
+}
+
+// quantiseYaw quantises yaw in degrees to 256 steps
+func quantiseYaw(yaw float32) byte {
+	steps := int(math.Floor(float64(yaw)*256/360+0.5)) % 256
+	if steps < 0 {
+		steps += 256
+	}
+	return byte(steps)
+}
@@ -0,0 +1,168 @@
+package proto
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/lovelly/goworld/engine/common"
+	"github.com/lovelly/goworld/engine/netutil"
+)
+
+func checkSyncInfo(t *testing.T, entityID common.EntityID, info, decoded EntitySyncInfo) {
+	for i, d := range []float32{info.X - decoded.X, info.Y - decoded.Y, info.Z - decoded.Z} {
+		if math.Abs(float64(d)) > float64(COMPACT_SYNC_PRECISION) {
+			t.Fatalf("%s: axis %d decoded as %v, but should be %v", entityID, i, decoded, info)
+		}
+	}
+	dyaw := math.Mod(float64(info.Yaw-decoded.Yaw)+540, 360) - 180
+	if math.Abs(dyaw) > 360.0/256 {
+		t.Fatalf("%s: yaw decoded as %v, but should be %v", entityID, decoded.Yaw, info.Yaw)
+	}
+}
+
+func TestCompactSync(t *testing.T) {
+	enc, dec := NewCompactSyncEncoder(), NewCompactSyncDecoder()
+	entityIDs := make([]common.EntityID, 100)
+	infos := make([]EntitySyncInfo, len(entityIDs))
+	for i := range entityIDs {
+		entityIDs[i] = common.GenEntityID()
+		infos[i] = EntitySyncInfo{rand.Float32() * 10000, rand.Float32() * 100, rand.Float32() * 10000, rand.Float32() * 360}
+	}
+
+	for round := 0; round < 100; round++ {
+		packet := netutil.NewPacket()
+		synced := map[common.EntityID]EntitySyncInfo{}
+		for i, entityID := range entityIDs {
+			if rand.Intn(2) == 0 {
+				continue
+			}
+			info := &infos[i]
+			if rand.Intn(10) == 0 {
+				// teleport
+				info.X, info.Z = rand.Float32()*10000, rand.Float32()*10000
+			} else if rand.Intn(20) == 0 {
+				// teleport out of the range of quantised positions
+				info.X, info.Z = 100000+rand.Float32()*10000, -100000-rand.Float32()*10000
+			} else {
+				info.X += rand.Float32()*10 - 5
+				info.Z += rand.Float32()*10 - 5
+			}
+			info.Yaw = rand.Float32() * 360
+			enc.Encode(packet, entityID, *info)
+			synced[entityID] = *info
+		}
+
+		dec.Decode(packet, func(entityID common.EntityID, decoded EntitySyncInfo) {
+			info, ok := synced[entityID]
+			if !ok {
+				t.Fatalf("unexpected entity %s", entityID)
+			}
+			delete(synced, entityID)
+			checkSyncInfo(t, entityID, info, decoded)
+		})
+		if len(synced) != 0 {
+			t.Fatalf("entities not decoded: %v", synced)
+		}
+		packet.Release()
+
+		// replace some entities to reuse handles
+		i := rand.Intn(len(entityIDs))
+		enc.Remove(entityIDs[i])
+		entityIDs[i] = common.GenEntityID()
+	}
+
+	if len(enc.lastPos) > len(entityIDs)+1 {
+		t.Errorf("handles should be reused, but %d handles are allocated", len(enc.lastPos))
+	}
+}
+
+func TestInt24(t *testing.T) {
+	packet := netutil.NewPacket()
+	defer packet.Release()
+	values := []int32{0, 1, -1, 12345, -12345, _COMPACT_SYNC_MAX_INT24, _COMPACT_SYNC_MIN_INT24}
+	for _, v := range values {
+		appendInt24(packet, v)
+	}
+	for _, v := range values {
+		if d := readInt24(packet); d != v {
+			t.Errorf("int24 %d decoded as %d", v, d)
+		}
+	}
+}
+
+func TestQuantiseYaw(t *testing.T) {
+	for yaw, expected := range map[float32]byte{0: 0, 90: 64, 180: 128, 359.9: 0, -90: 192, 450: 64} {
+		if q := quantiseYaw(yaw); q != expected {
+			t.Errorf("yaw %v should be quantised to %d, but got %d", yaw, expected, q)
+		}
+	}
+}
+
+// benchmarkSync encodes sync infos of entities moving slowly, and reports the bytes per sync info
+func benchmarkSync(b *testing.B, encode func(packet *netutil.Packet, entityID common.EntityID, info EntitySyncInfo)) {
+	benchmarkSyncMove(b, 1, encode)
+}
+
+// benchmarkSyncMove encodes sync infos of entities moving by at most step in each round, and reports the bytes per sync info
+func benchmarkSyncMove(b *testing.B, step float32, encode func(packet *netutil.Packet, entityID common.EntityID, info EntitySyncInfo)) {
+	const numEntities = 100
+	entityIDs := make([]common.EntityID, numEntities)
+	infos := make([]EntitySyncInfo, numEntities)
+	for i := range entityIDs {
+		entityIDs[i] = common.GenEntityID()
+		infos[i] = EntitySyncInfo{rand.Float32() * 1000, 0, rand.Float32() * 1000, rand.Float32() * 360}
+	}
+
+	totalBytes := 0
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		packet := netutil.NewPacket()
+		for i, entityID := range entityIDs {
+			infos[i].X += (rand.Float32() - 0.5) * step
+			infos[i].Z += (rand.Float32() - 0.5) * step
+			encode(packet, entityID, infos[i])
+		}
+		totalBytes += int(packet.GetPayloadLen())
+		packet.Release()
+	}
+	b.ReportMetric(float64(totalBytes)/float64(b.N*numEntities), "bytes/sync")
+}
+
+func BenchmarkSyncFloat(b *testing.B) {
+	benchmarkSync(b, func(packet *netutil.Packet, entityID common.EntityID, info EntitySyncInfo) {
+		packet.AppendEntityID(entityID)
+		packet.AppendFloat32(info.X)
+		packet.AppendFloat32(info.Y)
+		packet.AppendFloat32(info.Z)
+		packet.AppendFloat32(info.Yaw)
+	})
+}
+
+func BenchmarkSyncCompact(b *testing.B) {
+	enc := NewCompactSyncEncoder()
+	benchmarkSync(b, enc.Encode)
+}
+
+// BenchmarkSyncTeleportFloat encodes teleports as full entries with float32 positions,
+// which is the previous encoding of FULL entries: 15 bytes/sync
+func BenchmarkSyncTeleportFloat(b *testing.B) {
+	handles := map[common.EntityID]uint16{}
+	benchmarkSyncMove(b, 1000, func(packet *netutil.Packet, entityID common.EntityID, info EntitySyncInfo) {
+		handle, ok := handles[entityID]
+		if !ok {
+			handle = uint16(len(handles))
+			handles[entityID] = handle
+		}
+		packet.AppendUint16(COMPACT_SYNC_FLOAT<<_COMPACT_SYNC_KIND_SHIFT | handle)
+		appendSyncPosition(packet, [3]float32{info.X, info.Y, info.Z})
+		packet.AppendByte(quantiseYaw(info.Yaw))
+	})
+}
+
+// BenchmarkSyncTeleportCompact encodes teleports as full entries with quantised positions: 12 bytes/sync,
+// or less when some teleports are short enough for deltas
+func BenchmarkSyncTeleportCompact(b *testing.B) {
+	enc := NewCompactSyncEncoder()
+	benchmarkSyncMove(b, 1000, enc.Encode)
+}
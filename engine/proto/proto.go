@@ -54,6 +54,30 @@ const (
 	MT_MIGRATE_REQUEST
 	// MT_REAL_MIGRATE is a message type for entity migrations
 	MT_REAL_MIGRATE
+
+	// Message types for calls with reply
+
+	// MT_CALL_ENTITY_METHOD_WITH_REPLY is a message type for calling entity methods which expect replies
+	MT_CALL_ENTITY_METHOD_WITH_REPLY
+	// MT_CALL_ENTITY_METHOD_REPLY is a message type for replying entity method calls
+	MT_CALL_ENTITY_METHOD_REPLY
+
+	// Message types for giving clients
+
+	// MT_GIVE_CLIENT_TO_ENTITY is a message type for giving clients to entities on any game
+	MT_GIVE_CLIENT_TO_ENTITY
+
+	// Message types for creating entities with replies
+
+	// MT_NOTIFY_CREATE_ENTITY_FAILED is a message type for notifying failures of creating or loading entities
+	MT_NOTIFY_CREATE_ENTITY_FAILED
+	// MT_CREATE_ENTITY_ANYWHERE_REPLY is a message type for replying creating or loading entities anywhere
+	MT_CREATE_ENTITY_ANYWHERE_REPLY
+
+	// Message types for loading members of persistent spaces
+
+	// MT_LOAD_ENTITY_IN_SPACE is a message type for loading entities into spaces if they are not on any game
+	MT_LOAD_ENTITY_IN_SPACE
 )
 
 const (
@@ -81,6 +105,10 @@ const (
 	MT_SET_CLIENTPROXY_FILTER_PROP
 	// MT_CLEAR_CLIENTPROXY_FILTER_PROPS message type
 	MT_CLEAR_CLIENTPROXY_FILTER_PROPS
+	// MT_NOTIFY_ATTR_DELTA_ON_CLIENT message type: batched attribute changes of an entity
+	MT_NOTIFY_ATTR_DELTA_ON_CLIENT
+	// MT_MOVE_ENTITY_ON_CLIENT message type: entity moves from position to target at speed, stops at position if speed is 0
+	MT_MOVE_ENTITY_ON_CLIENT
 	// MT_REDIRECT_TO_GATEPROXY_MSG_TYPE_STOP message type
 	MT_REDIRECT_TO_GATEPROXY_MSG_TYPE_STOP = 1499
 )
@@ -102,6 +130,24 @@ const (
 	MT_UDP_SYNC_CONN_NOTIFY_CLIENTID_ACK
 	// MT_HEARTBEAT_FROM_CLIENT is sent by client to notify the gate server that the client is alive
 	MT_HEARTBEAT_FROM_CLIENT
+	// MT_SET_COMPACT_SYNC_FROM_CLIENT is sent by client to request compact sync encoding
+	MT_SET_COMPACT_SYNC_FROM_CLIENT
+	// MT_SET_COMPACT_SYNC_ON_CLIENT is sent to client to tell if compact sync encoding is enabled
+	MT_SET_COMPACT_SYNC_ON_CLIENT
+	// MT_SYNC_POSITION_YAW_COMPACT_ON_CLIENT is sent to client with entity sync infos in compact sync encoding
+	MT_SYNC_POSITION_YAW_COMPACT_ON_CLIENT
+)
+
+// Error codes of creating or loading entities, sent with MT_NOTIFY_CREATE_ENTITY_FAILED and MT_CREATE_ENTITY_ANYWHERE_REPLY
+const (
+	// CREATE_ENTITY_OK means the entity is created or loaded
+	CREATE_ENTITY_OK = iota
+	// CREATE_ENTITY_FAILED means the entity is failed to create or load for other reasons
+	CREATE_ENTITY_FAILED
+	// CREATE_ENTITY_NOT_FOUND means the entity is not found in storage
+	CREATE_ENTITY_NOT_FOUND
+	// CREATE_ENTITY_TYPE_NOT_REGISTERED means the entity type is not registered in the game
+	CREATE_ENTITY_TYPE_NOT_REGISTERED
 )
 
 const (
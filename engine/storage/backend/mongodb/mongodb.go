@@ -5,10 +5,12 @@ import (
 	"gopkg.in/mgo.v2/bson"
 
 	"io"
+	"strings"
 
 	"github.com/lovelly/goworld/engine/common"
 	"github.com/lovelly/goworld/engine/gwlog"
 	"github.com/lovelly/goworld/engine/storage/storage_common"
+	"github.com/pkg/errors"
 )
 
 const (
@@ -50,12 +52,52 @@ func (es *mongoDBEntityStorge) Write(typeName string, entityID common.EntityID,
 	return err
 }
 
+// WritePatch updates changed sub-documents of entity data using $set and $unset
+func (es *mongoDBEntityStorge) WritePatch(typeName string, entityID common.EntityID, patch *storagecommon.DataPatch) error {
+	update := bson.M{}
+	if len(patch.Set) > 0 {
+		set := bson.M{}
+		for _, entry := range patch.Set {
+			set[dataFieldPath(entry.Path)] = entry.Value
+		}
+		update["$set"] = set
+	}
+	if len(patch.Unset) > 0 {
+		unset := bson.M{}
+		for _, path := range patch.Unset {
+			unset[dataFieldPath(path)] = ""
+		}
+		update["$unset"] = unset
+	}
+	if len(update) == 0 {
+		return nil
+	}
+
+	col := es.getCollection(typeName)
+	err := col.UpdateId(entityID, update)
+	if err == mgo.ErrNotFound {
+		return errors.Wrapf(storagecommon.ErrPatchNotApplicable, "mongodb: %s.%s not found", typeName, entityID)
+	}
+	return err
+}
+
+// IsRootPatchOnly returns false since MongoDB can update any sub-documents
+func (es *mongoDBEntityStorge) IsRootPatchOnly() bool {
+	return false
+}
+
+func dataFieldPath(path []string) string {
+	return "data." + strings.Join(path, ".")
+}
+
 func (es *mongoDBEntityStorge) Read(typeName string, entityID common.EntityID) (interface{}, error) {
 	col := es.getCollection(typeName)
 	q := col.FindId(entityID)
 	var doc bson.M
 	err := q.One(&doc)
-	if err != nil {
+	if err == mgo.ErrNotFound {
+		return nil, nil
+	} else if err != nil {
 		return nil, err
 	}
 	return es.convertM2Map(doc["data"].(bson.M)), nil
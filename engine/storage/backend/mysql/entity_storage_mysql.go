@@ -9,6 +9,8 @@ import (
 
 	"fmt"
 
+	"github.com/pkg/errors"
+
 	_ "github.com/go-sql-driver/mysql"
 )
 
@@ -99,6 +101,48 @@ func (es *mysqlEntityStorage) Write(typeName string, entityID common.EntityID, d
 	return err
 }
 
+// WritePatch applies the patch to entity data in a transaction
+func (es *mysqlEntityStorage) WritePatch(typeName string, entityID common.EntityID, patch *storagecommon.DataPatch) error {
+	if err := es.createTableForEntityTypeIfNotExists(typeName); err != nil {
+		return err
+	}
+
+	tx, err := es.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	row := tx.QueryRow("SELECT `data` FROM `"+typeName+"` WHERE `id` = ? FOR UPDATE", string(entityID))
+	var b []byte
+	if err = row.Scan(&b); err == sql.ErrNoRows {
+		return errors.Wrapf(storagecommon.ErrPatchNotApplicable, "mysql: %s.%s not found", typeName, entityID)
+	} else if err != nil {
+		return err
+	}
+
+	var data map[string]interface{}
+	if err = dataPacker.UnpackMsg(b, &data); err != nil {
+		return err
+	}
+	if err = storagecommon.ApplyDataPatch(data, patch); err != nil {
+		return errors.Wrapf(storagecommon.ErrPatchNotApplicable, "mysql: apply patch to %s.%s failed: %s", typeName, entityID, err)
+	}
+
+	if b, err = packData(data); err != nil {
+		return err
+	}
+	if _, err = tx.Exec("UPDATE `"+typeName+"` SET `data` = ? WHERE `id` = ?", b, string(entityID)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// IsRootPatchOnly returns false since patches are applied to the unpacked entity data
+func (es *mysqlEntityStorage) IsRootPatchOnly() bool {
+	return false
+}
+
 func (es *mysqlEntityStorage) Read(typeName string, entityID common.EntityID) (interface{}, error) {
 	if err := es.createTableForEntityTypeIfNotExists(typeName); err != nil {
 		return nil, err
@@ -109,7 +153,9 @@ func (es *mysqlEntityStorage) Read(typeName string, entityID common.EntityID) (i
 	row := es.db.QueryRow("SELECT `data` FROM `"+typeName+"` WHERE `id` = ?", string(entityID))
 	var b []byte
 	err = row.Scan(&b)
-	if err != nil {
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
 		return nil, err
 	}
 
@@ -10,6 +10,11 @@ import (
 	"github.com/lovelly/goworld/engine/storage/storage_common"
 )
 
+const (
+	// _HASH_MARKER_FIELD makes sure that the hash of entity exists even if it has no attribute
+	_HASH_MARKER_FIELD = "$"
+)
+
 var (
 	dataPacker = netutil.MessagePackMsgPacker{}
 )
@@ -82,18 +87,121 @@ func isZeroCursor(c interface{}) bool {
 	return string(c.([]byte)) == "0"
 }
 
+// Write writes entity data as a hash, each root attribute is stored in a field
 func (es *redisEntityStorage) Write(typeName string, entityID common.EntityID, data interface{}) error {
-	b, err := packData(data)
+	doc, ok := data.(map[string]interface{})
+	if !ok {
+		return errors.Errorf("redis: can not write entity data of type %T", data)
+	}
+
+	key := entityKey(typeName, entityID)
+	args := redis.Args{}.Add(key, _HASH_MARKER_FIELD, "")
+	for attr, val := range doc {
+		b, err := packData(val)
+		if err != nil {
+			return err
+		}
+		args = args.Add(attr, b)
+	}
+
+	es.c.Send("MULTI")
+	es.c.Send("DEL", key)
+	es.c.Send("HMSET", args...)
+	_, err := es.c.Do("EXEC")
+	return err
+}
+
+// WritePatch updates fields of changed root attributes
+func (es *redisEntityStorage) WritePatch(typeName string, entityID common.EntityID, patch *storagecommon.DataPatch) error {
+	key := entityKey(typeName, entityID)
+	keyType, err := redis.String(es.c.Do("TYPE", key))
 	if err != nil {
 		return err
 	}
 
-	_, err = es.c.Do("SET", entityKey(typeName, entityID), b)
+	if keyType == "none" {
+		return errors.Wrapf(storagecommon.ErrPatchNotApplicable, "redis: %s not found", key)
+	} else if keyType == "string" {
+		// entity data is saved as a single value by older versions, convert it to hash
+		data, err := es.readString(key)
+		if err != nil {
+			return err
+		}
+		if err := storagecommon.ApplyDataPatch(data, patch); err != nil {
+			return errors.Wrapf(storagecommon.ErrPatchNotApplicable, "redis: apply patch to %s failed: %s", key, err)
+		}
+		return es.Write(typeName, entityID, data)
+	}
+
+	setArgs := redis.Args{}.Add(key, _HASH_MARKER_FIELD, "")
+	for _, entry := range patch.Set {
+		if len(entry.Path) != 1 {
+			return errors.Wrapf(storagecommon.ErrPatchNotApplicable, "redis: can not patch sub-document %v", entry.Path)
+		}
+		b, err := packData(entry.Value)
+		if err != nil {
+			return err
+		}
+		setArgs = setArgs.Add(entry.Path[0], b)
+	}
+	delArgs := redis.Args{}.Add(key)
+	for _, path := range patch.Unset {
+		if len(path) != 1 {
+			return errors.Wrapf(storagecommon.ErrPatchNotApplicable, "redis: can not patch sub-document %v", path)
+		}
+		delArgs = delArgs.Add(path[0])
+	}
+
+	es.c.Send("MULTI")
+	es.c.Send("HMSET", setArgs...)
+	if len(delArgs) > 1 {
+		es.c.Send("HDEL", delArgs...)
+	}
+	_, err = es.c.Do("EXEC")
 	return err
 }
 
+// IsRootPatchOnly returns true since each root attribute is stored in a field of hash
+func (es *redisEntityStorage) IsRootPatchOnly() bool {
+	return true
+}
+
 func (es *redisEntityStorage) Read(typeName string, entityID common.EntityID) (interface{}, error) {
-	b, err := redis.Bytes(es.c.Do("GET", entityKey(typeName, entityID)))
+	key := entityKey(typeName, entityID)
+	keyType, err := redis.String(es.c.Do("TYPE", key))
+	if err != nil {
+		return nil, err
+	}
+
+	if keyType == "none" {
+		return nil, nil
+	} else if keyType == "string" {
+		return es.readString(key)
+	} else if keyType != "hash" {
+		return nil, redis.ErrNil
+	}
+
+	fields, err := redis.StringMap(es.c.Do("HGETALL", key))
+	if err != nil {
+		return nil, err
+	}
+	data := make(map[string]interface{}, len(fields))
+	for attr, b := range fields {
+		if attr == _HASH_MARKER_FIELD {
+			continue
+		}
+		var val interface{}
+		if err = dataPacker.UnpackMsg([]byte(b), &val); err != nil {
+			return nil, err
+		}
+		data[attr] = val
+	}
+	return data, nil
+}
+
+// readString reads entity data saved as a single value
+func (es *redisEntityStorage) readString(key string) (map[string]interface{}, error) {
+	b, err := redis.Bytes(es.c.Do("GET", key))
 	if err != nil {
 		return nil, err
 	}
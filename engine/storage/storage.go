@@ -25,6 +25,8 @@ var (
 	storageEngine            storagecommon.EntityStorage
 	operationQueue           = xnsyncutil.NewSyncQueue()
 	storageRoutineTerminated = xnsyncutil.NewOneTimeCond()
+	patchSupported           bool
+	rootPatchOnly            bool
 )
 
 type saveRequest struct {
@@ -34,6 +36,14 @@ type saveRequest struct {
 	Callback SaveCallbackFunc
 }
 
+type savePatchRequest struct {
+	TypeName    string
+	EntityID    common.EntityID
+	Patch       *storagecommon.DataPatch
+	GetFullData func() interface{}
+	Callback    SaveCallbackFunc
+}
+
 type loadRequest struct {
 	TypeName string
 	EntityID common.EntityID
@@ -74,6 +84,31 @@ func Save(typeName string, entityID common.EntityID, data interface{}, callback
 	checkOperationQueueLen()
 }
 
+// SavePatch saves the changes of entity data to storage
+//
+// Only works if IsPatchSupported returns true. If the patch can not be applied, getFullData is called in the logic
+// goroutine and the full entity data is saved instead.
+func SavePatch(typeName string, entityID common.EntityID, patch *storagecommon.DataPatch, getFullData func() interface{}, callback SaveCallbackFunc) {
+	operationQueue.Push(savePatchRequest{
+		TypeName:    typeName,
+		EntityID:    entityID,
+		Patch:       patch,
+		GetFullData: getFullData,
+		Callback:    callback,
+	})
+	checkOperationQueueLen()
+}
+
+// IsPatchSupported returns if the storage backend can save the changes of entity data
+func IsPatchSupported() bool {
+	return patchSupported
+}
+
+// IsRootPatchOnly returns if the storage backend can only save changes of whole root attributes
+func IsRootPatchOnly() bool {
+	return rootPatchOnly
+}
+
 // Load loads entity data from storage
 func Load(typeName string, entityID common.EntityID, callback LoadCallbackFunc) {
 	operationQueue.Push(loadRequest{
@@ -127,6 +162,10 @@ func Initialize() {
 	if err != nil {
 		gwlog.Fatalf("Storage engine is not ready1: %s", err)
 	}
+	if patchStorage, ok := storageEngine.(storagecommon.PatchEntityStorage); ok {
+		patchSupported = true
+		rootPatchOnly = patchStorage.IsRootPatchOnly()
+	}
 	go storageRoutine()
 }
 
@@ -194,41 +233,40 @@ func storageRoutine() {
 		if saveReq, ok := op.(saveRequest); ok {
 			// handle save request
 			monop = opmon.StartOperation("storage.save")
-			for {
-				if consts.DEBUG_SAVE_LOAD {
-					gwlog.Debugf("storage: SAVING %s %s ...", saveReq.TypeName, saveReq.EntityID)
-				}
-				err := assureStorageEngineReady()
-				if err != nil {
-					gwlog.Errorf("Storage engine is not ready3: %s", err)
-					time.Sleep(time.Second) // wait for 1 second to retry
-					continue
-				}
-
-				if storageEngine == nil {
-					gwlog.Fatalf("storage engine is nil")
-				}
-
-				err = storageEngine.Write(saveReq.TypeName, saveReq.EntityID, saveReq.Data)
-				if err != nil {
-					// save failed ?
-					gwlog.Errorf("storage: save failed: %s", err)
-
-					if err != nil && storageEngine.IsEOF(err) {
-						storageEngine.Close()
-						storageEngine = nil
-					}
-
-					continue // always retry if fail
-				} else {
-					monop.Finish(time.Millisecond * 100)
-					if saveReq.Callback != nil {
-						post.Post(func() {
-							saveReq.Callback()
-						})
-					}
-					break
+			writeWithRetry(saveReq.TypeName, saveReq.EntityID, func() error {
+				return storageEngine.Write(saveReq.TypeName, saveReq.EntityID, saveReq.Data)
+			})
+			monop.Finish(time.Millisecond * 100)
+			if saveReq.Callback != nil {
+				post.Post(func() {
+					saveReq.Callback()
+				})
+			}
+		} else if patchReq, ok := op.(savePatchRequest); ok {
+			// handle save patch request
+			monop = opmon.StartOperation("storage.savepatch")
+			err := writeWithRetry(patchReq.TypeName, patchReq.EntityID, func() error {
+				patchStorage, ok := storageEngine.(storagecommon.PatchEntityStorage)
+				if !ok {
+					gwlog.Panicf("storage engine %T can not save patch", storageEngine)
 				}
+				return patchStorage.WritePatch(patchReq.TypeName, patchReq.EntityID, patchReq.Patch)
+			})
+			monop.Finish(time.Millisecond * 100)
+			if err != nil && patchReq.GetFullData != nil {
+				// the patch will never be applied, save full data instead
+				gwlog.Warnf("storage: save patch of %s %s failed: %s, saving full data ...", patchReq.TypeName, patchReq.EntityID, err)
+				post.Post(func() {
+					Save(patchReq.TypeName, patchReq.EntityID, patchReq.GetFullData(), patchReq.Callback)
+				})
+				continue
+			} else if err != nil {
+				gwlog.Errorf("storage: save patch of %s %s failed and will not be retried: %s", patchReq.TypeName, patchReq.EntityID, err)
+			}
+			if patchReq.Callback != nil {
+				post.Post(func() {
+					patchReq.Callback()
+				})
 			}
 		} else if loadReq, ok := op.(loadRequest); ok {
 			// handle load request
@@ -286,3 +324,40 @@ func storageRoutine() {
 		}
 	}
 }
+
+// writeWithRetry writes entity data using the write function, and retries until it succeeds
+//
+// Only errors caused by ErrPatchNotApplicable are returned, since patches which can not be applied never succeed on retry
+func writeWithRetry(typeName string, entityID common.EntityID, write func() error) error {
+	for {
+		if consts.DEBUG_SAVE_LOAD {
+			gwlog.Debugf("storage: SAVING %s %s ...", typeName, entityID)
+		}
+		err := assureStorageEngineReady()
+		if err != nil {
+			gwlog.Errorf("Storage engine is not ready3: %s", err)
+			time.Sleep(time.Second) // wait for 1 second to retry
+			continue
+		}
+
+		if storageEngine == nil {
+			gwlog.Fatalf("storage engine is nil")
+		}
+
+		err = write()
+		if err == nil {
+			return nil
+		}
+
+		if storagecommon.IsPatchNotApplicable(err) {
+			return err
+		}
+
+		// save failed ?
+		gwlog.Errorf("storage: save %s %s failed: %s, retrying ...", typeName, entityID, err)
+		if storageEngine.IsEOF(err) || storagecommon.IsConnectionError(err) {
+			storageEngine.Close()
+			storageEngine = nil
+		}
+	}
+}
@@ -1,13 +1,123 @@
 package storagecommon
 
-import "github.com/lovelly/goworld/engine/common"
+import (
+	"database/sql/driver"
+	"io"
+	"net"
+	"strconv"
+
+	"github.com/lovelly/goworld/engine/common"
+	"github.com/pkg/errors"
+)
 
 // EntityStorage defines the interface of entity storage backends
 type EntityStorage interface {
 	List(typeName string) ([]common.EntityID, error)
 	Write(typeName string, entityID common.EntityID, data interface{}) error
-	Read(typeName string, entityID common.EntityID) (interface{}, error)
+	Read(typeName string, entityID common.EntityID) (interface{}, error) // returns nil data if entity is not found
 	Exists(typeName string, entityID common.EntityID) (bool, error)
 	Close()
 	IsEOF(err error) bool
 }
+
+// PatchEntityStorage is implemented by entity storage backends which can update only the changed parts of entity data
+type PatchEntityStorage interface {
+	EntityStorage
+	// WritePatch applies the patch to entity data which is already written
+	WritePatch(typeName string, entityID common.EntityID, patch *DataPatch) error
+	// IsRootPatchOnly returns true if the backend can only update whole root attributes
+	IsRootPatchOnly() bool
+}
+
+// ErrPatchNotApplicable is returned by WritePatch if the patch can never be applied, e.g. entity data is not written yet
+// or the backend can not update the path. The full entity data should be written instead.
+var ErrPatchNotApplicable = errors.New("patch not applicable")
+
+// IsPatchNotApplicable returns if the error is caused by ErrPatchNotApplicable
+func IsPatchNotApplicable(err error) bool {
+	return errors.Cause(err) == ErrPatchNotApplicable
+}
+
+// IsConnectionError returns if the error is caused by the connection to storage backend, which might succeed on retry
+func IsConnectionError(err error) bool {
+	err = errors.Cause(err)
+	if err == io.EOF || err == io.ErrUnexpectedEOF || err == driver.ErrBadConn {
+		return true
+	}
+	_, ok := err.(net.Error)
+	return ok
+}
+
+// DataPatch is the changes of entity data since last save
+type DataPatch struct {
+	Set   []PatchEntry // changed sub-documents
+	Unset [][]string   // paths of deleted sub-documents
+}
+
+// PatchEntry is a changed sub-document of entity data
+type PatchEntry struct {
+	Path  []string // path from the root attribute, list indices are formatted as decimal strings
+	Value interface{}
+}
+
+// ApplyDataPatch applies the patch to entity data
+//
+// Entries whose paths are not found in data are skipped, and the first error is returned.
+func ApplyDataPatch(data map[string]interface{}, patch *DataPatch) (err error) {
+	for _, entry := range patch.Set {
+		if perr := applyPatchPath(data, entry.Path, entry.Value, false); perr != nil && err == nil {
+			err = perr
+		}
+	}
+	for _, path := range patch.Unset {
+		if perr := applyPatchPath(data, path, nil, true); perr != nil && err == nil {
+			err = perr
+		}
+	}
+	return
+}
+
+func applyPatchPath(data map[string]interface{}, path []string, val interface{}, unset bool) error {
+	var container interface{} = data
+	for i, key := range path {
+		last := i == len(path)-1
+		switch c := container.(type) {
+		case map[string]interface{}:
+			if last {
+				if unset {
+					delete(c, key)
+				} else {
+					c[key] = val
+				}
+				return nil
+			}
+			container = c[key]
+		case map[interface{}]interface{}:
+			if last {
+				if unset {
+					delete(c, key)
+				} else {
+					c[key] = val
+				}
+				return nil
+			}
+			container = c[key]
+		case []interface{}:
+			index, err := strconv.Atoi(key)
+			if err != nil || index < 0 || index >= len(c) {
+				return errors.Errorf("invalid list index in patch path %v", path)
+			}
+			if last {
+				if unset {
+					return errors.Errorf("can not unset list item %v", path)
+				}
+				c[index] = val
+				return nil
+			}
+			container = c[index]
+		default:
+			return errors.Errorf("patch path %v not found", path)
+		}
+	}
+	return nil
+}
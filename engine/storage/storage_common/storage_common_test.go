@@ -0,0 +1,21 @@
+package storagecommon
+
+import (
+	"io"
+	"net"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestErrorClassification(t *testing.T) {
+	if !IsPatchNotApplicable(errors.Wrapf(ErrPatchNotApplicable, "entity not found")) || IsPatchNotApplicable(io.EOF) {
+		t.Errorf("wrapped ErrPatchNotApplicable should be recognized")
+	}
+	if !IsConnectionError(io.EOF) || !IsConnectionError(&net.OpError{Op: "read", Err: io.ErrClosedPipe}) {
+		t.Errorf("EOF and network errors should be connection errors")
+	}
+	if IsConnectionError(ErrPatchNotApplicable) || IsConnectionError(errors.New("can not patch sub-document")) {
+		t.Errorf("other errors should not be connection errors")
+	}
+}
@@ -45,6 +45,12 @@ func GenUUID() string {
 	return _UUIDEncoding.EncodeToString(b)
 }
 
+// GenFixedUUID generates a UUID which is always the same for the same name
+func GenFixedUUID(name []byte) string {
+	sum := md5.Sum(name)
+	return _UUIDEncoding.EncodeToString(sum[:12])
+}
+
 // objectIdCounter is atomically incremented when generating a new ObjectId
 // using NewObjectId() function. It's used as a counter part of an id.
 var objectIdCounter uint32
@@ -12,6 +12,13 @@ func TestGenUUID(t *testing.T) {
 	}
 }
 
+func TestGenFixedUUID(t *testing.T) {
+	uuid := GenFixedUUID([]byte("space:1"))
+	if len(uuid) != UUID_LENGTH || uuid != GenFixedUUID([]byte("space:1")) || uuid == GenFixedUUID([]byte("space:2")) {
+		t.Fatalf("GenFixedUUID should be stable and unique by name: %s", uuid)
+	}
+}
+
 func BenchmarkGenUUID(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		GenUUID()
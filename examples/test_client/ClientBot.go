@@ -25,6 +25,7 @@ import (
 	"github.com/lovelly/goworld/engine/netutil"
 	"github.com/lovelly/goworld/engine/post"
 	"github.com/lovelly/goworld/engine/proto"
+	"github.com/xiaonanln/typeconv"
 	"github.com/xtaci/kcp-go"
 	"golang.org/x/net/websocket"
 )
@@ -55,6 +56,7 @@ type ClientBot struct {
 	useWebSocket       bool
 	noEntitySync       bool
 	packetQueue        chan packetQueueItem
+	compactSync        *proto.CompactSyncDecoder
 }
 
 type packetQueueItem struct { // packet queue from dispatcher client
@@ -113,6 +115,9 @@ func (bot *ClientBot) run() {
 		gwlog.Infof("Notify KCP connected ...")
 		bot.conn.SetHeartbeatFromClient()
 	}
+	if compactSync {
+		bot.conn.SendSetCompactSyncFromClient(true)
+	}
 
 	go bot.recvLoop()
 	bot.loop()
@@ -292,6 +297,28 @@ func (bot *ClientBot) handlePacket(msgtype proto.MsgType, packet *netutil.Packet
 			gwlog.Debugf("Entity %s Attribute %v: pop", entityID, path)
 		}
 		bot.applyListAttrPop(entityID, path)
+	} else if msgtype == proto.MT_NOTIFY_ATTR_DELTA_ON_CLIENT {
+		entityID := packet.ReadEntityID()
+		var ops []interface{}
+		packet.ReadData(&ops)
+		if !quiet {
+			gwlog.Debugf("Entity %s Attribute delta: %v", entityID, ops)
+		}
+		bot.applyAttrDelta(entityID, ops)
+	} else if msgtype == proto.MT_MOVE_ENTITY_ON_CLIENT {
+		entityID := packet.ReadEntityID()
+		x := entity.Coord(packet.ReadFloat32())
+		y := entity.Coord(packet.ReadFloat32())
+		z := entity.Coord(packet.ReadFloat32())
+		tx := entity.Coord(packet.ReadFloat32())
+		ty := entity.Coord(packet.ReadFloat32())
+		tz := entity.Coord(packet.ReadFloat32())
+		speed := packet.ReadFloat32()
+		if !quiet {
+			gwlog.Debugf("Entity %s move from %v to %v at speed %v", entityID, entity.Vector3{X: x, Y: y, Z: z}, entity.Vector3{X: tx, Y: ty, Z: tz}, speed)
+		}
+		// bots do not interpolate movements
+		bot.updateEntityPosition(entityID, entity.Vector3{X: x, Y: y, Z: z})
 	} else if msgtype == proto.MT_CREATE_ENTITY_ON_CLIENT {
 		isPlayer := packet.ReadBool()
 		entityID := packet.ReadEntityID()
@@ -349,9 +376,22 @@ func (bot *ClientBot) handlePacket(msgtype proto.MsgType, packet *netutil.Packet
 			y := entity.Coord(packet.ReadFloat32())
 			z := entity.Coord(packet.ReadFloat32())
 			yaw := entity.Yaw(packet.ReadFloat32())
-			bot.updateEntityPosition(entityID, entity.Vector3{x, y, z})
+			bot.updateEntityPosition(entityID, entity.Vector3{X: x, Y: y, Z: z})
 			bot.updateEntityYaw(entityID, yaw)
 		}
+	} else if msgtype == proto.MT_SYNC_POSITION_YAW_COMPACT_ON_CLIENT {
+		bot.compactSync.Decode(packet, func(entityID common.EntityID, info proto.EntitySyncInfo) {
+			bot.updateEntityPosition(entityID, entity.Vector3{X: entity.Coord(info.X), Y: entity.Coord(info.Y), Z: entity.Coord(info.Z)})
+			bot.updateEntityYaw(entityID, entity.Yaw(info.Yaw))
+		})
+	} else if msgtype == proto.MT_SET_COMPACT_SYNC_ON_CLIENT {
+		enabled := packet.ReadBool()
+		gwlog.Infof("%s: compact sync enabled: %v", bot, enabled)
+		if enabled && bot.compactSync == nil {
+			bot.compactSync = proto.NewCompactSyncDecoder()
+		} else if !enabled {
+			bot.compactSync = nil
+		}
 		//} else if msgtype == proto.MT_SET_CLIENT_CLIENTID {
 		//	clientid := packet.ReadClientID()
 		//	bot.setClientID(clientid)
@@ -431,6 +471,23 @@ func (bot *ClientBot) applyListAttrPop(entityID common.EntityID, path []interfac
 
 }
 
+// applyAttrDelta applies batched attribute changes: [path, key, val] for setting and [path, key] for deleting
+func (bot *ClientBot) applyAttrDelta(entityID common.EntityID, ops []interface{}) {
+	for _, op := range ops {
+		fields := op.([]interface{})
+		path, _ := fields[0].([]interface{})
+		if key, ok := fields[1].(string); ok {
+			if len(fields) == 3 {
+				bot.applyMapAttrChange(entityID, path, key, fields[2])
+			} else {
+				bot.applyMapAttrDel(entityID, path, key)
+			}
+		} else {
+			bot.applyListAttrChange(entityID, path, int(typeconv.Int(fields[1])), fields[2])
+		}
+	}
+}
+
 func (bot *ClientBot) createEntity(typeName string, entityID common.EntityID, isPlayer bool, clientData map[string]interface{}, x, y, z entity.Coord, yaw entity.Yaw) {
 	if bot.entities[entityID] == nil {
 		e := newClientEntity(bot, typeName, entityID, isPlayer, clientData, x, y, z, yaw)
@@ -1,9 +1,6 @@
 package main
 
 import (
-	"math/rand"
-	"time"
-
 	"github.com/lovelly/goworld"
 	"github.com/lovelly/goworld/engine/common"
 	"github.com/lovelly/goworld/engine/entity"
@@ -60,53 +57,15 @@ func (a *Account) Login_Client(username string, password string) {
 			avatarID = goworld.CreateEntityLocally("Avatar")
 			a.setAvatarID(username, avatarID)
 
-			avatar := goworld.GetEntity(avatarID)
-			a.onAvatarEntityFound(avatar)
+			a.GiveClientToID(avatarID)
 		} else {
 			goworld.LoadEntityAnywhere("Avatar", avatarID)
-			a.Call(avatarID, "GetSpaceID", a.ID) // request for avatar space ID
+			a.GiveClientToID(avatarID) // the client is given after avatar is loaded
 		}
 	})
 }
 
-// OnGetAvatarSpaceID is called by Avatar to send spaceID
-func (a *Account) OnGetAvatarSpaceID(avatarID common.EntityID, spaceID common.EntityID) {
-	// avatar may be in the same space with account, check again
-	avatar := goworld.GetEntity(avatarID)
-	if avatar != nil {
-		a.onAvatarEntityFound(avatar)
-		return
-	}
-
-	a.Attrs.SetStr("loginAvatarID", string(avatarID))
-	a.EnterSpace(spaceID, entity.Vector3{})
-}
-
-func (a *Account) onAvatarEntityFound(avatar *entity.Entity) {
-	a.GiveClientTo(avatar)
-}
-
 // OnClientDisconnected is triggered when client is disconnected or given
 func (a *Account) OnClientDisconnected() {
 	a.Destroy()
 }
-
-// OnMigrateIn is called when Account entity is migrate in
-func (a *Account) OnMigrateIn() {
-	loginAvatarID := common.EntityID(a.Attrs.GetStr("loginAvatarID"))
-	avatar := goworld.GetEntity(loginAvatarID)
-	gwlog.Debugf("%s migrating in, attrs=%v, loginAvatarID=%s, avatar=%v, client=%s", a, a.Attrs.ToMap(), loginAvatarID, avatar, a.GetClient())
-
-	if avatar != nil {
-		a.onAvatarEntityFound(avatar)
-	} else {
-		// failed ? try again
-		a.AddCallback(time.Millisecond*time.Duration(rand.Intn(3000)), "RetryLoginToAvatar", loginAvatarID)
-	}
-}
-
-// RetryLoginToAvatar retry to login
-func (a *Account) RetryLoginToAvatar(loginAvatarID common.EntityID) {
-	goworld.LoadEntityAnywhere("Avatar", loginAvatarID)
-	a.Call(loginAvatarID, "GetSpaceID", a.ID) // request for avatar space ID
-}
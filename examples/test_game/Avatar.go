@@ -7,7 +7,6 @@ import (
 
 	"strconv"
 
-	"github.com/lovelly/goworld"
 	"github.com/lovelly/goworld/engine/common"
 	"github.com/lovelly/goworld/engine/consts"
 	"github.com/lovelly/goworld/engine/entity"
@@ -24,14 +23,29 @@ type Avatar struct {
 }
 
 func (a *Avatar) DefineAttrs(desc *entity.EntityTypeDesc) {
-	desc.DefineAttr("name", "AllClients", "Persistent")
-	desc.DefineAttr("level", "AllClients", "Persistent")
-	desc.DefineAttr("prof", "AllClients", "Persistent")
-	desc.DefineAttr("exp", "Client", "Persistent")
-	desc.DefineAttr("mails", "Client", "Persistent")
-	desc.DefineAttr("spaceKind", "Persistent")
-	desc.DefineAttr("lastMailID", "Persistent")
-	desc.DefineAttr("testListField", "AllClients")
+	desc.DefineAttr("name", "AllClients", "Persistent", "Str").Default("无名")
+	desc.DefineAttr("level", "AllClients", "Persistent", "Int").Default(1)
+	desc.DefineAttr("prof", "AllClients", "Persistent", "Int").Default(func() interface{} {
+		return 1 + rand.Intn(4)
+	})
+	desc.DefineAttr("exp", "Client", "Persistent", "Int")
+	desc.DefineAttr("mails", "Client", "Persistent", "Map")
+	desc.DefineAttr("spaceKind", "Persistent", "Int").Default(func() interface{} {
+		return 1 + rand.Intn(100)
+	})
+	desc.DefineAttr("lastMailID", "Persistent", "Int")
+	desc.DefineAttr("testListField", "AllClients", "List")
+	desc.SetAttrDeltaSync(true)
+
+	desc.DefineRPC("Say", entity.RPCLimits{
+		MaxArgBytes:       1024,
+		MaxCallsPerSecond: 10,
+		Args:              []entity.ArgLimit{entity.ArgLen(1, 16), entity.ArgLen(0, 512)},
+	})
+	desc.DefineRPC("EnterSpace", entity.RPCLimits{
+		MaxCallsPerSecond: 5,
+		Args:              []entity.ArgLimit{entity.ArgRange(1, 1000)},
+	})
 }
 
 func (a *Avatar) OnInit() {
@@ -39,7 +53,6 @@ func (a *Avatar) OnInit() {
 }
 
 func (a *Avatar) OnAttrsReady() {
-	a.setDefaultAttrs()
 	gwlog.Debugf("Avatar %s is ready: client=%s, mails=%d", a, a.GetClient(), a.Attrs.GetMapAttr("mails").Size())
 	a.Msgbox.SetMsgHandler(a.handleMsgboxMsg)
 }
@@ -53,6 +66,13 @@ func (a *Avatar) OnCreated() {
 
 	//gwlog.Debugf("Found OnlineService: %s", onlineServiceEid)
 	a.CallService("OnlineService", "CheckIn", a.ID, a.Attrs.GetStr("name"), a.Attrs.GetInt("level"))
+	a.CallServiceWithReply("OnlineService", "GetOnlineInfo", func(results []interface{}, err error) {
+		if err != nil {
+			gwlog.Errorf("%s get online info failed: %s", a, err)
+			return
+		}
+		gwlog.Infof("%s get online info: online=%v, maxlevel=%v", a, results[0], results[1])
+	})
 	for _, subject := range _TEST_PUBLISH_SUBSCRIBE_SUBJECTS { // subscribe all subjects
 		a.CallService(pubsub.ServiceName, "Subscribe", a.ID, subject)
 	}
@@ -60,22 +80,16 @@ func (a *Avatar) OnCreated() {
 	//a.AddTimer(time.Second, "PerSecondTick", 1, "")
 }
 
+// OnAttrChange_level is called when level of avatar is changed
+func (a *Avatar) OnAttrChange_level(path []interface{}, old, new interface{}) {
+	a.SetFilterProp("level", strconv.Itoa(int(a.GetInt("level"))))
+}
+
 // PerSecondTick is ticked per second, if timer is setup
 func (a *Avatar) PerSecondTick(arg1 int, arg2 string) {
 	fmt.Fprint(os.Stderr, "!")
 }
 
-func (a *Avatar) setDefaultAttrs() {
-	a.Attrs.SetDefaultStr("name", "无名")
-	a.Attrs.SetDefaultInt("level", 1)
-	a.Attrs.SetDefaultInt("exp", 0)
-	a.Attrs.SetDefaultInt("prof", int64(1+rand.Intn(4)))
-	a.Attrs.SetDefaultInt("spaceKind", int64(1+rand.Intn(100)))
-	a.Attrs.SetDefaultInt("lastMailID", 0)
-	a.Attrs.SetDefaultMapAttr("mails", goworld.MapAttr())
-	a.Attrs.SetDefaultListAttr("testListField", goworld.ListAttr())
-}
-
 // TestListField_Client is a test RPC for client
 func (a *Avatar) TestListField_Client() {
 	testListField := a.GetListAttr("testListField")
@@ -152,11 +166,6 @@ func (a *Avatar) OnEnterSpace() {
 	}
 }
 
-// GetSpaceID is a server RPC to query avatar space ID
-func (a *Avatar) GetSpaceID(callerID common.EntityID) {
-	a.Call(callerID, "OnGetAvatarSpaceID", a.ID, a.Space.ID)
-}
-
 // OnDestroy is called when avatar is destroying
 func (a *Avatar) OnDestroy() {
 	a.CallService("OnlineService", "CheckOut", a.ID)
@@ -50,3 +50,8 @@ func (s *OnlineService) CheckOut(avatarID common.EntityID) {
 	delete(s.avatars, avatarID)
 	gwlog.Infof("%s CHECK OUT: %s, total online %d", s, avatarID, len(s.avatars))
 }
+
+// GetOnlineInfo returns the number of online avatars and the max level
+func (s *OnlineService) GetOnlineInfo() (int, int) {
+	return len(s.avatars), s.maxlevel
+}
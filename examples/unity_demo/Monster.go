@@ -19,47 +19,28 @@ type Monster struct {
 }
 
 func (monster *Monster) DefineAttrs(desc *entity.EntityTypeDesc) {
-	desc.DefineAttr("name", "AllClients")
-	desc.DefineAttr("lv", "AllClients")
-	desc.DefineAttr("hp", "AllClients")
-	desc.DefineAttr("hpmax", "AllClients")
-	desc.DefineAttr("action", "AllClients")
+	desc.DefineAttr("name", "AllClients", "Str").Default("minion")
+	desc.DefineAttr("lv", "AllClients", "Int").Default(1)
+	desc.DefineAttr("hp", "AllClients", "Int").Default(100)
+	desc.DefineAttr("hpmax", "AllClients", "Int").Default(100)
+	desc.DefineAttr("action", "AllClients", "Str").Default("idle")
 }
 
 func (monster *Monster) OnEnterSpace() {
-	monster.setDefaultAttrs()
+	monster.attackCD = time.Second
+	monster.lastAttackTime = time.Now()
 	monster.AddTimer(time.Millisecond*100, "AI")
 	monster.lastTickTime = time.Now()
 	monster.AddTimer(time.Millisecond*30, "Tick")
 }
 
-func (monster *Monster) setDefaultAttrs() {
-	monster.Attrs.SetDefaultStr("name", "minion")
-	monster.Attrs.SetDefaultInt("lv", 1)
-	monster.Attrs.SetDefaultInt("hpmax", 100)
-	monster.Attrs.SetDefaultInt("hp", 100)
-	monster.Attrs.SetDefaultStr("action", "idle")
-
-	monster.attackCD = time.Second
-	monster.lastAttackTime = time.Now()
-}
-
 func (monster *Monster) AI() {
 	var nearestPlayer *entity.Entity
-	for entity := range monster.Neighbors() {
-
-		if entity.TypeName != "Player" {
-			continue
-		}
-
-		if entity.GetInt("hp") <= 0 {
-			// dead
-			continue
-		}
-
-		if nearestPlayer == nil || nearestPlayer.DistanceTo(&monster.Entity) > entity.DistanceTo(&monster.Entity) {
-			nearestPlayer = entity
-		}
+	players := monster.Space.GetNearestEntities(monster.GetPosition(), monster.Space.GetAOIDistance(), 1, func(e *entity.Entity) bool {
+		return e.TypeName == "Player" && e.GetInt("hp") > 0 // ignore dead players
+	})
+	if len(players) > 0 {
+		nearestPlayer = players[0]
 	}
 
 	if nearestPlayer == nil {
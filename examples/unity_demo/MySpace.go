@@ -7,21 +7,13 @@ import (
 	"github.com/lovelly/goworld/engine/gwlog"
 )
 
-const (
-	_SPACE_DESTROY_CHECK_INTERVAL = time.Minute * 5
-)
-
 // MySpace is the custom space type
 type MySpace struct {
 	entity.Space // Space type should always inherit from entity.Space
-
-	destroyCheckTimer entity.EntityTimerID
 }
 
 // OnSpaceCreated is called when the space is created
 func (space *MySpace) OnSpaceCreated() {
-	// notify the SpaceService that it's ok
-	space.CallService("SpaceService", "NotifySpaceLoaded", space.Kind, space.ID)
 	space.AddTimer(time.Second*5, "DumpEntityStatus")
 	space.AddTimer(time.Second*5, "SummonMonsters")
 	//M := 10
@@ -51,7 +43,6 @@ func (space *MySpace) OnEntityEnterSpace(entity *entity.Entity) {
 
 func (space *MySpace) onPlayerEnterSpace(entity *entity.Entity) {
 	gwlog.Debugf("Player %s enter space %s, total avatar count %d", entity, space, space.CountEntities("Player"))
-	space.clearDestroyCheckTimer()
 }
 
 // OnEntityLeaveSpace is called when entity leaves space
@@ -63,45 +54,4 @@ func (space *MySpace) OnEntityLeaveSpace(entity *entity.Entity) {
 
 func (space *MySpace) onPlayerLeaveSpace(entity *entity.Entity) {
 	gwlog.Infof("Player %s leave space %s, left avatar count %d", entity, space, space.CountEntities("Player"))
-	if space.CountEntities("Player") == 0 {
-		// no avatar left, start destroying space
-		space.setDestroyCheckTimer()
-	}
-}
-
-func (space *MySpace) setDestroyCheckTimer() {
-	if space.destroyCheckTimer != 0 {
-		return
-	}
-
-	space.destroyCheckTimer = space.AddTimer(_SPACE_DESTROY_CHECK_INTERVAL, "CheckForDestroy")
-}
-
-// CheckForDestroy checks if the space should be destroyed
-func (space *MySpace) CheckForDestroy() {
-	avatarCount := space.CountEntities("Player")
-	if avatarCount != 0 {
-		gwlog.Panicf("Player count should be 0, but is %d", avatarCount)
-	}
-
-	space.CallService("SpaceService", "RequestDestroy", space.Kind, space.ID)
-}
-
-func (space *MySpace) clearDestroyCheckTimer() {
-	if space.destroyCheckTimer == 0 {
-		return
-	}
-
-	space.CancelTimer(space.destroyCheckTimer)
-	space.destroyCheckTimer = 0
-}
-
-// ConfirmRequestDestroy is called by SpaceService to confirm that the space
-func (space *MySpace) ConfirmRequestDestroy(ok bool) {
-	if ok {
-		if space.CountEntities("Player") != 0 {
-			gwlog.Panicf("%s ConfirmRequestDestroy: avatar count is %d", space, space.CountEntities("Player"))
-		}
-		space.Destroy()
-	}
 }
@@ -13,29 +13,17 @@ type Player struct {
 }
 
 func (a *Player) DefineAttrs(desc *entity.EntityTypeDesc) {
-	desc.DefineAttr("name", "AllClients", "Persistent")
-	desc.DefineAttr("lv", "AllClients", "Persistent")
-	desc.DefineAttr("hp", "AllClients")
-	desc.DefineAttr("hpmax", "AllClients")
-	desc.DefineAttr("action", "AllClients")
-	desc.DefineAttr("spaceKind", "Persistent")
+	desc.DefineAttr("name", "AllClients", "Persistent", "Str").Default("noname")
+	desc.DefineAttr("lv", "AllClients", "Persistent", "Int").Default(1)
+	desc.DefineAttr("hp", "AllClients", "Int").Default(100)
+	desc.DefineAttr("hpmax", "AllClients", "Int").Default(100)
+	desc.DefineAttr("action", "AllClients", "Str").Default("idle")
+	desc.DefineAttr("spaceKind", "Persistent", "Int").Default(1)
 }
 
 // OnCreated 在Player对象创建后被调用
 func (a *Player) OnCreated() {
 	a.Entity.OnCreated()
-	a.setDefaultAttrs()
-}
-
-// setDefaultAttrs 设置玩家的一些默认属性
-func (a *Player) setDefaultAttrs() {
-	a.Attrs.SetDefaultInt("spaceKind", 1)
-	a.Attrs.SetDefaultStr("name", "noname")
-	a.Attrs.SetDefaultInt("lv", 1)
-	a.Attrs.SetDefaultInt("hp", 100)
-	a.Attrs.SetDefaultInt("hpmax", 100)
-	a.Attrs.SetDefaultStr("action", "idle")
-
 	a.SetClientSyncing(true)
 }
 
@@ -51,7 +39,7 @@ func (p *Player) enterSpace(spaceKind int) {
 	if consts.DEBUG_SPACES {
 		gwlog.Infof("%s enter space from %d => %d", p, p.Space.Kind, spaceKind)
 	}
-	p.CallService("SpaceService", "EnterSpace", p.ID, spaceKind)
+	p.EnterSpaceByKind(spaceKind, entity.Vector3{})
 }
 
 // OnClientConnected is called when client is connected
@@ -71,12 +59,6 @@ func (a *Player) EnterSpace_Client(kind int) {
 	a.enterSpace(kind)
 }
 
-// DoEnterSpace is called by SpaceService to notify avatar entering specified space
-func (a *Player) DoEnterSpace(kind int, spaceID common.EntityID) {
-	// let the avatar enter space with spaceID
-	a.EnterSpace(spaceID, entity.Vector3{})
-}
-
 //func (a *Player) randomPosition() entity.Vector3 {
 //	minCoord, maxCoord := -400, 400
 //	return entity.Vector3{
@@ -7,13 +7,13 @@ import (
 	"github.com/lovelly/goworld"
 	"github.com/lovelly/goworld/components/game"
 	"github.com/lovelly/goworld/engine/common"
+	"github.com/lovelly/goworld/engine/entity"
 	"github.com/lovelly/goworld/engine/gwlog"
 )
 
 var (
 	_SERVICE_NAMES = []string{
 		"OnlineService",
-		"SpaceService",
 	}
 )
 
@@ -24,9 +24,14 @@ type serverDelegate struct {
 
 func main() {
 	goworld.RegisterSpace(&MySpace{}) // 注册自定义的Space类型
+	// 场景最多容纳100名玩家，没有玩家5分钟后销毁
+	goworld.SetSpaceKindPolicy(1, entity.SpaceKindPolicy{
+		Capacity:    100,
+		CountTypes:  []string{"Player"},
+		IdleTimeout: time.Minute * 5,
+	})
 
 	goworld.RegisterEntity("OnlineService", &OnlineService{}, false, false)
-	goworld.RegisterEntity("SpaceService", &SpaceService{}, false, false)
 	// 注册Account类型
 	goworld.RegisterEntity("Account", &Account{}, false, false)
 	// 注册Monster类型
@@ -21,8 +21,8 @@ func Run(delegate game.IGameDelegate) {
 //
 // returns the entity type description object which can be used to define more properties
 // of entity type
-func RegisterEntity(typeName string, entityPtr entity.IEntity, isPersistent, useAOI bool) {
-	entity.RegisterEntity(typeName, entityPtr, isPersistent, useAOI)
+func RegisterEntity(typeName string, entityPtr entity.IEntity, isPersistent, useAOI bool) *entity.EntityTypeDesc {
+	return entity.RegisterEntity(typeName, entityPtr, isPersistent, useAOI)
 }
 
 // CreateSpaceAnywhere creates a space with specified kind in any game server
@@ -54,6 +54,60 @@ func LoadEntityAnywhere(typeName string, entityID common.EntityID) {
 	entity.LoadEntityAnywhere(typeName, entityID)
 }
 
+// CreateEntityAnywhereWithCallback creates a entity on any server and returns the entity ID
+//
+// callback is called with the game of the entity when the entity is created, or with an error if failed or timed out
+func CreateEntityAnywhereWithCallback(typeName string, callback entity.CreateEntityCallback) common.EntityID {
+	return entity.CreateEntityAnywhereWithCallback(typeName, callback)
+}
+
+// LoadEntityAnywhereWithCallback loads the specified entity from entity storage
+//
+// callback is called with the game of the entity when the entity is loaded or already exists,
+// or with an error if the entity is not found in storage, the entity type is not registered or timed out
+func LoadEntityAnywhereWithCallback(typeName string, entityID common.EntityID, callback entity.CreateEntityCallback) {
+	entity.LoadEntityAnywhereWithCallback(typeName, entityID, callback)
+}
+
+// LoadSpaceNavMap loads the navigation map file (.grid or .navmesh) of spaces of specified kind
+//
+// Navigation maps should be loaded before goworld.Run
+func LoadSpaceNavMap(kind int, path string) error {
+	return entity.LoadSpaceNavMap(kind, path)
+}
+
+// SetSpaceKindPolicy sets the lifecycle policy of spaces of the kind, including capacity, idle timeout and pool size
+//
+// Entities can enter spaces of kinds with policies by Entity.EnterSpaceByKind
+func SetSpaceKindPolicy(kind int, policy entity.SpaceKindPolicy) {
+	entity.SetSpaceKindPolicy(kind, policy)
+}
+
+// SetSpaceKindPersistent makes spaces of the kind persistent, and persistent entities of memberTypes in the spaces
+// are re-entered at their saved positions when the space is loaded
+func SetSpaceKindPersistent(kind int, memberTypes ...string) {
+	entity.SetSpaceKindPersistent(kind, memberTypes...)
+}
+
+// LoadPersistentSpaceAnywhere loads or creates the persistent space of the kind and instance name in any game
+//
+// returns the stable space ID; use empty instance for the only space of the kind
+func LoadPersistentSpaceAnywhere(kind int, instance string, callback entity.CreateEntityCallback) common.EntityID {
+	return entity.LoadPersistentSpaceAnywhere(kind, instance, callback)
+}
+
+// SetSpaceKindPartition splits spaces of the kind into cells of one large world, which can be on different games
+//
+// Should be called on all games before Run
+func SetSpaceKindPartition(kind int, partition entity.SpacePartition) {
+	entity.SetSpaceKindPartition(kind, partition)
+}
+
+// CreatePartitionedSpaceAnywhere creates all cells of the partitioned space kind on any games
+func CreatePartitionedSpaceAnywhere(kind int) {
+	entity.CreatePartitionedSpaceAnywhere(kind)
+}
+
 // GetServiceProviders get the set of EntityIDs that provides the specified service
 func GetServiceProviders(serviceName string) entity.EntityIDSet {
 	return entity.GetServiceProviders(serviceName)